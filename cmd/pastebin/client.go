@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Паста в ответах API сервера
+type paste struct {
+	ID                string     `json:"id"`
+	URL               string     `json:"url"`
+	Title             string     `json:"title"`
+	Content           string     `json:"content"`
+	Language          string     `json:"language"`
+	Visibility        string     `json:"visibility"`
+	Revision          int        `json:"revision"`
+	DeleteAfter       int32      `json:"delete_after"`
+	PasswordProtected bool       `json:"password_protected"`
+	CreatedAt         time.Time  `json:"created_at"`
+	ExpiresAt         *time.Time `json:"expires_at"`
+}
+
+type pasteList struct {
+	Pastes     []paste `json:"pastes"`
+	NextCursor string  `json:"next_cursor"`
+}
+
+// Поля новой пасты
+type pasteInput struct {
+	Title       string `json:"title,omitempty"`
+	Content     string `json:"content"`
+	Language    string `json:"language,omitempty"`
+	Visibility  string `json:"visibility,omitempty"`
+	Expires     string `json:"expires,omitempty"`
+	DeleteAfter int    `json:"delete_after,omitempty"`
+	Password    string `json:"password,omitempty"`
+}
+
+// Ошибка в едином формате API
+type apiError struct {
+	Status  int
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s (%d %s)", e.Message, e.Status, e.Code)
+}
+
+// HTTP-клиент к /api/v1
+type client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+func newClient(cfg config) *client {
+	return &client{
+		server: strings.TrimRight(cfg.Server, "/"),
+		token:  cfg.Token,
+		http:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Выполняем запрос и разбираем ответ в out (если он не nil)
+func (c *client) do(method, path string, headers map[string]string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.server+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var payload struct {
+			Error apiError `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil || payload.Error.Code == "" {
+			return &apiError{Status: resp.StatusCode, Code: "http_error", Message: http.StatusText(resp.StatusCode)}
+		}
+		payload.Error.Status = resp.StatusCode
+		return &payload.Error
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *client) create(in pasteInput) (paste, error) {
+	var p paste
+	err := c.do(http.MethodPost, "/api/v1/pastes", nil, in, &p)
+	return p, err
+}
+
+func (c *client) get(id, password string) (paste, error) {
+	var headers map[string]string
+	if password != "" {
+		headers = map[string]string{"X-Paste-Password": password}
+	}
+	var p paste
+	err := c.do(http.MethodGet, "/api/v1/pastes/"+url.PathEscape(id), headers, nil, &p)
+	return p, err
+}
+
+// Паста для правки владельцем или соавтором; просмотр не засчитывается
+func (c *client) getForEdit(id string) (paste, error) {
+	var p paste
+	err := c.do(http.MethodGet, "/api/v1/pastes/"+url.PathEscape(id)+"/edit", nil, nil, &p)
+	return p, err
+}
+
+func (c *client) list(public bool, limit int, cursor string) (pasteList, error) {
+	query := url.Values{}
+	if public {
+		query.Set("public", "true")
+	}
+	if limit > 0 {
+		query.Set("limit", fmt.Sprint(limit))
+	}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	var list pasteList
+	err := c.do(http.MethodGet, "/api/v1/pastes?"+query.Encode(), nil, nil, &list)
+	return list, err
+}
+
+func (c *client) updateContent(id, content string) (paste, error) {
+	var p paste
+	err := c.do(http.MethodPatch, "/api/v1/pastes/"+url.PathEscape(id), nil, map[string]string{"content": content}, &p)
+	return p, err
+}
+
+func (c *client) remove(id string) error {
+	return c.do(http.MethodDelete, "/api/v1/pastes/"+url.PathEscape(id), nil, nil, nil)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Настройки клиента: адрес сервера и API-токен
+type config struct {
+	Server string `json:"server"`
+	Token  string `json:"token"`
+}
+
+const defaultServer = "http://localhost:8080"
+
+// Путь к файлу настроек: $PASTEBIN_CONFIG или ~/.config/pastebin/config.json
+func configPath() (string, error) {
+	if path := os.Getenv("PASTEBIN_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pastebin", "config.json"), nil
+}
+
+// Настройки только из файла
+func readConfigFile() (config, error) {
+	cfg := config{Server: defaultServer}
+	path, err := configPath()
+	if err != nil {
+		return cfg, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return cfg, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, nil
+}
+
+// Читаем настройки; переменные окружения важнее файла
+func loadConfig() (config, error) {
+	cfg, err := readConfigFile()
+	if err != nil {
+		return cfg, err
+	}
+	if server := os.Getenv("PASTEBIN_SERVER"); server != "" {
+		cfg.Server = server
+	}
+	if token := os.Getenv("PASTEBIN_TOKEN"); token != "" {
+		cfg.Token = token
+	}
+	return cfg, nil
+}
+
+// Файл содержит токен, поэтому доступен только владельцу
+func saveConfig(cfg config) (string, error) {
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, append(data, '\n'), 0o600)
+}
@@ -0,0 +1,337 @@
+// Command pastebin — консольный клиент сервиса паст.
+//
+//	echo hello | pastebin -title greeting -expires 1day
+//	pastebin get <id>
+//	pastebin ls
+//	pastebin rm <id>...
+//	pastebin edit <id>
+//	pastebin config -server https://paste.example.com -token pb_...
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+const usage = `Usage:
+  pastebin [flags] [file]     create a paste from file or stdin and print its URL
+  pastebin get [-password p] <id>
+  pastebin ls [-public] [-limit n] [-all]
+  pastebin rm <id>...
+  pastebin edit <id>          open the paste in $EDITOR and save changes
+  pastebin config [-server url] [-token token]
+
+Settings are read from $PASTEBIN_CONFIG or ~/.config/pastebin/config.json;
+PASTEBIN_SERVER and PASTEBIN_TOKEN override them.
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "pastebin:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "get":
+			return runGet(args[1:], stdout)
+		case "ls":
+			return runList(args[1:], stdout)
+		case "rm":
+			return runRemove(args[1:], stdout, stderr)
+		case "edit":
+			return runEdit(args[1:], stdout)
+		case "config":
+			return runConfig(args[1:], stdout)
+		case "help", "-h", "-help", "--help":
+			fmt.Fprint(stdout, usage)
+			return nil
+		}
+	}
+	return runCreate(args, stdin, stdout)
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(fs.Output(), usage) }
+	return fs
+}
+
+// Клиент по сохранённым настройкам
+func loadClient() (*client, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	return newClient(cfg), nil
+}
+
+// ID можно передать и ссылкой на пасту
+func pasteID(arg string) string {
+	arg = strings.TrimRight(arg, "/")
+	if i := strings.LastIndex(arg, "/"); i >= 0 {
+		return arg[i+1:]
+	}
+	return arg
+}
+
+// Язык по расширению файла, если он есть в списке сервера
+var languagesByExt = map[string]string{
+	".go": "go", ".py": "python", ".js": "javascript", ".ts": "typescript", ".java": "java",
+	".c": "c", ".h": "c", ".cpp": "cpp", ".cc": "cpp", ".rs": "rust", ".sh": "bash",
+	".sql": "sql", ".json": "json", ".yaml": "yaml", ".yml": "yaml", ".html": "html",
+	".css": "css", ".md": "markdown", ".txt": "plaintext",
+}
+
+func runCreate(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("pastebin")
+	var in pasteInput
+	fs.StringVar(&in.Title, "title", "", "paste title")
+	fs.StringVar(&in.Language, "lang", "", "language for highlighting")
+	fs.StringVar(&in.Expires, "expires", "", "1hour, 1day, 1week, 1month, 6months, 1year or never")
+	fs.StringVar(&in.Visibility, "visibility", "", "public, unlisted or private")
+	fs.StringVar(&in.Password, "password", "", "protect the paste with a password")
+	fs.IntVar(&in.DeleteAfter, "burn", 0, "delete the paste after N reads")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var content []byte
+	var err error
+	switch fs.NArg() {
+	case 0:
+		content, err = io.ReadAll(stdin)
+	case 1:
+		path := fs.Arg(0)
+		content, err = os.ReadFile(path)
+		if in.Title == "" {
+			in.Title = filepath.Base(path)
+		}
+		if in.Language == "" {
+			in.Language = languagesByExt[strings.ToLower(filepath.Ext(path))]
+		}
+	default:
+		return errors.New("expected at most one file")
+	}
+	if err != nil {
+		return err
+	}
+	if len(content) == 0 {
+		return errors.New("nothing to paste: input is empty")
+	}
+	in.Content = string(content)
+
+	c, err := loadClient()
+	if err != nil {
+		return err
+	}
+	p, err := c.create(in)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, p.URL)
+	return nil
+}
+
+func runGet(args []string, stdout io.Writer) error {
+	fs := newFlagSet("get")
+	password := fs.String("password", "", "password of a protected paste")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: pastebin get [-password p] <id>")
+	}
+
+	c, err := loadClient()
+	if err != nil {
+		return err
+	}
+	p, err := c.get(pasteID(fs.Arg(0)), *password)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(stdout, p.Content)
+	if !strings.HasSuffix(p.Content, "\n") {
+		fmt.Fprintln(stdout)
+	}
+	return nil
+}
+
+func runList(args []string, stdout io.Writer) error {
+	fs := newFlagSet("ls")
+	public := fs.Bool("public", false, "list public pastes of all users")
+	limit := fs.Int("limit", 20, "pastes per page")
+	all := fs.Bool("all", false, "fetch every page")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	c, err := loadClient()
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCREATED\tVISIBILITY\tTITLE")
+	cursor := ""
+	for {
+		list, err := c.list(*public, *limit, cursor)
+		if err != nil {
+			return err
+		}
+		for _, p := range list.Pastes {
+			title := p.Title
+			if p.PasswordProtected {
+				title += " [password]"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.ID, p.CreatedAt.Local().Format("2006-01-02 15:04"), p.Visibility, title)
+		}
+		cursor = list.NextCursor
+		if !*all || cursor == "" {
+			break
+		}
+	}
+	return tw.Flush()
+}
+
+func runRemove(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: pastebin rm <id>...")
+	}
+	c, err := loadClient()
+	if err != nil {
+		return err
+	}
+	var failed int
+	for _, arg := range args {
+		id := pasteID(arg)
+		if err := c.remove(id); err != nil {
+			fmt.Fprintf(stderr, "pastebin: %s: %v\n", id, err)
+			failed++
+			continue
+		}
+		fmt.Fprintln(stdout, "deleted", id)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d pastes not deleted", failed, len(args))
+	}
+	return nil
+}
+
+func runEdit(args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: pastebin edit <id>")
+	}
+	c, err := loadClient()
+	if err != nil {
+		return err
+	}
+	id := pasteID(args[0])
+	// Обычное чтение засчитывается как просмотр и может сжечь пасту до сохранения
+	p, err := c.getForEdit(id)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp("", "pastebin-*"+extForLanguage(p.Language))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(p.Content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// $EDITOR может содержать аргументы, например "code --wait"
+	parts := strings.Fields(editor)
+	cmd := exec.Command(parts[0], append(parts[1:], tmp.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor: %w", err)
+	}
+
+	edited, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return err
+	}
+	if string(edited) == p.Content {
+		fmt.Fprintln(stdout, "no changes")
+		return nil
+	}
+	updated, err := c.updateContent(id, string(edited))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "saved revision %d: %s\n", updated.Revision, updated.URL)
+	return nil
+}
+
+// Расширение временного файла, чтобы редактор включил подсветку
+func extForLanguage(language string) string {
+	for ext, lang := range languagesByExt {
+		if lang == language && ext != ".h" && ext != ".cc" && ext != ".yml" {
+			return ext
+		}
+	}
+	return ".txt"
+}
+
+func runConfig(args []string, stdout io.Writer) error {
+	fs := newFlagSet("config")
+	server := fs.String("server", "", "server URL")
+	token := fs.String("token", "", "personal API token from the profile page")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *server == "" && *token == "" {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		masked := "(not set)"
+		if cfg.Token != "" {
+			masked = cfg.Token[:min(len(cfg.Token), 9)] + "…"
+		}
+		fmt.Fprintf(stdout, "server: %s\ntoken:  %s\n", cfg.Server, masked)
+		return nil
+	}
+
+	// Переменные окружения в файл не переносим
+	cfg, err := readConfigFile()
+	if err != nil {
+		return err
+	}
+	if *server != "" {
+		cfg.Server = *server
+	}
+	if *token != "" {
+		cfg.Token = *token
+	}
+	path, err := saveConfig(cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, "saved", path)
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Поддельный сервер API с одной пастой
+func fakeServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer pb_test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"code":"unauthorized","message":"Invalid token"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/pastes":
+			var in pasteInput
+			json.NewDecoder(r.Body).Decode(&in)
+			if in.Content != "hello\n" || in.Title != "greeting" || in.DeleteAfter != 2 {
+				t.Errorf("Неверное тело запроса: %+v", in)
+			}
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(paste{ID: "abc", URL: "http://paste.test/paste/abc"})
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/pastes/abc":
+			if r.Header.Get("X-Paste-Password") != "secret" {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"error":{"code":"password_required","message":"Paste is password protected"}}`))
+				return
+			}
+			json.NewEncoder(w).Encode(paste{ID: "abc", Content: "hello"})
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/pastes/abc/edit":
+			json.NewEncoder(w).Encode(paste{ID: "abc", Content: "hello"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":"not_found","message":"Paste not found"}}`))
+		}
+	}))
+}
+
+func setupConfig(t *testing.T, server, token string) {
+	t.Helper()
+	t.Setenv("PASTEBIN_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+	t.Setenv("PASTEBIN_SERVER", server)
+	t.Setenv("PASTEBIN_TOKEN", token)
+}
+
+func TestCreateFromStdin(t *testing.T) {
+	srv := fakeServer(t)
+	defer srv.Close()
+	setupConfig(t, srv.URL, "pb_test")
+
+	var out bytes.Buffer
+	err := run([]string{"-title", "greeting", "-burn", "2"}, strings.NewReader("hello\n"), &out, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "http://paste.test/paste/abc\n" {
+		t.Errorf("Ожидалась ссылка на пасту, получено %q", out.String())
+	}
+}
+
+func TestGetWithPassword(t *testing.T) {
+	srv := fakeServer(t)
+	defer srv.Close()
+	setupConfig(t, srv.URL, "pb_test")
+
+	var out bytes.Buffer
+	err := run([]string{"get", "http://paste.test/paste/abc"}, nil, &out, &out)
+	if err == nil || !strings.Contains(err.Error(), "password_required") {
+		t.Errorf("Ожидалась ошибка password_required, получено %v", err)
+	}
+
+	out.Reset()
+	if err := run([]string{"get", "-password", "secret", "abc"}, nil, &out, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "hello\n" {
+		t.Errorf("Неверное содержимое: %q", out.String())
+	}
+}
+
+func TestEditWithoutCountingView(t *testing.T) {
+	srv := fakeServer(t)
+	defer srv.Close()
+	setupConfig(t, srv.URL, "pb_test")
+	t.Setenv("VISUAL", "true")
+
+	// Паста с паролем: обычное чтение без пароля вернуло бы ошибку
+	var out bytes.Buffer
+	if err := run([]string{"edit", "abc"}, nil, &out, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "no changes\n" {
+		t.Errorf("Неверный вывод: %q", out.String())
+	}
+}
+
+func TestRemoveReportsToStderr(t *testing.T) {
+	srv := fakeServer(t)
+	defer srv.Close()
+	setupConfig(t, srv.URL, "pb_test")
+
+	var out, errOut bytes.Buffer
+	err := run([]string{"rm", "missing"}, nil, &out, &errOut)
+	if err == nil || out.Len() != 0 {
+		t.Errorf("Ожидалась ошибка без вывода, получено %v, %q", err, out.String())
+	}
+	if !strings.Contains(errOut.String(), "missing") {
+		t.Errorf("Ошибка не попала в stderr: %q", errOut.String())
+	}
+}
+
+func TestConfigSavedWithoutEnv(t *testing.T) {
+	setupConfig(t, "http://from-env", "pb_env")
+
+	var out bytes.Buffer
+	if err := run([]string{"config", "-token", "pb_saved"}, nil, &out, &out); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := readConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Token != "pb_saved" || cfg.Server != defaultServer {
+		t.Errorf("В файл попали неверные настройки: %+v", cfg)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/signal"
 	"pastebin/middleware"
+	"pastebin/models"
 	"pastebin/server"
 	"syscall"
 	"time"
@@ -23,12 +24,41 @@ func setupRoutes() *mux.Router {
 
 	// Определите маршруты
 	r.HandleFunc("/", server.MainPageHandler).Methods("GET")
+	r.PathPrefix("/static/").HandlerFunc(server.StaticHandler).Methods("GET", "HEAD")
 	r.HandleFunc("/create-paste", server.CreatePasteHandler).Methods("POST")
-	r.HandleFunc("/paste/{id}", server.ViewPasteHandler).Methods("GET")
+	r.Handle("/", middleware.OptionalAuthMiddleware(middleware.RequireScope(models.ScopePastesWrite, http.HandlerFunc(server.RawUploadHandler)))).Methods("POST")
+	r.HandleFunc("/paste/{id}", server.ViewPasteHandler).Methods("GET", "POST")
+	r.HandleFunc("/paste/{id}/raw", server.RawPasteHandler).Methods("GET")
+	r.HandleFunc("/paste/{id}/qr.svg", server.PasteQRSVGHandler).Methods("GET")
+	r.HandleFunc("/paste/{id}/qr.png", server.PasteQRPNGHandler).Methods("GET")
 	r.HandleFunc("/admin", middleware.AdminMiddleware(server.AllPastesHandler)).Methods("GET")
+	r.HandleFunc("/trending", server.TrendingHandler).Methods("GET")
+	r.HandleFunc("/popular", server.PopularHandler).Methods("GET")
+	r.HandleFunc("/paste/{id}/star", server.StarPasteHandler).Methods("POST")
+	r.HandleFunc("/paste/{id}/stats", server.PasteStatsHandler).Methods("GET")
+	r.HandleFunc("/paste/{id}/live", server.LiveEditPageHandler).Methods("GET")
+	r.HandleFunc("/paste/{id}/live/ws", server.LiveEditSocketHandler)
+	r.HandleFunc("/pastes/{id}/live", server.ToggleLiveEditHandler).Methods("POST")
+	r.HandleFunc("/admin/stats", middleware.AdminMiddleware(server.AdminStatsHandler)).Methods("GET")
+	r.HandleFunc("/admin/cache", middleware.AdminMiddleware(server.CacheStatsHandler)).Methods("GET")
+	r.HandleFunc("/admin/events", middleware.AdminMiddleware(server.EventStatsHandler)).Methods("GET")
 
 	r.HandleFunc("/pastes/{id}/delete", server.DeletePasteHandler).Methods("POST")
 	r.HandleFunc("/pastes/{id}/edit", server.EditPasteHandler).Methods("GET", "POST")
+	r.HandleFunc("/pastes/{id}/shares", server.SharePasteHandler).Methods("POST")
+	r.HandleFunc("/pastes/{id}/shares/{user}/delete", server.UnsharePasteHandler).Methods("POST")
+	r.HandleFunc("/paste/{id}/propose", server.ProposeEditPageHandler).Methods("GET")
+	r.HandleFunc("/paste/{id}/proposals", server.CreateProposalHandler).Methods("POST")
+	r.HandleFunc("/proposals/{id}", server.ProposalHandler).Methods("GET")
+	r.HandleFunc("/proposals/{id}/comments", server.ProposalCommentHandler).Methods("POST")
+	r.HandleFunc("/proposals/{id}/accept", server.AcceptProposalHandler).Methods("POST")
+	r.HandleFunc("/proposals/{id}/reject", server.RejectProposalHandler).Methods("POST")
+	r.HandleFunc("/proposals/{id}/withdraw", server.WithdrawProposalHandler).Methods("POST")
+
+	r.HandleFunc("/paste/{id}/comments", server.CreateCommentHandler).Methods("POST")
+	r.HandleFunc("/pastes/{id}/comments/toggle", server.ToggleCommentsHandler).Methods("POST")
+	r.HandleFunc("/comments/{id}/edit", server.EditCommentHandler).Methods("POST")
+	r.HandleFunc("/comments/{id}/delete", server.DeleteCommentHandler).Methods("POST")
 
 	r.HandleFunc("/signup", server.SignupHandler).Methods("GET", "POST")
 	r.HandleFunc("/login", server.LoginHandler).Methods("GET", "POST")
@@ -50,6 +80,44 @@ func setupRoutes() *mux.Router {
 	r.HandleFunc("/close_chat", server.CloseChatHandler).Methods("POST")
 	r.HandleFunc("/admin/chats", middleware.AdminMiddleware(server.AllChatsHandler)).Methods("GET")
 
+	r.HandleFunc("/profile/tokens", server.CreateAPITokenHandler).Methods("POST")
+	r.HandleFunc("/profile/tokens/{id}/revoke", server.RevokeAPITokenHandler).Methods("POST")
+	r.HandleFunc("/profile/pastes/bulk", server.BulkPastesHandler).Methods("POST")
+	r.HandleFunc("/profile/ssh-keys", server.AddSSHKeyHandler).Methods("POST")
+	r.HandleFunc("/profile/ssh-keys/{id}/delete", server.DeleteSSHKeyHandler).Methods("POST")
+	r.HandleFunc("/events/pastes", server.PasteEventsHandler).Methods("GET")
+	r.HandleFunc("/feed.atom", server.AtomFeedHandler).Methods("GET")
+	r.HandleFunc("/feed.rss", server.RSSFeedHandler).Methods("GET")
+
+	r.HandleFunc("/webhooks", server.WebhooksHandler).Methods("GET")
+	r.HandleFunc("/webhooks", server.CreateWebhookHandler).Methods("POST")
+	r.HandleFunc("/webhooks/{id}", server.WebhookDetailHandler).Methods("GET")
+	r.HandleFunc("/webhooks/{id}/delete", server.DeleteWebhookHandler).Methods("POST")
+	r.HandleFunc("/webhooks/{id}/deliveries/{delivery}/redeliver", server.RedeliverWebhookHandler).Methods("POST")
+
+	// JSON API; API-токены допускаются только с нужными правами
+	api := r.PathPrefix("/api/v1").Subrouter()
+	scoped := func(scope string, h http.HandlerFunc) http.Handler {
+		return middleware.AuthMiddleware(middleware.RequireScope(scope, h))
+	}
+	api.Handle("/pastes", scoped(models.ScopePastesRead, server.APIListPastesHandler)).Methods("GET")
+	api.Handle("/pastes", scoped(models.ScopePastesWrite, server.APICreatePasteHandler)).Methods("POST")
+	api.Handle("/pastes/batch", scoped(models.ScopePastesWrite, server.APIBatchCreatePastesHandler)).Methods("POST")
+	api.Handle("/pastes/bulk", scoped(models.ScopePastesWrite, server.APIBulkPastesHandler)).Methods("POST")
+	api.Handle("/pastes/{id}", middleware.OptionalAuthMiddleware(middleware.RequireScope(models.ScopePastesRead, http.HandlerFunc(server.APIGetPasteHandler)))).Methods("GET")
+	api.Handle("/pastes/{id}", scoped(models.ScopePastesWrite, server.APIUpdatePasteHandler)).Methods("PATCH")
+	api.Handle("/pastes/{id}", scoped(models.ScopePastesWrite, server.APIDeletePasteHandler)).Methods("DELETE")
+	api.Handle("/pastes/{id}/edit", scoped(models.ScopePastesRead, server.APIGetEditablePasteHandler)).Methods("GET")
+	api.Handle("/pastes/{id}/shares", scoped(models.ScopePastesRead, server.APIListPasteSharesHandler)).Methods("GET")
+	api.Handle("/pastes/{id}/shares", scoped(models.ScopePastesWrite, server.APISharePasteHandler)).Methods("POST")
+	api.Handle("/pastes/{id}/shares/{user_id}", scoped(models.ScopePastesWrite, server.APIUnsharePasteHandler)).Methods("DELETE")
+	api.Handle("/chat", scoped(models.ScopeChat, server.APIGetChatHandler)).Methods("GET")
+	api.Handle("/chat/messages", scoped(models.ScopeChat, server.APIPostChatMessageHandler)).Methods("POST")
+	api.Handle("/me", middleware.AuthMiddleware(http.HandlerFunc(server.APIMeHandler))).Methods("GET")
+
+	r.HandleFunc("/api/openapi.json", server.OpenAPIHandler).Methods("GET")
+	r.HandleFunc("/api/docs", server.APIDocsHandler).Methods("GET")
+
 	return r
 }
 
@@ -68,11 +136,51 @@ func main() {
 	// Инициализация логгера
 	server.InitLogger()
 
+	// Шаблоны страниц
+	if err := server.InitTemplates(); err != nil {
+		log.Fatalf("Ошибка загрузки шаблонов: %v", err)
+	}
+
+	// Подписчики доменных событий: аудит, метрики, уведомления, кэш, вебхуки
+	server.StartEventSubscribers()
+
+	// Фоновые задачи останавливаются вместе с сервером
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	server.StartDiscoveryAggregator(bgCtx)
+	server.StartViewFlusher(bgCtx)
+	server.StartExpirySweeper(bgCtx)
+	server.StartWebhookDispatcher(bgCtx)
+
 	// Создаем сервер
 	srv := &http.Server{
 		Addr:    ":8080",
 		Handler: setupRoutes(),
 	}
+	// SSE-потоки бесконечны: закрываем их, чтобы Shutdown не ждал
+	srv.RegisterOnShutdown(server.ClosePasteStreams)
+
+	// Приём паст по TCP (cat file | nc host 9999) — только если задан NETCAT_ADDR
+	var netcat *server.NetcatServer
+	if addr := os.Getenv("NETCAT_ADDR"); addr != "" {
+		netcat, err = server.StartNetcatServer(addr, middleware.AllowIP)
+		if err != nil {
+			log.Fatalf("Ошибка запуска netcat-приёма: %v", err)
+		}
+	}
+
+	// Приём паст по SSH (ssh host < file) — только если задан SSH_ADDR
+	var sshServer *server.SSHServer
+	if addr := os.Getenv("SSH_ADDR"); addr != "" {
+		hostKeyPath := os.Getenv("SSH_HOST_KEY")
+		if hostKeyPath == "" {
+			hostKeyPath = "ssh_host_ed25519_key"
+		}
+		sshServer, err = server.StartSSHServer(addr, hostKeyPath, middleware.AllowIP)
+		if err != nil {
+			log.Fatalf("Ошибка запуска SSH-сервера: %v", err)
+		}
+	}
 
 	// Канал для получения сигналов завершения
 	quit := make(chan os.Signal, 1)
@@ -98,6 +206,31 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	if netcat != nil {
+		if err := netcat.Shutdown(ctx); err != nil {
+			log.Printf("Error stopping netcat listener: %v", err)
+		}
+	}
+	if sshServer != nil {
+		if err := sshServer.Shutdown(ctx); err != nil {
+			log.Printf("Error stopping SSH server: %v", err)
+		}
+	}
+	// Сохраняем документы совместного редактирования и отключаем редакторы
+	if err := server.CloseLiveSessions(ctx); err != nil {
+		log.Printf("Error closing live editing sessions: %v", err)
+	}
+	stopBackground()
+
+	// Дожидаемся, пока подписчики обработают очередь событий
+	if err := server.CloseEvents(ctx); err != nil {
+		log.Printf("Error draining event subscribers: %v", err)
+	}
+
+	// Сбрасываем накопленные счётчики просмотров
+	if err := server.FlushViews(ctx); err != nil {
+		log.Printf("Error flushing view counters: %v", err)
+	}
 
 	// Закрываем базу данных
 	log.Println("Closing database connections...")
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"pastebin/server"
+)
+
+// Каждый зарегистрированный маршрут JSON API должен быть описан в OpenAPI
+func TestAPIRoutesDocumented(t *testing.T) {
+	paths := server.OpenAPISpec()["paths"].(map[string]interface{})
+
+	checked := 0
+	err := setupRoutes().Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(path, "/api/v1/") {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			t.Errorf("Маршрут %s зарегистрирован без методов", path)
+			return nil
+		}
+		for _, method := range methods {
+			checked++
+			item, ok := paths[path].(map[string]interface{})
+			if !ok {
+				t.Errorf("%s %s отсутствует в спецификации", method, path)
+				continue
+			}
+			if _, ok := item[strings.ToLower(method)]; !ok {
+				t.Errorf("%s %s отсутствует в спецификации", method, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked == 0 {
+		t.Fatal("Не найдено ни одного маршрута /api/v1")
+	}
+}
@@ -2,9 +2,12 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"net/http"
+	"pastebin/models"
 	"pastebin/server"
 	"pastebin/utils"
 	"strings"
@@ -16,42 +19,89 @@ func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Authorization header missing", http.StatusUnauthorized)
+			server.WriteAPIError(w, http.StatusUnauthorized, "unauthorized", "Authorization header missing")
 			return
 		}
 
-		// Извлекаем токен из заголовка
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method")
-			}
-			return jwtSecret, nil
-		})
-
-		if err != nil || !token.Valid {
-			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+		ctx, err := authenticate(r.Context(), authHeader)
+		if err != nil {
+			server.WriteAPIError(w, http.StatusUnauthorized, "unauthorized", err.Error())
 			return
 		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		// Извлекаем email из токена
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok || !token.Valid {
-			http.Error(w, "Invalid token claims", http.StatusUnauthorized)
+// Как AuthMiddleware, но запросы без заголовка Authorization проходят как гостевые
+func OptionalAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
 			return
 		}
+		AuthMiddleware(next).ServeHTTP(w, r)
+	})
+}
 
-		email, ok := claims["email"].(string)
-		if !ok {
-			http.Error(w, "Email not found in token", http.StatusUnauthorized)
+// Пропускаем запрос, только если у токена есть право scope
+func RequireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !utils.HasScope(r.Context(), scope) {
+			server.WriteAPIError(w, http.StatusForbidden, "insufficient_scope", "Token lacks the "+scope+" scope")
 			return
 		}
+		next.ServeHTTP(w, r)
+	})
+}
 
-		// Передаём email в контекст
-		ctx := context.WithValue(r.Context(), "userEmail", email)
-		next.ServeHTTP(w, r.WithContext(ctx))
+// Проверяем Bearer-токен и кладём email и ID пользователя в контекст
+func authenticate(ctx context.Context, authHeader string) (context.Context, error) {
+	// Извлекаем токен из заголовка
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+
+	// Персональный API-токен: ограничен правами, выданными при создании
+	if strings.HasPrefix(tokenString, models.APITokenPrefix) {
+		apiToken, err := server.AuthenticateAPIToken(ctx, tokenString)
+		if err != nil {
+			return nil, err
+		}
+		ctx = utils.ContextWithScopes(ctx, apiToken.Scopes)
+		return utils.ContextWithUserID(ctx, apiToken.UserID), nil
+	}
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method")
+		}
+		return jwtSecret, nil
 	})
+
+	if err != nil || !token.Valid {
+		return nil, errors.New("Invalid or expired token")
+	}
+
+	// Извлекаем email из токена
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("Invalid token claims")
+	}
+
+	email, ok := claims["email"].(string)
+	if !ok {
+		return nil, errors.New("Email not found in token")
+	}
+
+	userIDHex, _ := claims["user_id"].(string)
+	userID, err := primitive.ObjectIDFromHex(userIDHex)
+	if err != nil {
+		return nil, errors.New("User ID not found in token")
+	}
+
+	// Передаём email и ID пользователя в контекст
+	ctx = context.WithValue(ctx, "userEmail", email)
+	return utils.ContextWithUserID(ctx, userID), nil
 }
+
 func AdminMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Извлекаем токен из куки
@@ -49,6 +49,11 @@ func cleanupOldClients() {
 	}
 }
 
+// Общий лимит для всех способов создать запрос с одного IP: HTTP и TCP-приёма паст
+func AllowIP(ip string) bool {
+	return getLimiter(ip).Allow()
+}
+
 func RateLimiterMiddleware(next http.Handler) http.Handler {
 	go cleanupOldClients()
 
@@ -59,8 +64,7 @@ func RateLimiterMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		limiter := getLimiter(ip)
-		if !limiter.Allow() {
+		if !AllowIP(ip) {
 			http.Error(w, "Too many requests", http.StatusTooManyRequests)
 			return
 		}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Персональный API-токен пользователя; хранится только хэш значения
+type APIToken struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	UserID     primitive.ObjectID `bson:"user_id"`
+	Name       string             `bson:"name"`
+	Hash       string             `bson:"hash"`   // SHA-256 от значения токена
+	Prefix     string             `bson:"prefix"` // Начало токена, чтобы узнать его в списке
+	Scopes     []string           `bson:"scopes"`
+	CreatedAt  time.Time          `bson:"createdAt"`
+	ExpiresAt  time.Time          `bson:"expiresAt,omitempty"` // Нулевое значение — бессрочный
+	LastUsedAt time.Time          `bson:"lastUsedAt,omitempty"`
+	RevokedAt  time.Time          `bson:"revokedAt,omitempty"`
+}
+
+// Префикс, по которому API-токен отличается от JWT
+const APITokenPrefix = "pb_"
+
+// Права API-токенов
+const (
+	ScopePastesRead  = "pastes:read"
+	ScopePastesWrite = "pastes:write"
+	ScopeChat        = "chat"
+)
+
+var Scopes = []string{ScopePastesRead, ScopePastesWrite, ScopeChat}
+
+// Можно ли пользоваться токеном в момент now
+func (t APIToken) IsActive(now time.Time) bool {
+	if !t.RevokedAt.IsZero() {
+		return false
+	}
+	return t.ExpiresAt.IsZero() || now.Before(t.ExpiresAt)
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Комментарий к строке или диапазону строк пасты
+type Comment struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	PasteID    primitive.ObjectID `bson:"paste_id"`
+	UserID     primitive.ObjectID `bson:"user_id"`
+	ParentID   primitive.ObjectID `bson:"parent_id,omitempty"` // Для ответов в треде
+	AuthorName string             `bson:"author_name"`
+	LineStart  int                `bson:"line_start"`
+	LineEnd    int                `bson:"line_end"`
+	Revision   int                `bson:"revision"` // Ревизия пасты, к которой привязан комментарий
+	Content    string             `bson:"content"`
+	Deleted    bool               `bson:"deleted"`
+	CreatedAt  time.Time          `bson:"createdAt"`
+	UpdatedAt  time.Time          `bson:"updatedAt"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Ключ Idempotency-Key пользователя и сохранённый ответ на первый запрос с ним
+type IdempotencyKey struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty"`
+	UserID      primitive.ObjectID  `bson:"user_id"`
+	Key         string              `bson:"key"`
+	RequestHash string              `bson:"request_hash"` // SHA-256 метода, пути и тела
+	Completed   bool                `bson:"completed"`    // false — запрос ещё выполняется
+	Status      int                 `bson:"status,omitempty"`
+	Header      map[string][]string `bson:"header,omitempty"`
+	Body        []byte              `bson:"body,omitempty"`
+	LockedAt    time.Time           `bson:"lockedAt"`
+	CreatedAt   time.Time           `bson:"createdAt"`
+	ExpiresAt   time.Time           `bson:"expiresAt"` // TTL-индекс удаляет ключ
+}
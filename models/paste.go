@@ -7,13 +7,92 @@ import (
 
 // Структура для пасты
 type Paste struct {
-	ID           primitive.ObjectID `bson:"_id"`
-	UserID       primitive.ObjectID `bson:"user_id" json:"user_id"` // Ссылка на пользователя
-	Title        string             `bson:"title"`
-	Content      string             `bson:"content"`
-	CreatedAt    time.Time          `bson:"createdAt"`
-	Expires      string             `bson:"expires"`
-	Password     string             `bson:"password"`
-	DeleteAfter  int32              `bson:"deleteAfter"`
-	CurrentReads int32              `bson:"currentReads"`
+	ID               primitive.ObjectID `bson:"_id"`
+	UserID           primitive.ObjectID `bson:"user_id" json:"user_id"` // Ссылка на пользователя
+	Title            string             `bson:"title"`
+	Content          string             `bson:"content"`
+	CreatedAt        time.Time          `bson:"createdAt"`
+	Expires          string             `bson:"expires"`
+	ExpiresAt        time.Time          `bson:"expiresAt,omitempty"` // Нулевое значение — паста бессрочная
+	Password         string             `bson:"password"`            // bcrypt-хэш; пустая строка — без пароля
+	DeleteAfter      int32              `bson:"deleteAfter"`
+	CurrentReads     int32              `bson:"currentReads"`
+	Revision         int                `bson:"revision"`                // Номер ревизии, растёт при каждом редактировании
+	UpdatedAt        time.Time          `bson:"updatedAt"`               // Время последнего редактирования
+	UpdatedBy        primitive.ObjectID `bson:"updatedBy,omitempty"`     // Автор последней ревизии; пусто — правка админа или совместная
+	UpdatedByName    string             `bson:"updatedByName,omitempty"` // Его имя на момент правки
+	CommentsDisabled bool               `bson:"commentsDisabled"`        // Владелец может запретить комментарии к строкам
+	Language         string             `bson:"language"`
+	Visibility       string             `bson:"visibility"` // "public" / "unlisted" / "private"
+	Stars            int                `bson:"stars"`
+	LiveKey          string             `bson:"liveKey,omitempty"` // Ключ ссылки совместного редактирования; пусто — режим выключен
+	Shares           []PasteShare       `bson:"shares,omitempty"`  // Доступ, выданный владельцем другим пользователям
+}
+
+// Доступ к пасте для конкретного пользователя
+type PasteShare struct {
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Email     string             `bson:"email" json:"email"`
+	Role      string             `bson:"role" json:"role"` // "view" / "edit"
+	CreatedAt time.Time          `bson:"createdAt" json:"created_at"`
+}
+
+// Роли в доступе к пасте
+const (
+	ShareView = "view"
+	ShareEdit = "edit"
+)
+
+// Видимость пасты
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// Языки, доступные при создании пасты
+var Languages = []string{"plaintext", "go", "python", "javascript", "typescript", "java", "c", "cpp", "rust", "bash", "sql", "json", "yaml", "html", "css", "markdown"}
+
+// Истёк ли срок жизни пасты
+func (p Paste) IsExpired(now time.Time) bool {
+	return !p.ExpiresAt.IsZero() && !now.Before(p.ExpiresAt)
+}
+
+// Пасты, созданные до появления поля visibility, считаются публичными
+func (p Paste) IsPublic() bool {
+	return p.Visibility == VisibilityPublic || p.Visibility == ""
+}
+
+// Защищена ли паста паролем
+func (p Paste) HasPassword() bool {
+	return p.Password != ""
+}
+
+// Является ли пользователь владельцем; у анонимной пасты владельца нет
+func (p Paste) IsOwner(userID primitive.ObjectID) bool {
+	return !userID.IsZero() && userID == p.UserID
+}
+
+// Роль пользователя в доступе к пасте; пустая строка — доступа нет
+func (p Paste) ShareRole(userID primitive.ObjectID) string {
+	if userID.IsZero() {
+		return ""
+	}
+	for _, share := range p.Shares {
+		if share.UserID == userID {
+			return share.Role
+		}
+	}
+	return ""
+}
+
+// Может ли пользователь открыть пасту: приватную видят только владелец
+// и те, кому она открыта
+func (p Paste) CanView(userID primitive.ObjectID) bool {
+	return p.Visibility != VisibilityPrivate || p.IsOwner(userID) || p.ShareRole(userID) != ""
+}
+
+// Может ли пользователь менять название и текст пасты
+func (p Paste) CanEdit(userID primitive.ObjectID) bool {
+	return p.IsOwner(userID) || p.ShareRole(userID) == ShareEdit
 }
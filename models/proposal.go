@@ -0,0 +1,44 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Предложенная правка чужой пасты: владелец смотрит разницу, обсуждает
+// её и принимает или отклоняет
+type Proposal struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	PasteID        primitive.ObjectID `bson:"paste_id"`
+	OwnerID        primitive.ObjectID `bson:"owner_id"` // Владелец пасты, который решает
+	AuthorID       primitive.ObjectID `bson:"author_id"`
+	AuthorName     string             `bson:"author_name"`
+	Message        string             `bson:"message"` // Зачем эта правка
+	Title          string             `bson:"title"`
+	Content        string             `bson:"content"`
+	BaseRevision   int                `bson:"base_revision"` // Ревизия пасты, от которой сделана правка
+	BaseTitle      string             `bson:"base_title"`
+	BaseContent    string             `bson:"base_content"`
+	Status         string             `bson:"status"`
+	Comments       []ProposalComment  `bson:"comments,omitempty"`
+	CreatedAt      time.Time          `bson:"createdAt"`
+	ResolvedAt     time.Time          `bson:"resolvedAt,omitempty"`
+	MergedRevision int                `bson:"merged_revision,omitempty"` // Ревизия пасты после принятия
+}
+
+// Комментарий в обсуждении предложенной правки
+type ProposalComment struct {
+	UserID     primitive.ObjectID `bson:"user_id"`
+	AuthorName string             `bson:"author_name"`
+	Content    string             `bson:"content"`
+	CreatedAt  time.Time          `bson:"createdAt"`
+}
+
+// Состояния предложенной правки
+const (
+	ProposalOpen      = "open"
+	ProposalAccepted  = "accepted"
+	ProposalRejected  = "rejected"
+	ProposalWithdrawn = "withdrawn"
+	ProposalClosed    = "closed" // Паста удалена или истекла
+)
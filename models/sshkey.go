@@ -0,0 +1,17 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Публичный SSH-ключ пользователя; пасты, созданные по SSH с этим ключом, принадлежат ему
+type SSHKey struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	UserID      primitive.ObjectID `bson:"user_id"`
+	Name        string             `bson:"name"`
+	Fingerprint string             `bson:"fingerprint"` // SHA256:..., как у ssh-keygen -l
+	PublicKey   string             `bson:"public_key"`  // В формате authorized_keys
+	CreatedAt   time.Time          `bson:"createdAt"`
+	LastUsedAt  time.Time          `bson:"lastUsedAt,omitempty"`
+}
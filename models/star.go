@@ -0,0 +1,14 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Звезда, поставленная пользователем пасте
+type Star struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	PasteID   primitive.ObjectID `bson:"paste_id"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	CreatedAt time.Time          `bson:"createdAt"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// События, на которые можно подписать вебхук
+const (
+	EventPasteCreated = "paste.created"
+	EventPasteUpdated = "paste.updated"
+	EventPasteDeleted = "paste.deleted"
+	EventPasteExpired = "paste.expired"
+	EventChatMessage  = "chat.message"
+)
+
+var WebhookEvents = []string{EventPasteCreated, EventPasteUpdated, EventPasteDeleted, EventPasteExpired, EventChatMessage}
+
+// Адрес, на который отправляются события пользователя (или всего сайта — для админов)
+type Webhook struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	URL       string             `bson:"url"`
+	Secret    string             `bson:"secret"` // Ключ HMAC-SHA256 для подписи доставок
+	Events    []string           `bson:"events"`
+	Global    bool               `bson:"global"` // События всех пользователей; только для админов
+	CreatedAt time.Time          `bson:"createdAt"`
+}
+
+// Статусы доставки
+const (
+	DeliveryPending   = "pending"
+	DeliverySending   = "sending" // Взята на отправку, см. LockedUntil
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Одна отправка события на вебхук вместе с историей попыток
+type WebhookDelivery struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	WebhookID     primitive.ObjectID `bson:"webhook_id"`
+	Event         string             `bson:"event"`
+	Payload       string             `bson:"payload"` // Тело запроса как есть, чтобы подпись совпадала при повторе
+	Status        string             `bson:"status"`
+	Attempts      int                `bson:"attempts"`
+	NextAttemptAt time.Time          `bson:"nextAttemptAt"`
+	LockedUntil   time.Time          `bson:"lockedUntil,omitempty"` // До этого времени доставку отправляет тот, кто её взял
+	StatusCode    int                `bson:"statusCode,omitempty"`
+	LastError     string             `bson:"lastError,omitempty"`
+	ReplayOf      primitive.ObjectID `bson:"replayOf,omitempty"`
+	CreatedAt     time.Time          `bson:"createdAt"`
+	DeliveredAt   time.Time          `bson:"deliveredAt,omitempty"`
+	FinishedAt    time.Time          `bson:"finishedAt,omitempty"` // Доставлена или попытки кончились; по нему удаляется история
+}
@@ -0,0 +1,416 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"pastebin/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Размер страницы списка паст в админке
+const (
+	adminDefaultPageSize = 20
+	adminMaxPageSize     = 200
+	adminPreviewLength   = 200 // Символов содержимого в списке
+)
+
+// Состояние срока жизни для фильтра
+const (
+	expiryNever     = "never"     // Бессрочные
+	expiryScheduled = "scheduled" // Истекут в будущем
+	expiryExpired   = "expired"   // Срок вышел, но паста ещё не удалена
+)
+
+// Фильтры списка паст; пустые поля не ограничивают выборку
+type adminPasteFilter struct {
+	Owner      string // ID или email владельца, "anonymous" — без владельца
+	Language   string
+	Visibility string
+	MinSize    int // Байт содержимого
+	MaxSize    int
+	MinViews   int
+	MaxViews   int
+	Expiry     string
+	Period     string // last-day, last-week, last-month, last-year
+}
+
+var errUnknownOwner = errors.New("unknown owner")
+
+func parseAdminPasteFilter(q url.Values) (adminPasteFilter, error) {
+	f := adminPasteFilter{
+		Owner:      strings.TrimSpace(q.Get("owner")),
+		Language:   q.Get("language"),
+		Visibility: q.Get("visibility"),
+		Expiry:     q.Get("expiry"),
+		Period:     q.Get("filter"),
+	}
+	for name, dst := range map[string]*int{
+		"min_size": &f.MinSize, "max_size": &f.MaxSize,
+		"min_views": &f.MinViews, "max_views": &f.MaxViews,
+	} {
+		if value := q.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return f, fmt.Errorf("invalid %s", name)
+			}
+			*dst = n
+		}
+	}
+	switch f.Visibility {
+	case "", models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate:
+	default:
+		return f, fmt.Errorf("invalid visibility")
+	}
+	switch f.Expiry {
+	case "", expiryNever, expiryScheduled, expiryExpired:
+	default:
+		return f, fmt.Errorf("invalid expiry state")
+	}
+	return f, nil
+}
+
+// Условие запроса к pastes
+func (f adminPasteFilter) query(ctx context.Context, now time.Time) (bson.M, error) {
+	var and bson.A
+
+	if f.Owner != "" {
+		ownerID, err := resolveOwner(ctx, f.Owner)
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, bson.M{"user_id": ownerID})
+	}
+	if f.Language != "" {
+		and = append(and, bson.M{"language": f.Language})
+	}
+	switch f.Visibility {
+	case "":
+	case models.VisibilityPublic:
+		// Старые пасты без поля visibility считаются публичными
+		and = append(and, bson.M{"visibility": bson.M{"$in": bson.A{models.VisibilityPublic, "", nil}}})
+	default:
+		and = append(and, bson.M{"visibility": f.Visibility})
+	}
+
+	size := bson.M{"$strLenBytes": bson.M{"$ifNull": bson.A{"$content", ""}}}
+	if f.MinSize > 0 {
+		and = append(and, bson.M{"$expr": bson.M{"$gte": bson.A{size, f.MinSize}}})
+	}
+	if f.MaxSize > 0 {
+		and = append(and, bson.M{"$expr": bson.M{"$lte": bson.A{size, f.MaxSize}}})
+	}
+	if f.MinViews > 0 {
+		and = append(and, bson.M{"currentReads": bson.M{"$gte": f.MinViews}})
+	}
+	if f.MaxViews > 0 {
+		and = append(and, bson.M{"currentReads": bson.M{"$lte": f.MaxViews}})
+	}
+
+	switch f.Expiry {
+	case expiryNever:
+		and = append(and, bson.M{"expiresAt": bson.M{"$exists": false}})
+	case expiryScheduled:
+		and = append(and, bson.M{"expiresAt": bson.M{"$gt": now}})
+	case expiryExpired:
+		and = append(and, bson.M{"expiresAt": bson.M{"$lte": now}})
+	}
+
+	periods := map[string]time.Time{
+		"last-year":  now.AddDate(-1, 0, 0),
+		"last-month": now.AddDate(0, -1, 0),
+		"last-week":  now.AddDate(0, 0, -7),
+		"last-day":   now.AddDate(0, 0, -1),
+	}
+	if since, ok := periods[f.Period]; ok {
+		and = append(and, bson.M{"createdAt": bson.M{"$gte": since}})
+	}
+
+	if len(and) == 0 {
+		return bson.M{}, nil
+	}
+	return bson.M{"$and": and}, nil
+}
+
+// Владелец по ID или email
+func resolveOwner(ctx context.Context, owner string) (primitive.ObjectID, error) {
+	if owner == "anonymous" {
+		return primitive.NilObjectID, nil
+	}
+	if id, err := primitive.ObjectIDFromHex(owner); err == nil {
+		return id, nil
+	}
+	var user models.User
+	err := GetCollection("users").FindOne(ctx, bson.M{"email": owner}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return primitive.NilObjectID, errUnknownOwner
+	}
+	return user.ID, err
+}
+
+// Строка списка: паста без содержимого, но с его размером и началом
+type adminPasteRow struct {
+	models.Paste `bson:",inline"`
+	Size         int    `bson:"size"`
+	Preview      string `bson:"preview"`
+}
+
+// Пасты по фильтру в порядке (createdAt, _id). limit 0 — без ограничения
+func findAdminPastes(ctx context.Context, match, after bson.M, order, limit int) ([]adminPasteRow, error) {
+	cursor, err := adminPastesCursor(ctx, match, after, order, limit)
+	if err != nil {
+		return nil, err
+	}
+	var rows []adminPasteRow
+	err = cursor.All(ctx, &rows)
+	return rows, err
+}
+
+// Курсор по строкам списка паст; limit 0 — без ограничения
+func adminPastesCursor(ctx context.Context, match, after bson.M, order, limit int) (*mongo.Cursor, error) {
+	if after != nil {
+		match = bson.M{"$and": bson.A{match, after}}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$sort", Value: bson.D{{Key: "createdAt", Value: order}, {Key: "_id", Value: order}}}},
+	}
+	if limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit}})
+	}
+	content := bson.M{"$ifNull": bson.A{"$content", ""}}
+	pipeline = append(pipeline, bson.D{{Key: "$addFields", Value: bson.M{
+		"size":    bson.M{"$strLenBytes": content},
+		"preview": bson.M{"$substrCP": bson.A{content, 0, adminPreviewLength}},
+	}}}, bson.D{{Key: "$project", Value: bson.M{"content": 0}}})
+
+	return GetCollection("pastes").Aggregate(ctx, pipeline)
+}
+
+// Текущий запрос с заменой части параметров; пустое значение удаляет параметр
+func withQuery(r *http.Request, set map[string]string) string {
+	q := r.URL.Query()
+	for key, value := range set {
+		if value == "" {
+			q.Del(key)
+		} else {
+			q.Set(key, value)
+		}
+	}
+	return r.URL.Path + "?" + q.Encode()
+}
+
+// Список всех паст для админа: фильтры, keyset-пагинация и выгрузка в CSV.
+// ?cursor= — следующая страница, ?before= — предыдущая
+func AllPastesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	q := r.URL.Query()
+	filter, err := parseAdminPasteFilter(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	match, err := filter.query(ctx, time.Now())
+	if err == errUnknownOwner {
+		http.Error(w, "Owner not found", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to resolve owner")
+		return
+	}
+
+	order := -1 // От новых к старым
+	if q.Get("sort") == "oldest" {
+		order = 1
+	}
+
+	if q.Get("format") == "csv" {
+		writePastesCSV(ctx, w, match, order)
+		return
+	}
+
+	limit := parsePageSize(q.Get("limit"), adminDefaultPageSize, adminMaxPageSize)
+	var after bson.M
+	backwards := false
+	if value := q.Get("cursor"); value != "" {
+		c, err := decodeCursor(value)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		after = c.after(order)
+	} else if value := q.Get("before"); value != "" {
+		c, err := decodeCursor(value)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		// Предыдущая страница: идём в обратную сторону и разворачиваем
+		after = c.after(-order)
+		backwards = true
+	}
+
+	queryOrder := order
+	if backwards {
+		queryOrder = -order
+	}
+	// Одна лишняя запись показывает, есть ли страница дальше
+	rows, err := findAdminPastes(ctx, match, after, queryOrder, limit+1)
+	if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to fetch pastes")
+		return
+	}
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+	if backwards {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	total, err := GetCollection("pastes").CountDocuments(ctx, match)
+	if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to count pastes")
+		return
+	}
+	all, err := GetCollection("pastes").EstimatedDocumentCount(ctx)
+	if err != nil {
+		log.Printf("Ошибка подсчёта паст: %v", err)
+	}
+
+	// Ссылки на соседние страницы: вперёд есть, если нашлась лишняя запись
+	// или мы пришли назад; назад — если пришли по курсору
+	var next, prev string
+	if len(rows) > 0 {
+		first, last := rows[0], rows[len(rows)-1]
+		if (more && !backwards) || (backwards && after != nil) {
+			next = withQuery(r, map[string]string{"cursor": encodeCursor(last.CreatedAt, last.ID), "before": ""})
+		}
+		if (more && backwards) || (!backwards && after != nil) {
+			prev = withQuery(r, map[string]string{"before": encodeCursor(first.CreatedAt, first.ID), "cursor": ""})
+		}
+	}
+
+	render(w, r, "allpastes.html", struct {
+		Pastes    []adminPasteRow
+		Filter    adminPasteFilter
+		Sort      string
+		Limit     int
+		Total     int64
+		All       int64
+		Next      string
+		Prev      string
+		CSV       string
+		Languages []string
+		Now       time.Time
+	}{
+		Pastes:    rows,
+		Filter:    filter,
+		Sort:      q.Get("sort"),
+		Limit:     limit,
+		Total:     total,
+		All:       all,
+		Next:      next,
+		Prev:      prev,
+		CSV:       withQuery(r, map[string]string{"format": "csv", "cursor": "", "before": ""}),
+		Languages: models.Languages,
+		Now:       time.Now(),
+	})
+}
+
+// Через сколько строк выгрузки отправлять накопленное клиенту
+const csvFlushRows = 500
+
+// Защита от формул при открытии CSV в таблицах
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Все пасты по фильтру в CSV, без содержимого. Строки пишутся по мере
+// чтения курсора, чтобы не держать в памяти всю выборку
+func writePastesCSV(ctx context.Context, w http.ResponseWriter, match bson.M, order int) {
+	cursor, err := adminPastesCursor(ctx, match, nil, order, 0)
+	if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to fetch pastes")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="pastes-%s.csv"`, time.Now().UTC().Format("20060102-150405")))
+	out := csv.NewWriter(w)
+	out.Write([]string{
+		"id", "title", "owner_id", "language", "visibility", "size", "views", "stars", "revision",
+		"password_protected", "delete_after", "created_at", "updated_at", "expires_at",
+	})
+	for n := 1; cursor.Next(ctx); n++ {
+		var row adminPasteRow
+		if err := cursor.Decode(&row); err != nil {
+			log.Printf("Ошибка чтения пасты для CSV: %v", err)
+			return
+		}
+		owner := ""
+		if !row.UserID.IsZero() {
+			owner = row.UserID.Hex()
+		}
+		visibility := row.Visibility
+		if visibility == "" {
+			visibility = models.VisibilityPublic
+		}
+		out.Write([]string{
+			row.ID.Hex(),
+			csvSafe(row.Title),
+			owner,
+			row.Language,
+			visibility,
+			strconv.Itoa(row.Size),
+			strconv.Itoa(int(row.CurrentReads)),
+			strconv.Itoa(row.Stars),
+			strconv.Itoa(row.Revision),
+			strconv.FormatBool(row.HasPassword()),
+			strconv.Itoa(int(row.DeleteAfter)),
+			csvTime(row.CreatedAt),
+			csvTime(row.UpdatedAt),
+			csvTime(row.ExpiresAt),
+		})
+		if n%csvFlushRows == 0 {
+			out.Flush()
+			if err := out.Error(); err != nil {
+				log.Printf("Ошибка записи CSV: %v", err)
+				return
+			}
+		}
+	}
+	// Заголовки уже отправлены: обрыв выборки виден только в логе и по неполному файлу
+	if err := cursor.Err(); err != nil {
+		log.Printf("Ошибка выборки паст для CSV: %v", err)
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		log.Printf("Ошибка записи CSV: %v", err)
+	}
+}
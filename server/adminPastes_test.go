@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseAdminPasteFilter(t *testing.T) {
+	q, _ := url.ParseQuery("owner=+anonymous+&language=go&visibility=private&expiry=expired&min_size=10&max_views=5&filter=last-week")
+	f, err := parseAdminPasteFilter(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := adminPasteFilter{Owner: "anonymous", Language: "go", Visibility: "private", Expiry: expiryExpired, MinSize: 10, MaxViews: 5, Period: "last-week"}
+	if f != want {
+		t.Errorf("filter = %+v, want %+v", f, want)
+	}
+
+	for _, bad := range []string{"min_size=-1", "max_views=many", "visibility=secret", "expiry=soon"} {
+		q, _ := url.ParseQuery(bad)
+		if _, err := parseAdminPasteFilter(q); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestAdminPasteFilterQuery(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	match, err := adminPasteFilter{}.query(context.Background(), now)
+	if err != nil || len(match) != 0 {
+		t.Fatalf("empty filter = %v, %v", match, err)
+	}
+
+	// Владелец по ID и "anonymous" не требует обращения к базе
+	ownerID := primitive.NewObjectID()
+	match, err = adminPasteFilter{Owner: ownerID.Hex(), Expiry: expiryNever, MinViews: 3}.query(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	and := match["$and"].(bson.A)
+	if len(and) != 3 || and[0].(bson.M)["user_id"] != ownerID {
+		t.Errorf("query = %v", match)
+	}
+
+	match, _ = adminPasteFilter{Owner: "anonymous"}.query(context.Background(), now)
+	if got := match["$and"].(bson.A)[0].(bson.M)["user_id"]; got != primitive.NilObjectID {
+		t.Errorf("anonymous owner = %v", got)
+	}
+}
+
+func TestCSVSafe(t *testing.T) {
+	for in, want := range map[string]string{"=SUM(A1)": "'=SUM(A1)", "-1": "'-1", "@x": "'@x", "notes": "notes", "": ""} {
+		if got := csvSafe(in); got != want {
+			t.Errorf("csvSafe(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWithQuery(t *testing.T) {
+	r := httptest.NewRequest("GET", "/admin?language=go&cursor=abc", nil)
+	got := withQuery(r, map[string]string{"cursor": "", "before": "xyz"})
+	if got != "/admin?before=xyz&language=go" {
+		t.Errorf("withQuery = %q", got)
+	}
+}
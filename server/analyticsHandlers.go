@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"pastebin/models"
+	"pastebin/utils"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Сколько дней показываем в таблице просмотров по дням
+const statsDays = 30
+
+type DayStat struct {
+	Day    string `bson:"_id"`
+	Views  int    `bson:"views"`
+	Unique int    `bson:"unique"`
+}
+
+type ReferrerStat struct {
+	Host  string `bson:"_id"`
+	Count int    `bson:"count"`
+}
+
+type PasteViewStat struct {
+	PasteID primitive.ObjectID `bson:"_id"`
+	Title   string             `bson:"-"`
+	Views   int                `bson:"views"`
+	Unique  int                `bson:"unique"`
+}
+
+// Данные страницы статистики
+type statsPage struct {
+	Title     string
+	PasteID   string
+	Total     DayStat
+	Daily     []DayStat
+	Referrers []ReferrerStat
+	TopPastes []PasteViewStat
+}
+
+// Просмотры по дням, начиная с since
+func dailyStats(ctx context.Context, match bson.M, since string) ([]DayStat, error) {
+	dayMatch := bson.M{"day": bson.M{"$gte": since}}
+	for k, v := range match {
+		dayMatch[k] = v
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: dayMatch}},
+		{{Key: "$group", Value: bson.M{"_id": "$day", "views": bson.M{"$sum": "$views"}, "unique": bson.M{"$sum": "$unique"}}}},
+		{{Key: "$sort", Value: bson.M{"_id": -1}}},
+	}
+	var days []DayStat
+	cursor, err := GetCollection("paste_stats_daily").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &days)
+	return days, err
+}
+
+// Просмотры за всё время
+func totalStats(ctx context.Context, match bson.M) (DayStat, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "views": bson.M{"$sum": "$views"}, "unique": bson.M{"$sum": "$unique"}}}},
+	}
+	var rows []DayStat
+	cursor, err := GetCollection("paste_stats_daily").Aggregate(ctx, pipeline)
+	if err != nil {
+		return DayStat{}, err
+	}
+	if err := cursor.All(ctx, &rows); err != nil || len(rows) == 0 {
+		return DayStat{}, err
+	}
+	return rows[0], nil
+}
+
+func topReferrers(ctx context.Context, match bson.M, limit int64) ([]ReferrerStat, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": "$host", "count": bson.M{"$sum": "$count"}}}},
+		{{Key: "$sort", Value: bson.M{"count": -1}}},
+		{{Key: "$limit", Value: limit}},
+	}
+	var referrers []ReferrerStat
+	cursor, err := GetCollection("paste_referrers").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &referrers)
+	return referrers, err
+}
+
+func topPastesByViews(ctx context.Context, limit int64) ([]PasteViewStat, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$paste_id", "views": bson.M{"$sum": "$views"}, "unique": bson.M{"$sum": "$unique"}}}},
+		{{Key: "$sort", Value: bson.M{"views": -1}}},
+		{{Key: "$limit", Value: limit}},
+	}
+	var top []PasteViewStat
+	cursor, err := GetCollection("paste_stats_daily").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &top); err != nil {
+		return nil, err
+	}
+
+	// Подставляем заголовки паст
+	for i := range top {
+		var paste struct {
+			Title string `bson:"title"`
+		}
+		if err := GetCollection("pastes").FindOne(ctx, bson.M{"_id": top[i].PasteID}).Decode(&paste); err == nil {
+			top[i].Title = paste.Title
+		}
+	}
+	return top, nil
+}
+
+func loadStatsPage(ctx context.Context, title string, match bson.M) (statsPage, error) {
+	page := statsPage{Title: title}
+	var err error
+	since := time.Now().UTC().AddDate(0, 0, -statsDays+1).Format("2006-01-02")
+
+	if page.Total, err = totalStats(ctx, match); err != nil {
+		return page, err
+	}
+	if page.Daily, err = dailyStats(ctx, match, since); err != nil {
+		return page, err
+	}
+	if page.Referrers, err = topReferrers(ctx, match, 10); err != nil {
+		return page, err
+	}
+	return page, nil
+}
+
+// Статистика просмотров пасты для её владельца
+func PasteStatsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	pasteID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid paste ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var paste models.Paste
+	err = GetCollection("pastes").FindOne(ctx, bson.M{"_id": pasteID, "user_id": userID}).Decode(&paste)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Paste not found or access denied", http.StatusForbidden)
+		return
+	} else if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	page, err := loadStatsPage(ctx, paste.Title, bson.M{"paste_id": paste.ID})
+	if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to load stats")
+		return
+	}
+	page.PasteID = paste.ID.Hex()
+	render(w, r, "pastestats.html", page)
+}
+
+// Сводная статистика по всему сайту для администратора
+func AdminStatsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	page, err := loadStatsPage(ctx, "All pastes", bson.M{})
+	if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to load stats")
+		return
+	}
+	if page.TopPastes, err = topPastesByViews(ctx, 20); err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to load stats")
+		return
+	}
+	render(w, r, "pastestats.html", page)
+}
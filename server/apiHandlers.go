@@ -0,0 +1,511 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"pastebin/models"
+	"pastebin/utils"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Ограничение размера тела запроса API
+const apiMaxBodyBytes = 10 << 20
+
+// Заголовок с паролем защищённой пасты
+const pastePasswordHeader = "X-Paste-Password"
+
+// Размер страницы списка паст
+const (
+	apiDefaultPageSize = 20
+	apiMaxPageSize     = 100
+)
+
+// Паста в ответах API
+type APIPaste struct {
+	ID           string     `json:"id"`
+	URL          string     `json:"url"`
+	UserID       string     `json:"user_id"`
+	Title        string     `json:"title"`
+	Content      string     `json:"content,omitempty"` // В списках не передаётся
+	Language     string     `json:"language"`
+	Visibility   string     `json:"visibility"`
+	Revision     int        `json:"revision"`
+	Stars        int        `json:"stars"`
+	CurrentReads int32      `json:"current_reads"`
+	DeleteAfter  int32      `json:"delete_after"`
+	Protected    bool       `json:"password_protected"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	UpdatedBy    string     `json:"updated_by,omitempty"` // Автор последней ревизии, если известен
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+}
+
+// Страница списка паст
+type APIPasteList struct {
+	Pastes     []APIPaste `json:"pastes"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// Пользователь в ответах API
+type APIUser struct {
+	ID         string `json:"id"`
+	Email      string `json:"email"`
+	Name       string `json:"name"`
+	Role       string `json:"role"`
+	IsVerified bool   `json:"is_verified"`
+}
+
+// Единый формат ошибок API
+type APIError struct {
+	Error APIErrorBody `json:"error"`
+}
+
+type APIErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Изменяемые поля пасты; отсутствующие в запросе поля не трогаем
+type apiPasteUpdate struct {
+	Title      *string `json:"title"`
+	Content    *string `json:"content"`
+	Language   *string `json:"language"`
+	Visibility *string `json:"visibility"`
+	Expires    *string `json:"expires"`
+}
+
+func toAPIPaste(r *http.Request, p models.Paste) APIPaste {
+	out := APIPaste{
+		ID:           p.ID.Hex(),
+		URL:          pasteURL(r, p.ID),
+		UserID:       p.UserID.Hex(),
+		Title:        p.Title,
+		Content:      p.Content,
+		Language:     p.Language,
+		Visibility:   p.Visibility,
+		Revision:     p.Revision,
+		Stars:        p.Stars,
+		CurrentReads: p.CurrentReads,
+		DeleteAfter:  p.DeleteAfter,
+		Protected:    p.HasPassword(),
+		CreatedAt:    p.CreatedAt,
+		UpdatedAt:    p.UpdatedAt,
+	}
+	if out.Visibility == "" {
+		out.Visibility = models.VisibilityPublic
+	}
+	if !p.UpdatedBy.IsZero() {
+		out.UpdatedBy = p.UpdatedBy.Hex()
+	}
+	if !p.ExpiresAt.IsZero() {
+		expiresAt := p.ExpiresAt
+		out.ExpiresAt = &expiresAt
+	}
+	return out
+}
+
+func toAPIUser(u models.User) APIUser {
+	return APIUser{ID: u.ID.Hex(), Email: u.Email, Name: u.Name, Role: u.Role, IsVerified: u.IsVerified}
+}
+
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Ошибка кодирования JSON: %v", err)
+	}
+}
+
+func WriteAPIError(w http.ResponseWriter, status int, code, message string) {
+	WriteJSON(w, status, APIError{Error: APIErrorBody{Code: code, Message: message}})
+}
+
+// Разбираем JSON-тело запроса; при ошибке ответ уже отправлен
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, apiMaxBodyBytes)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		WriteAPIError(w, http.StatusBadRequest, "invalid_json", "Request body must be valid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// Паста, которой владеет вызывающий пользователь; при ошибке ответ уже отправлен
+func findOwnedPaste(w http.ResponseWriter, r *http.Request) (models.Paste, bool) {
+	return findAPIPaste(w, r, models.Paste.IsOwner)
+}
+
+// Паста, которую вызывающий пользователь может править
+func findEditablePaste(w http.ResponseWriter, r *http.Request) (models.Paste, bool) {
+	return findAPIPaste(w, r, models.Paste.CanEdit)
+}
+
+// Паста из URL, если allowed разрешает её вызывающему пользователю
+func findAPIPaste(w http.ResponseWriter, r *http.Request, allowed func(models.Paste, primitive.ObjectID) bool) (models.Paste, bool) {
+	var paste models.Paste
+	pasteID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		WriteAPIError(w, http.StatusBadRequest, "invalid_id", "Invalid paste ID")
+		return paste, false
+	}
+
+	err = GetCollection("pastes").FindOne(r.Context(), bson.M{"_id": pasteID}).Decode(&paste)
+	if err == mongo.ErrNoDocuments || (err == nil && paste.IsExpired(time.Now())) {
+		WriteAPIError(w, http.StatusNotFound, "not_found", "Paste not found")
+		return paste, false
+	} else if err != nil {
+		log.Printf("Ошибка загрузки пасты: %v", err)
+		WriteAPIError(w, http.StatusInternalServerError, "internal", "Database error")
+		return paste, false
+	}
+
+	if !allowed(paste, utils.UserIDFromContext(r.Context())) {
+		WriteAPIError(w, http.StatusForbidden, "forbidden", "Paste not found or access denied")
+		return paste, false
+	}
+	return paste, true
+}
+
+// POST /api/v1/pastes
+func APICreatePasteHandler(w http.ResponseWriter, r *http.Request) {
+	userID := utils.UserIDFromContext(r.Context())
+
+	w, finish, ok := beginIdempotent(w, r, userID, WriteAPIError)
+	if !ok {
+		return
+	}
+	defer finish()
+
+	var in pasteInput
+	if !decodeJSONBody(w, r, &in) {
+		return
+	}
+
+	paste, err := newPaste(userID, in, time.Now())
+	if err != nil {
+		WriteAPIError(w, http.StatusBadRequest, "invalid_paste", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	if err := insertPaste(ctx, paste); err != nil {
+		WriteAPIError(w, http.StatusInternalServerError, "internal", "Failed to save paste")
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/pastes/"+paste.ID.Hex())
+	WriteJSON(w, http.StatusCreated, toAPIPaste(r, paste))
+}
+
+// GET /api/v1/pastes/{id}
+func APIGetPasteHandler(w http.ResponseWriter, r *http.Request) {
+	pasteID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		WriteAPIError(w, http.StatusBadRequest, "invalid_id", "Invalid paste ID")
+		return
+	}
+
+	cached, err := readPaste(r.Context(), pasteID, utils.UserIDFromContext(r.Context()), r.Header.Get(pastePasswordHeader))
+	if err == errPasteNotFound {
+		WriteAPIError(w, http.StatusNotFound, "not_found", "Paste not found")
+		return
+	} else if err == errPasteLocked {
+		WriteAPIError(w, http.StatusForbidden, "password_required", "Paste is password protected; send the password in "+pastePasswordHeader)
+		return
+	} else if err != nil {
+		log.Printf("Ошибка загрузки пасты: %v", err)
+		WriteAPIError(w, http.StatusInternalServerError, "internal", "Database error")
+		return
+	}
+
+	if err := recordView(r.Context(), pasteID, r); err != nil {
+		log.Printf("Ошибка записи статистики просмотра: %v", err)
+	}
+
+	body, err := json.Marshal(toAPIPaste(r, cached.Paste))
+	if err != nil {
+		log.Printf("Ошибка кодирования JSON: %v", err)
+		WriteAPIError(w, http.StatusInternalServerError, "internal", "Failed to encode paste")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if writePasteValidators(w, r, cached.Paste, pasteRepJSON, body, true) {
+		return
+	}
+	w.Write(append(body, '\n'))
+}
+
+// GET /api/v1/pastes — свои пасты, ?public=true — публичные пасты всех пользователей,
+// ?shared=true — чужие пасты, открытые вызывающему пользователю.
+// Пагинация курсором: ?cursor=<next_cursor>&limit=N
+func APIListPastesHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := parsePageSize(query.Get("limit"), apiDefaultPageSize, apiMaxPageSize)
+
+	userID := utils.UserIDFromContext(r.Context())
+	filter := bson.M{"user_id": userID}
+	if query.Get("public") == "true" {
+		filter = publicPasteFilter()
+	} else if query.Get("shared") == "true" {
+		filter = bson.M{"shares.user_id": userID}
+	}
+	conditions := bson.A{filter, bson.M{"$or": bson.A{
+		bson.M{"expiresAt": bson.M{"$exists": false}},
+		bson.M{"expiresAt": bson.M{"$gt": time.Now()}},
+	}}}
+
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := decodeCursor(value)
+		if err != nil {
+			WriteAPIError(w, http.StatusBadRequest, "invalid_cursor", "Invalid cursor")
+			return
+		}
+		conditions = append(conditions, cursor.after(-1))
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit + 1)).
+		SetProjection(bson.M{"content": 0})
+	cursor, err := GetCollection("pastes").Find(ctx, bson.M{"$and": conditions}, findOptions)
+	if err != nil {
+		log.Printf("Error fetching pastes: %v\n", err)
+		WriteAPIError(w, http.StatusInternalServerError, "internal", "Failed to fetch pastes")
+		return
+	}
+	var pastes []models.Paste
+	if err := cursor.All(ctx, &pastes); err != nil {
+		log.Printf("Error decoding pastes: %v\n", err)
+		WriteAPIError(w, http.StatusInternalServerError, "internal", "Failed to decode pastes")
+		return
+	}
+
+	result := APIPasteList{Pastes: make([]APIPaste, 0, len(pastes))}
+	if len(pastes) > limit {
+		pastes = pastes[:limit]
+		last := pastes[limit-1]
+		result.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	for _, p := range pastes {
+		result.Pastes = append(result.Pastes, toAPIPaste(r, p))
+	}
+	WriteJSON(w, http.StatusOK, result)
+}
+
+// GET /api/v1/pastes/{id}/edit — паста для правки владельцем или соавтором.
+// Просмотр не засчитывается, поэтому сгорающая паста при этом не сгорает
+func APIGetEditablePasteHandler(w http.ResponseWriter, r *http.Request) {
+	paste, ok := findEditablePaste(w, r)
+	if !ok {
+		return
+	}
+	WriteJSON(w, http.StatusOK, toAPIPaste(r, paste))
+}
+
+// PATCH /api/v1/pastes/{id}. Соавторы меняют название, текст и язык,
+// видимость и срок жизни — только владелец
+func APIUpdatePasteHandler(w http.ResponseWriter, r *http.Request) {
+	paste, ok := findEditablePaste(w, r)
+	if !ok {
+		return
+	}
+	userID := utils.UserIDFromContext(r.Context())
+
+	var in apiPasteUpdate
+	if !decodeJSONBody(w, r, &in) {
+		return
+	}
+	if !paste.IsOwner(userID) && (in.Visibility != nil || in.Expires != nil) {
+		WriteAPIError(w, http.StatusForbidden, "forbidden", "Only the owner can change visibility and expiry")
+		return
+	}
+
+	now := time.Now()
+	set := bson.M{"updatedAt": now}
+	update := bson.M{"$set": set}
+	if in.Title != nil || in.Content != nil {
+		update["$inc"] = bson.M{"revision": 1}
+	}
+	if in.Title != nil {
+		set["title"] = *in.Title
+	}
+	if in.Content != nil {
+		if *in.Content == "" {
+			WriteAPIError(w, http.StatusBadRequest, "invalid_paste", "Content is required")
+			return
+		}
+		set["content"] = *in.Content
+	}
+	if in.Language != nil {
+		set["language"] = *in.Language
+	}
+	if in.Visibility != nil {
+		switch *in.Visibility {
+		case models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate:
+			set["visibility"] = *in.Visibility
+		default:
+			WriteAPIError(w, http.StatusBadRequest, "invalid_paste", "Invalid visibility")
+			return
+		}
+	}
+	if in.Expires != nil {
+		expiresAt, err := expiryTime(*in.Expires, now)
+		if err != nil {
+			WriteAPIError(w, http.StatusBadRequest, "invalid_paste", "Invalid expiry")
+			return
+		}
+		set["expires"] = *in.Expires
+		if expiresAt.IsZero() {
+			update["$unset"] = bson.M{"expiresAt": ""}
+		} else {
+			set["expiresAt"] = expiresAt
+		}
+	}
+
+	if in.Title != nil || in.Content != nil {
+		setRevisionAuthor(r.Context(), update, userID)
+	}
+
+	var updated models.Paste
+	err := GetCollection("pastes").FindOneAndUpdate(
+		r.Context(),
+		editablePasteFilter(paste.ID, userID),
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		log.Printf("Database update error for paste ID=%s: %v\n", paste.ID.Hex(), err)
+		WriteAPIError(w, http.StatusInternalServerError, "internal", "Failed to update paste")
+		return
+	}
+	events.Publish(PasteUpdated{Paste: updated})
+	WriteJSON(w, http.StatusOK, toAPIPaste(r, updated))
+}
+
+// DELETE /api/v1/pastes/{id}
+func APIDeletePasteHandler(w http.ResponseWriter, r *http.Request) {
+	paste, ok := findOwnedPaste(w, r)
+	if !ok {
+		return
+	}
+
+	// Паста могла исчезнуть между проверкой и удалением: событие только
+	// о том, что удалили сами
+	var deleted models.Paste
+	err := GetCollection("pastes").FindOneAndDelete(r.Context(), bson.M{"_id": paste.ID, "user_id": paste.UserID}).Decode(&deleted)
+	if err == mongo.ErrNoDocuments {
+		WriteAPIError(w, http.StatusNotFound, "not_found", "Paste not found")
+		return
+	} else if err != nil {
+		log.Printf("Failed to delete paste with ID %s: %v", paste.ID.Hex(), err)
+		WriteAPIError(w, http.StatusInternalServerError, "internal", "Failed to delete paste")
+		return
+	}
+	events.Publish(PasteDeleted{Paste: deleted})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Сообщение чата в ответах API
+type APIChatMessage struct {
+	Sender    string    `json:"sender"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type apiChatMessageInput struct {
+	Content string `json:"content"`
+}
+
+type APIChat struct {
+	ID       string           `json:"id"`
+	Messages []APIChatMessage `json:"messages"`
+}
+
+// Активный чат вызывающего пользователя; при ошибке ответ уже отправлен
+func findActiveChat(w http.ResponseWriter, r *http.Request) (models.Chat, bool) {
+	var chat models.Chat
+	err := GetCollection("chats").FindOne(r.Context(), bson.M{
+		"user_id": utils.UserIDFromContext(r.Context()),
+		"status":  "active",
+	}).Decode(&chat)
+	if err == mongo.ErrNoDocuments {
+		WriteAPIError(w, http.StatusNotFound, "not_found", "No active chat")
+		return chat, false
+	} else if err != nil {
+		log.Println("Ошибка получения чата:", err)
+		WriteAPIError(w, http.StatusInternalServerError, "internal", "Database error")
+		return chat, false
+	}
+	return chat, true
+}
+
+// GET /api/v1/chat
+func APIGetChatHandler(w http.ResponseWriter, r *http.Request) {
+	chat, ok := findActiveChat(w, r)
+	if !ok {
+		return
+	}
+	result := APIChat{ID: chat.ID.Hex(), Messages: make([]APIChatMessage, 0, len(chat.Messages))}
+	for _, m := range chat.Messages {
+		result.Messages = append(result.Messages, APIChatMessage{Sender: m.Sender, Content: m.Content, Timestamp: m.Timestamp})
+	}
+	WriteJSON(w, http.StatusOK, result)
+}
+
+// POST /api/v1/chat/messages — сообщение в активный чат от имени пользователя
+func APIPostChatMessageHandler(w http.ResponseWriter, r *http.Request) {
+	chat, ok := findActiveChat(w, r)
+	if !ok {
+		return
+	}
+	var in apiChatMessageInput
+	if !decodeJSONBody(w, r, &in) {
+		return
+	}
+	if in.Content == "" {
+		WriteAPIError(w, http.StatusBadRequest, "invalid_message", "Content is required")
+		return
+	}
+
+	message := models.Message{Sender: "user", Content: in.Content, Timestamp: time.Now()}
+	_, err := GetCollection("chats").UpdateOne(r.Context(),
+		bson.M{"_id": chat.ID},
+		bson.M{"$push": bson.M{"messages": message}},
+	)
+	if err != nil {
+		log.Println("Ошибка сохранения сообщения:", err)
+		WriteAPIError(w, http.StatusInternalServerError, "internal", "Failed to save message")
+		return
+	}
+	events.Publish(ChatMessagePosted{Chat: chat, Message: message})
+	WriteJSON(w, http.StatusCreated, APIChatMessage{Sender: message.Sender, Content: message.Content, Timestamp: message.Timestamp})
+}
+
+// GET /api/v1/me — владелец токена
+func APIMeHandler(w http.ResponseWriter, r *http.Request) {
+	var user models.User
+	err := GetCollection("users").FindOne(r.Context(), bson.M{"_id": utils.UserIDFromContext(r.Context())}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		WriteAPIError(w, http.StatusNotFound, "not_found", "User not found")
+		return
+	} else if err != nil {
+		log.Printf("Ошибка загрузки пользователя: %v", err)
+		WriteAPIError(w, http.StatusInternalServerError, "internal", "Database error")
+		return
+	}
+	WriteJSON(w, http.StatusOK, toAPIUser(user))
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pastebin/utils"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 30, 0, 123e6, time.UTC)
+	id := primitive.NewObjectID()
+
+	got, err := decodeCursor(encodeCursor(createdAt, id))
+	if err != nil {
+		t.Fatalf("Ошибка декодирования курсора: %v", err)
+	}
+	if !got.CreatedAt.Equal(createdAt) || got.ID != id {
+		t.Errorf("Курсор изменился: %+v", got)
+	}
+
+	for _, bad := range []string{"", "!!!", "bm90LWEtY3Vyc29y", encodeCursor(createdAt, id)[:5]} {
+		if _, err := decodeCursor(bad); err == nil {
+			t.Errorf("Курсор %q должен быть отклонён", bad)
+		}
+	}
+}
+
+func TestParsePageSize(t *testing.T) {
+	cases := map[string]int{"": 20, "abc": 20, "0": 20, "-5": 20, "7": 7, "1000": 100}
+	for value, want := range cases {
+		if got := parsePageSize(value, 20, 100); got != want {
+			t.Errorf("parsePageSize(%q) = %d, ожидалось %d", value, got, want)
+		}
+	}
+}
+
+func TestWriteAPIError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteAPIError(rec, http.StatusNotFound, "not_found", "Paste not found")
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Неверный статус: %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Неверный Content-Type: %q", ct)
+	}
+	var body APIError
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Тело не JSON: %v", err)
+	}
+	if body.Error.Code != "not_found" || body.Error.Message != "Paste not found" {
+		t.Errorf("Неверное тело ошибки: %+v", body)
+	}
+}
+
+func TestAPICreateRejectsInvalidJSON(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/pastes", strings.NewReader(`{"content": 1}`))
+	rec := httptest.NewRecorder()
+	APICreatePasteHandler(rec, req)
+
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid_json") {
+		t.Errorf("Ожидалась ошибка invalid_json, получено %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAPIDeletePaste(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	pasteID, owner := primitive.NewObjectID(), primitive.NewObjectID()
+	paste := bson.D{{Key: "_id", Value: pasteID}, {Key: "user_id", Value: owner}, {Key: "title", Value: "mine"}}
+
+	deletePaste := func(mt *mtest.T, deleted interface{}) (*httptest.ResponseRecorder, []Event) {
+		useTestDB(mt)
+		var published []Event
+		old := events
+		events = NewEventBus()
+		events.Subscribe("test", 0, Inline, func(e Event) { published = append(published, e) })
+		mt.Cleanup(func() { events = old })
+
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "pastebin.pastes", mtest.FirstBatch, paste),
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: deleted}),
+		)
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/pastes/"+pasteID.Hex(), nil)
+		req = mux.SetURLVars(req, map[string]string{"id": pasteID.Hex()})
+		req = req.WithContext(utils.ContextWithUserID(req.Context(), owner))
+		rec := httptest.NewRecorder()
+		APIDeletePasteHandler(rec, req)
+		return rec, published
+	}
+
+	mt.Run("удаляет", func(mt *mtest.T) {
+		rec, published := deletePaste(mt, paste)
+		if rec.Code != http.StatusNoContent || len(published) != 1 {
+			mt.Errorf("статус %d, событий %d", rec.Code, len(published))
+		}
+	})
+
+	mt.Run("паста исчезла до удаления", func(mt *mtest.T) {
+		rec, published := deletePaste(mt, nil)
+		if rec.Code != http.StatusNotFound || len(published) != 0 {
+			mt.Errorf("статус %d, событий %d", rec.Code, len(published))
+		}
+	})
+}
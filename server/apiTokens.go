@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"pastebin/models"
+	"pastebin/utils"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Как часто обновляем lastUsedAt, чтобы не писать в базу на каждый запрос
+const tokenUsageResolution = time.Minute
+
+var errInvalidAPIToken = errors.New("Invalid, expired or revoked API token")
+
+// Новое случайное значение токена
+func generateAPIToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return models.APITokenPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Токен случайный и длинный, поэтому достаточно SHA-256 без соли
+func hashAPIToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// Проверяем API-токен из заголовка Authorization и отмечаем его использование
+func AuthenticateAPIToken(ctx context.Context, raw string) (models.APIToken, error) {
+	var token models.APIToken
+	err := GetCollection("api_tokens").FindOne(ctx, bson.M{"hash": hashAPIToken(raw)}).Decode(&token)
+	if err == mongo.ErrNoDocuments {
+		return token, errInvalidAPIToken
+	} else if err != nil {
+		return token, err
+	}
+
+	now := time.Now()
+	if !token.IsActive(now) {
+		return token, errInvalidAPIToken
+	}
+
+	if now.Sub(token.LastUsedAt) >= tokenUsageResolution {
+		_, err := GetCollection("api_tokens").UpdateOne(ctx,
+			bson.M{"_id": token.ID},
+			bson.M{"$set": bson.M{"lastUsedAt": now}},
+		)
+		if err != nil {
+			log.Printf("Ошибка обновления lastUsedAt токена %s: %v", token.ID.Hex(), err)
+		}
+	}
+	return token, nil
+}
+
+// Токены пользователя для страницы профиля, новые сверху
+func listAPITokens(ctx context.Context, userID primitive.ObjectID) ([]models.APIToken, error) {
+	cursor, err := GetCollection("api_tokens").Find(ctx,
+		bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetProjection(bson.M{"hash": 0}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []models.APIToken
+	if err := cursor.All(ctx, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Оставляем только известные права, без повторов
+func parseScopes(values []string) []string {
+	var scopes []string
+	for _, scope := range models.Scopes {
+		for _, v := range values {
+			if v == scope {
+				scopes = append(scopes, scope)
+				break
+			}
+		}
+	}
+	return scopes
+}
+
+// POST /profile/tokens — создаём токен и показываем его значение один раз
+func CreateAPITokenHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetUserIDFromToken(r)
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		setFlash(w, "Token name is required")
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+		return
+	}
+	scopes := parseScopes(r.Form["scopes"])
+	if len(scopes) == 0 {
+		setFlash(w, "Select at least one scope")
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+		return
+	}
+	now := time.Now()
+	expiresAt, err := expiryTime(r.FormValue("expires"), now)
+	if err != nil {
+		setFlash(w, "Invalid expiry")
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+		return
+	}
+
+	raw, err := generateAPIToken()
+	if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+	token := models.APIToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Name:      name,
+		Hash:      hashAPIToken(raw),
+		Prefix:    raw[:len(models.APITokenPrefix)+6],
+		Scopes:    scopes,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	}
+	if _, err := GetCollection("api_tokens").InsertOne(r.Context(), token); err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to save token")
+		return
+	}
+
+	// Значение токена больше нигде не сохраняется — страницу не кэшируем
+	w.Header().Set("Cache-Control", "no-store")
+	render(w, r, "apitoken.html", struct {
+		Token models.APIToken
+		Value string
+	}{
+		Token: token,
+		Value: raw,
+	})
+}
+
+// POST /profile/tokens/{id}/revoke
+func RevokeAPITokenHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetUserIDFromToken(r)
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	tokenID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := GetCollection("api_tokens").UpdateOne(r.Context(),
+		bson.M{"_id": tokenID, "user_id": userID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to revoke token")
+		return
+	}
+	if result.MatchedCount == 0 {
+		http.Error(w, "Token not found or access denied", http.StatusForbidden)
+		return
+	}
+
+	setFlash(w, "Token revoked")
+	http.Redirect(w, r, "/profile", http.StatusSeeOther)
+}
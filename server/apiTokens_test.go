@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"pastebin/models"
+	"pastebin/utils"
+)
+
+func TestGenerateAPIToken(t *testing.T) {
+	a, err := generateAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := generateAPIToken()
+	if !strings.HasPrefix(a, models.APITokenPrefix) || a == b {
+		t.Errorf("Неверные токены: %q, %q", a, b)
+	}
+	if hashAPIToken(a) != hashAPIToken(a) || hashAPIToken(a) == hashAPIToken(b) || strings.Contains(hashAPIToken(a), a) {
+		t.Error("Хэш токена должен быть детерминированным и не содержать значение")
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	got := parseScopes([]string{"chat", "admin", "pastes:read", "chat"})
+	want := []string{models.ScopePastesRead, models.ScopeChat}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseScopes = %v, ожидалось %v", got, want)
+	}
+}
+
+func TestAPITokenActiveAndScopes(t *testing.T) {
+	now := time.Now()
+	token := models.APIToken{Scopes: []string{models.ScopePastesRead}}
+	if !token.IsActive(now) {
+		t.Error("Бессрочный токен должен быть активен")
+	}
+	token.ExpiresAt = now.Add(-time.Second)
+	if token.IsActive(now) {
+		t.Error("Истёкший токен активен")
+	}
+	token.ExpiresAt = time.Time{}
+	token.RevokedAt = now
+	if token.IsActive(now) {
+		t.Error("Отозванный токен активен")
+	}
+
+	ctx := context.Background()
+	if !utils.HasScope(ctx, models.ScopePastesWrite) {
+		t.Error("Вход по логину не должен ограничиваться правами")
+	}
+	ctx = utils.ContextWithScopes(ctx, token.Scopes)
+	if !utils.HasScope(ctx, models.ScopePastesRead) || utils.HasScope(ctx, models.ScopePastesWrite) {
+		t.Error("Права токена проверяются неверно")
+	}
+}
@@ -0,0 +1,305 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"pastebin/models"
+	"pastebin/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Сколько паст можно создать или изменить одним запросом
+const bulkMaxItems = 100
+
+// Действия над несколькими своими пастами
+const (
+	bulkActionDelete     = "delete"
+	bulkActionExpire     = "expire"
+	bulkActionVisibility = "visibility"
+)
+
+// Тело POST /api/v1/pastes/batch
+type apiBatchCreate struct {
+	Pastes []pasteInput `json:"pastes"`
+}
+
+// Тело POST /api/v1/pastes/bulk
+type apiBulkAction struct {
+	Action     string   `json:"action"`
+	IDs        []string `json:"ids"`
+	Expires    string   `json:"expires,omitempty"`    // Для action=expire; "never" снимает срок
+	Visibility string   `json:"visibility,omitempty"` // Для action=visibility
+}
+
+// Результат для одного элемента; Index — позиция в запросе
+type APIBulkItem struct {
+	Index  int           `json:"index"`
+	ID     string        `json:"id,omitempty"`
+	Status int           `json:"status"`
+	Paste  *APIPaste     `json:"paste,omitempty"`
+	Error  *APIErrorBody `json:"error,omitempty"`
+}
+
+// Отчёт о пакетной операции; элементы выполняются независимо
+type APIBulkReport struct {
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Results   []APIBulkItem `json:"results"`
+}
+
+func (report *APIBulkReport) ok(item APIBulkItem) {
+	report.Succeeded++
+	report.Results = append(report.Results, item)
+}
+
+func (report *APIBulkReport) fail(item APIBulkItem, status int, code, message string) {
+	item.Status = status
+	item.Error = &APIErrorBody{Code: code, Message: message}
+	report.Failed++
+	report.Results = append(report.Results, item)
+}
+
+// Создаём пасты пачкой. Неверные элементы пропускаются, остальные
+// сохраняются одной вставкой
+func createPasteBatch(r *http.Request, userID primitive.ObjectID, inputs []pasteInput) APIBulkReport {
+	report := APIBulkReport{Results: make([]APIBulkItem, 0, len(inputs))}
+	now := time.Now()
+
+	var pastes []models.Paste
+	var indexes []int
+	invalid := make(map[int]error)
+	for i, in := range inputs {
+		paste, err := newPaste(userID, in, now)
+		if err != nil {
+			invalid[i] = err
+			continue
+		}
+		pastes = append(pastes, paste)
+		indexes = append(indexes, i)
+	}
+
+	// Ошибки вставки по позиции в pastes
+	failed := make(map[int]bool)
+	if len(pastes) > 0 {
+		docs := make([]interface{}, len(pastes))
+		for i := range pastes {
+			docs[i] = pastes[i]
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+		_, err := GetCollection("pastes").InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+		var bulkErr mongo.BulkWriteException
+		if errors.As(err, &bulkErr) {
+			for _, writeErr := range bulkErr.WriteErrors {
+				failed[writeErr.Index] = true
+			}
+			if bulkErr.WriteConcernError != nil {
+				log.Printf("Ошибка пакетного сохранения паст: %v", err)
+			}
+		} else if err != nil {
+			log.Printf("Ошибка пакетного сохранения паст: %v", err)
+			for i := range pastes {
+				failed[i] = true
+			}
+		}
+	}
+
+	created := make(map[int]models.Paste)
+	for i, paste := range pastes {
+		if failed[i] {
+			continue
+		}
+		created[indexes[i]] = paste
+		events.Publish(PasteCreated{Paste: paste})
+	}
+
+	for i := range inputs {
+		item := APIBulkItem{Index: i}
+		if err, ok := invalid[i]; ok {
+			report.fail(item, http.StatusBadRequest, "invalid_paste", err.Error())
+			continue
+		}
+		paste, ok := created[i]
+		if !ok {
+			report.fail(item, http.StatusInternalServerError, "internal", "Failed to save paste")
+			continue
+		}
+		out := toAPIPaste(r, paste)
+		item.ID, item.Status, item.Paste = out.ID, http.StatusCreated, &out
+		report.ok(item)
+	}
+	return report
+}
+
+// Проверяем действие и собираем изменение для expire и visibility
+func bulkUpdate(in apiBulkAction, now time.Time) (bson.M, error) {
+	switch in.Action {
+	case bulkActionDelete:
+		return nil, nil
+	case bulkActionExpire:
+		expiresAt, err := expiryTime(in.Expires, now)
+		if err != nil || in.Expires == "" {
+			return nil, errors.New("Invalid expiry")
+		}
+		set := bson.M{"expires": in.Expires, "updatedAt": now}
+		if expiresAt.IsZero() {
+			return bson.M{"$set": set, "$unset": bson.M{"expiresAt": ""}}, nil
+		}
+		set["expiresAt"] = expiresAt
+		return bson.M{"$set": set}, nil
+	case bulkActionVisibility:
+		switch in.Visibility {
+		case models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate:
+			return bson.M{"$set": bson.M{"visibility": in.Visibility, "updatedAt": now}}, nil
+		}
+		return nil, errors.New("Invalid visibility")
+	}
+	return nil, fmt.Errorf("Unknown action %q", in.Action)
+}
+
+// Удаляем или меняем свои пасты по одной, чтобы чужие, несуществующие и
+// истёкшие попали в отчёт. Ошибка — запрос неверен целиком
+func applyBulkAction(r *http.Request, userID primitive.ObjectID, in apiBulkAction) (APIBulkReport, error) {
+	now := time.Now()
+	update, err := bulkUpdate(in, now)
+	if err != nil {
+		return APIBulkReport{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	collection := GetCollection("pastes")
+	report := APIBulkReport{Results: make([]APIBulkItem, 0, len(in.IDs))}
+	for i, hex := range in.IDs {
+		item := APIBulkItem{Index: i, ID: hex}
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			report.fail(item, http.StatusBadRequest, "invalid_id", "Invalid paste ID")
+			continue
+		}
+		filter := bson.M{
+			"_id":     id,
+			"user_id": userID,
+			"$or":     bson.A{bson.M{"expiresAt": bson.M{"$exists": false}}, bson.M{"expiresAt": bson.M{"$gt": now}}},
+		}
+
+		var paste models.Paste
+		if in.Action == bulkActionDelete {
+			err = collection.FindOneAndDelete(ctx, filter).Decode(&paste)
+		} else {
+			err = collection.FindOneAndUpdate(ctx, filter, update,
+				options.FindOneAndUpdate().SetReturnDocument(options.After),
+			).Decode(&paste)
+		}
+		if err == mongo.ErrNoDocuments {
+			report.fail(item, http.StatusNotFound, "not_found", "Paste not found or access denied")
+			continue
+		} else if err != nil {
+			log.Printf("Ошибка пакетной операции %s над пастой %s: %v", in.Action, hex, err)
+			report.fail(item, http.StatusInternalServerError, "internal", "Database error")
+			continue
+		}
+
+		if in.Action == bulkActionDelete {
+			events.Publish(PasteDeleted{Paste: paste})
+			item.Status = http.StatusNoContent
+		} else {
+			events.Publish(PasteUpdated{Paste: paste})
+			out := toAPIPaste(r, paste)
+			item.Status, item.Paste = http.StatusOK, &out
+		}
+		report.ok(item)
+	}
+	return report, nil
+}
+
+// POST /api/v1/pastes/batch
+func APIBatchCreatePastesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := utils.UserIDFromContext(r.Context())
+
+	w, finish, ok := beginIdempotent(w, r, userID, WriteAPIError)
+	if !ok {
+		return
+	}
+	defer finish()
+
+	var in apiBatchCreate
+	if !decodeJSONBody(w, r, &in) {
+		return
+	}
+	if len(in.Pastes) == 0 || len(in.Pastes) > bulkMaxItems {
+		WriteAPIError(w, http.StatusBadRequest, "invalid_batch", fmt.Sprintf("Send between 1 and %d pastes", bulkMaxItems))
+		return
+	}
+	WriteJSON(w, http.StatusOK, createPasteBatch(r, userID, in.Pastes))
+}
+
+// POST /api/v1/pastes/bulk
+func APIBulkPastesHandler(w http.ResponseWriter, r *http.Request) {
+	var in apiBulkAction
+	if !decodeJSONBody(w, r, &in) {
+		return
+	}
+	if len(in.IDs) == 0 || len(in.IDs) > bulkMaxItems {
+		WriteAPIError(w, http.StatusBadRequest, "invalid_batch", fmt.Sprintf("Send between 1 and %d paste IDs", bulkMaxItems))
+		return
+	}
+	report, err := applyBulkAction(r, utils.UserIDFromContext(r.Context()), in)
+	if err != nil {
+		WriteAPIError(w, http.StatusBadRequest, "invalid_action", err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusOK, report)
+}
+
+// Массовые действия с отмеченными пастами на странице профиля
+func BulkPastesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		HandleError(w, err, http.StatusBadRequest, "Failed to parse form data")
+		return
+	}
+
+	in := apiBulkAction{
+		Action:     r.FormValue("action"),
+		IDs:        r.Form["ids"],
+		Expires:    r.FormValue("expires"),
+		Visibility: r.FormValue("visibility"),
+	}
+	if len(in.IDs) == 0 {
+		setFlash(w, "Select at least one paste")
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+		return
+	}
+	if len(in.IDs) > bulkMaxItems {
+		http.Error(w, fmt.Sprintf("At most %d pastes at once", bulkMaxItems), http.StatusBadRequest)
+		return
+	}
+
+	report, err := applyBulkAction(r, userID, in)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	message := fmt.Sprintf("Updated %d paste(s)", report.Succeeded)
+	if in.Action == bulkActionDelete {
+		message = fmt.Sprintf("Deleted %d paste(s)", report.Succeeded)
+	}
+	if report.Failed > 0 {
+		message += fmt.Sprintf(", %d failed", report.Failed)
+	}
+	setFlash(w, message)
+	http.Redirect(w, r, "/profile", http.StatusSeeOther)
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBulkUpdate(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	update, err := bulkUpdate(apiBulkAction{Action: bulkActionExpire, Expires: "1day"}, now)
+	if err != nil || !update["$set"].(bson.M)["expiresAt"].(time.Time).Equal(now.AddDate(0, 0, 1)) {
+		t.Errorf("expire 1day = %v, %v", update, err)
+	}
+	update, err = bulkUpdate(apiBulkAction{Action: bulkActionExpire, Expires: "never"}, now)
+	if err != nil || update["$unset"] == nil {
+		t.Errorf("expire never = %v, %v", update, err)
+	}
+	update, err = bulkUpdate(apiBulkAction{Action: bulkActionVisibility, Visibility: "private"}, now)
+	if err != nil || update["$set"].(bson.M)["visibility"] != "private" {
+		t.Errorf("visibility = %v, %v", update, err)
+	}
+	if update, err := bulkUpdate(apiBulkAction{Action: bulkActionDelete}, now); err != nil || update != nil {
+		t.Errorf("delete = %v, %v", update, err)
+	}
+
+	for _, bad := range []apiBulkAction{
+		{Action: "archive"},
+		{Action: bulkActionExpire},
+		{Action: bulkActionExpire, Expires: "forever"},
+		{Action: bulkActionVisibility, Visibility: "secret"},
+	} {
+		if _, err := bulkUpdate(bad, now); err == nil {
+			t.Errorf("%+v: ожидалась ошибка", bad)
+		}
+	}
+}
+
+func TestCreatePasteBatchReportsInvalidItems(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/v1/pastes/batch", nil)
+	report := createPasteBatch(r, primitive.NewObjectID(), []pasteInput{
+		{Title: "empty"},
+		{Content: "x", Visibility: "secret"},
+	})
+	if report.Succeeded != 0 || report.Failed != 2 || len(report.Results) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	for i, item := range report.Results {
+		if item.Index != i || item.Status != http.StatusBadRequest || item.Error == nil || item.Error.Code != "invalid_paste" {
+			t.Errorf("item %d = %+v", i, item)
+		}
+	}
+}
+
+func TestBulkHandlersRejectBatchSize(t *testing.T) {
+	tooMany := make([]string, bulkMaxItems+1)
+	body, _ := json.Marshal(apiBulkAction{Action: bulkActionDelete, IDs: tooMany})
+	rec := httptest.NewRecorder()
+	APIBulkPastesHandler(rec, httptest.NewRequest("POST", "/api/v1/pastes/bulk", strings.NewReader(string(body))))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("bulk: статус %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	APIBatchCreatePastesHandler(rec, httptest.NewRequest("POST", "/api/v1/pastes/batch", strings.NewReader(`{"pastes": []}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("batch: статус %d", rec.Code)
+	}
+}
@@ -0,0 +1,294 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"pastebin/models"
+	"pastebin/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Строка пасты с номером для отображения и якоря комментариев
+type PasteLine struct {
+	Number int
+	Text   string
+}
+
+// Комментарий для шаблона: с признаком устаревания и ответами
+type CommentView struct {
+	models.Comment
+	Outdated bool
+	IsAuthor bool
+	Replies  []*CommentView
+}
+
+func pasteLines(content string) []PasteLine {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	parts := strings.Split(content, "\n")
+	lines := make([]PasteLine, len(parts))
+	for i, text := range parts {
+		lines[i] = PasteLine{Number: i + 1, Text: text}
+	}
+	return lines
+}
+
+// Имя автора для подписи комментария: Name, если заполнено, иначе email
+func displayName(ctx context.Context, userID primitive.ObjectID) string {
+	var user models.User
+	if err := db.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return "unknown"
+	}
+	if user.Name != "" {
+		return user.Name
+	}
+	return user.Email
+}
+
+// Загружаем комментарии пасты и собираем их в треды
+func loadCommentThreads(ctx context.Context, paste models.Paste, viewerID primitive.ObjectID) ([]*CommentView, error) {
+	findOptions := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := GetCollection("comments").Find(ctx, bson.M{"paste_id": paste.ID}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var comments []models.Comment
+	if err := cursor.All(ctx, &comments); err != nil {
+		return nil, err
+	}
+	return commentThreads(comments, paste, viewerID), nil
+}
+
+// Собираем комментарии, отсортированные по времени, в треды. Ответ на
+// неизвестный комментарий показывается отдельным тредом
+func commentThreads(comments []models.Comment, paste models.Paste, viewerID primitive.ObjectID) []*CommentView {
+	byID := make(map[primitive.ObjectID]*CommentView, len(comments))
+	views := make([]*CommentView, 0, len(comments))
+	for _, c := range comments {
+		view := &CommentView{
+			Comment:  c,
+			Outdated: c.Revision < paste.Revision,
+			IsAuthor: !viewerID.IsZero() && c.UserID == viewerID,
+		}
+		byID[c.ID] = view
+		views = append(views, view)
+	}
+
+	var threads []*CommentView
+	for _, view := range views {
+		if parent, ok := byID[view.ParentID]; ok && !view.ParentID.IsZero() {
+			parent.Replies = append(parent.Replies, view)
+			continue
+		}
+		threads = append(threads, view)
+	}
+	return threads
+}
+
+// Комментарии удалённой или истёкшей пасты
+func deletePasteComments(ctx context.Context, pasteID primitive.ObjectID) error {
+	_, err := GetCollection("comments").DeleteMany(ctx, bson.M{"paste_id": pasteID})
+	return err
+}
+
+// Добавление комментария к строкам пасты или ответа на комментарий
+func CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	pasteID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid paste ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// Комментировать можно только то, что можно прочитать. Прочтение при
+	// этом не засчитывается, иначе комментарий сжигал бы пасту с лимитом
+	var paste models.Paste
+	err = GetCollection("pastes").FindOne(ctx, bson.M{"_id": pasteID}).Decode(&paste)
+	if err == nil {
+		err = checkPasteAccess(ctx, paste, userID, r.PostFormValue("password"))
+	}
+	if err == mongo.ErrNoDocuments || err == errPasteNotFound {
+		http.Error(w, "Paste not found", http.StatusNotFound)
+		return
+	} else if err == errPasteLocked {
+		http.Error(w, "Wrong paste password", http.StatusForbidden)
+		return
+	} else if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if paste.CommentsDisabled {
+		http.Error(w, "Comments are disabled for this paste", http.StatusForbidden)
+		return
+	}
+
+	content := strings.TrimSpace(r.FormValue("content"))
+	if content == "" {
+		http.Error(w, "Content is required", http.StatusBadRequest)
+		return
+	}
+
+	comment := models.Comment{
+		ID:         primitive.NewObjectID(),
+		PasteID:    paste.ID,
+		UserID:     userID,
+		AuthorName: displayName(ctx, userID),
+		Revision:   paste.Revision,
+		Content:    content,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+
+	if parentHex := r.FormValue("parent_id"); parentHex != "" {
+		// Ответ наследует строки родительского комментария
+		parentID, err := primitive.ObjectIDFromHex(parentHex)
+		if err != nil {
+			http.Error(w, "Invalid parent ID", http.StatusBadRequest)
+			return
+		}
+		var parent models.Comment
+		err = GetCollection("comments").FindOne(ctx, bson.M{"_id": parentID, "paste_id": paste.ID}).Decode(&parent)
+		if err != nil {
+			http.Error(w, "Parent comment not found", http.StatusNotFound)
+			return
+		}
+		comment.ParentID = parent.ID
+		comment.LineStart = parent.LineStart
+		comment.LineEnd = parent.LineEnd
+		comment.Revision = parent.Revision
+	} else {
+		lineStart, err1 := strconv.Atoi(r.FormValue("line_start"))
+		lineEnd, err2 := strconv.Atoi(r.FormValue("line_end"))
+		if err1 != nil {
+			http.Error(w, "Invalid line number", http.StatusBadRequest)
+			return
+		}
+		if err2 != nil || lineEnd == 0 {
+			lineEnd = lineStart
+		}
+		if lineStart < 1 || lineEnd < lineStart || lineEnd > len(pasteLines(paste.Content)) {
+			http.Error(w, "Line range is out of bounds", http.StatusBadRequest)
+			return
+		}
+		comment.LineStart = lineStart
+		comment.LineEnd = lineEnd
+	}
+
+	if _, err := GetCollection("comments").InsertOne(ctx, comment); err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to save comment")
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/paste/%s#comment-%s", paste.ID.Hex(), comment.ID.Hex()), http.StatusSeeOther)
+}
+
+// Редактирование комментария его автором
+func EditCommentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	commentID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+		return
+	}
+
+	content := strings.TrimSpace(r.FormValue("content"))
+	if content == "" {
+		http.Error(w, "Content is required", http.StatusBadRequest)
+		return
+	}
+
+	var comment models.Comment
+	err = GetCollection("comments").FindOneAndUpdate(
+		r.Context(),
+		bson.M{"_id": commentID, "user_id": userID, "deleted": false},
+		bson.M{"$set": bson.M{"content": content, "updatedAt": time.Now()}},
+	).Decode(&comment)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Comment not found or access denied", http.StatusForbidden)
+		return
+	} else if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to update comment")
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/paste/%s#comment-%s", comment.PasteID.Hex(), comment.ID.Hex()), http.StatusSeeOther)
+}
+
+// Удаление комментария его автором. Текст стирается, но запись остаётся,
+// чтобы не потерять ответы в треде
+func DeleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	commentID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := GetCollection("comments").UpdateOne(
+		r.Context(),
+		bson.M{"_id": commentID, "user_id": userID},
+		bson.M{"$set": bson.M{"deleted": true, "content": "", "updatedAt": time.Now()}},
+	)
+	if err != nil || result.MatchedCount == 0 {
+		http.Error(w, "Comment not found or unauthorized", http.StatusForbidden)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Владелец включает или отключает комментарии к своей пасте
+func ToggleCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	pasteID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid paste ID", http.StatusBadRequest)
+		return
+	}
+
+	disabled := r.FormValue("disabled") == "true"
+	result, err := GetCollection("pastes").UpdateOne(
+		r.Context(),
+		bson.M{"_id": pasteID, "user_id": userID},
+		bson.M{"$set": bson.M{"commentsDisabled": disabled}},
+	)
+	if err != nil || result.MatchedCount == 0 {
+		http.Error(w, "Paste not found or unauthorized", http.StatusForbidden)
+		return
+	}
+	hotPastes.Invalidate(pasteID)
+
+	http.Redirect(w, r, fmt.Sprintf("/paste/%s", pasteID.Hex()), http.StatusSeeOther)
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"pastebin/models"
+	"pastebin/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPasteLines(t *testing.T) {
+	lines := pasteLines("first\r\nsecond\n\nlast")
+	if len(lines) != 4 {
+		t.Fatalf("ожидалось 4 строки, получено %d", len(lines))
+	}
+	for i, want := range []string{"first", "second", "", "last"} {
+		if lines[i].Number != i+1 || lines[i].Text != want {
+			t.Errorf("строка %d: %+v", i+1, lines[i])
+		}
+	}
+	if lines := pasteLines(""); len(lines) != 1 || lines[0].Number != 1 {
+		t.Errorf("пустая паста: %+v", lines)
+	}
+}
+
+func TestCommentThreads(t *testing.T) {
+	author, viewer := primitive.NewObjectID(), primitive.NewObjectID()
+	paste := models.Paste{ID: primitive.NewObjectID(), Revision: 2}
+	now := time.Now()
+	comment := func(parent primitive.ObjectID, user primitive.ObjectID, revision int) models.Comment {
+		now = now.Add(time.Second)
+		return models.Comment{ID: primitive.NewObjectID(), PasteID: paste.ID, ParentID: parent, UserID: user, Revision: revision, CreatedAt: now}
+	}
+	first := comment(primitive.NilObjectID, author, 1)
+	reply := comment(first.ID, viewer, 1)
+	second := comment(primitive.NilObjectID, viewer, 2)
+	orphan := comment(primitive.NewObjectID(), author, 2)
+
+	threads := commentThreads([]models.Comment{first, reply, second, orphan}, paste, viewer)
+	if len(threads) != 3 || threads[0].ID != first.ID || threads[1].ID != second.ID || threads[2].ID != orphan.ID {
+		t.Fatalf("треды: %+v", threads)
+	}
+	if len(threads[0].Replies) != 1 || threads[0].Replies[0].ID != reply.ID {
+		t.Errorf("ответ не попал в тред: %+v", threads[0].Replies)
+	}
+	if !threads[0].Outdated || threads[1].Outdated {
+		t.Error("устаревшим должен быть только комментарий к старой ревизии")
+	}
+	if threads[0].IsAuthor || !threads[0].Replies[0].IsAuthor || !threads[1].IsAuthor {
+		t.Error("неверный признак автора")
+	}
+	if anon := commentThreads([]models.Comment{first}, paste, primitive.NilObjectID); anon[0].IsAuthor {
+		t.Error("аноним считается автором")
+	}
+}
+
+func TestCheckPasteAccessWithoutReads(t *testing.T) {
+	ctx := context.Background()
+	owner, stranger := primitive.NewObjectID(), primitive.NewObjectID()
+	private := models.Paste{UserID: owner, Visibility: models.VisibilityPrivate}
+	if err := checkPasteAccess(ctx, private, stranger, ""); err != errPasteNotFound {
+		t.Errorf("чужая приватная паста: %v", err)
+	}
+	if err := checkPasteAccess(ctx, private, owner, ""); err != nil {
+		t.Errorf("своя приватная паста: %v", err)
+	}
+	hash, err := utils.HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked := models.Paste{UserID: owner, Password: hash}
+	if err := checkPasteAccess(ctx, locked, stranger, "wrong"); err != errPasteLocked {
+		t.Errorf("неверный пароль: %v", err)
+	}
+	if err := checkPasteAccess(ctx, locked, stranger, "secret"); err != nil {
+		t.Errorf("верный пароль: %v", err)
+	}
+}
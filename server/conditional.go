@@ -0,0 +1,98 @@
+package server
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"pastebin/models"
+	"strings"
+	"time"
+)
+
+// Представления пасты с собственным ETag
+const (
+	pasteRepRaw  = "raw"
+	pasteRepJSON = "json"
+	pasteRepHTML = "html"
+)
+
+// Может ли паста храниться в кэшах. Пароль, приватность и лимит
+// прочтений запрещают сохранять ответ где-либо
+func pasteCacheable(p models.Paste) bool {
+	return !p.HasPassword() && p.Visibility != models.VisibilityPrivate && p.DeleteAfter == 0
+}
+
+// Cache-Control для пасты. Кэши обязаны перепроверять ответ (no-cache),
+// поэтому правка пасты видна сразу, а повторные запросы получают 304.
+// shared — ответ одинаков для всех (без данных зрителя)
+func pasteCacheControl(p models.Paste, shared bool) string {
+	switch {
+	case !pasteCacheable(p):
+		return "private, no-store"
+	case p.IsPublic() && shared:
+		return "public, no-cache"
+	default:
+		// Непубличные ссылки и страницы с данными вошедшего пользователя
+		return "private, no-cache"
+	}
+}
+
+// Сильный ETag: ID и ревизия пасты. Содержимое raw определяется ревизией
+// полностью; в JSON и HTML есть счётчики и комментарии, поэтому к ним
+// добавляется хэш тела
+func pasteETag(p models.Paste, rep string, body []byte) string {
+	tag := fmt.Sprintf("%s-r%d-%s", p.ID.Hex(), p.Revision, rep)
+	if rep != pasteRepRaw {
+		h := fnv.New64a()
+		h.Write(body)
+		tag += fmt.Sprintf("-%016x", h.Sum64())
+	}
+	return `"` + tag + `"`
+}
+
+// Совпадает ли один из тегов If-None-Match
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Ставим ETag, Last-Modified и Cache-Control и проверяем условный запрос.
+// true — клиенту отправлен 304 и тело писать не нужно.
+// If-Modified-Since учитываем только для raw: время правки не отражает
+// новые комментарии и счётчики в JSON и HTML
+func writePasteValidators(w http.ResponseWriter, r *http.Request, p models.Paste, rep string, body []byte, shared bool) bool {
+	header := w.Header()
+	header.Set("Cache-Control", pasteCacheControl(p, shared))
+	if !pasteCacheable(p) {
+		return false
+	}
+
+	etag := pasteETag(p, rep, body)
+	modified := pasteUpdated(p).UTC().Truncate(time.Second)
+	header.Set("ETag", etag)
+	header.Set("Last-Modified", modified.Format(http.TimeFormat))
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	notModified := false
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		notModified = etagMatches(inm, etag)
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && rep == pasteRepRaw {
+		if t, err := http.ParseTime(ims); err == nil {
+			notModified = !modified.After(t)
+		}
+	}
+	if notModified {
+		// Тело не отправляем, его заголовки тоже
+		header.Del("Content-Type")
+		header.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+	}
+	return notModified
+}
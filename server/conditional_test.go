@@ -0,0 +1,92 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pastebin/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPasteCacheControl(t *testing.T) {
+	id := primitive.NewObjectID()
+	cases := []struct {
+		name   string
+		paste  models.Paste
+		shared bool
+		want   string
+	}{
+		{"public", models.Paste{ID: id, Visibility: models.VisibilityPublic}, true, "public, no-cache"},
+		{"legacy public", models.Paste{ID: id}, true, "public, no-cache"},
+		{"public for logged-in viewer", models.Paste{ID: id}, false, "private, no-cache"},
+		{"unlisted", models.Paste{ID: id, Visibility: models.VisibilityUnlisted}, true, "private, no-cache"},
+		{"private", models.Paste{ID: id, Visibility: models.VisibilityPrivate}, true, "private, no-store"},
+		{"password", models.Paste{ID: id, Password: "hash"}, true, "private, no-store"},
+		{"burn", models.Paste{ID: id, DeleteAfter: 1}, true, "private, no-store"},
+	}
+	for _, c := range cases {
+		if got := pasteCacheControl(c.paste, c.shared); got != c.want {
+			t.Errorf("%s: %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestPasteETagChangesWithRevisionAndBody(t *testing.T) {
+	p := models.Paste{ID: primitive.NewObjectID(), Revision: 1}
+	raw := pasteETag(p, pasteRepRaw, nil)
+	if raw != `"`+p.ID.Hex()+`-r1-raw"` {
+		t.Errorf("raw ETag = %s", raw)
+	}
+	if pasteETag(p, pasteRepJSON, []byte("a")) == pasteETag(p, pasteRepJSON, []byte("b")) {
+		t.Error("JSON ETag ignores the body")
+	}
+	p.Revision = 2
+	if pasteETag(p, pasteRepRaw, nil) == raw {
+		t.Error("raw ETag ignores the revision")
+	}
+}
+
+func TestWritePasteValidators(t *testing.T) {
+	edited := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	p := models.Paste{ID: primitive.NewObjectID(), Revision: 3, CreatedAt: edited.Add(-time.Hour), UpdatedAt: edited}
+
+	rec := httptest.NewRecorder()
+	if writePasteValidators(rec, httptest.NewRequest("GET", "/", nil), p, pasteRepRaw, nil, true) {
+		t.Fatal("unconditional request got 304")
+	}
+	etag := rec.Header().Get("ETag")
+	if rec.Header().Get("Last-Modified") != "Wed, 01 May 2024 12:00:00 GMT" {
+		t.Errorf("Last-Modified = %q", rec.Header().Get("Last-Modified"))
+	}
+
+	for name, header := range map[string][2]string{
+		"etag":     {"If-None-Match", `"other", ` + etag},
+		"modified": {"If-Modified-Since", "Wed, 01 May 2024 12:00:00 GMT"},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(header[0], header[1])
+		rec := httptest.NewRecorder()
+		if !writePasteValidators(rec, req, p, pasteRepRaw, nil, true) || rec.Code != http.StatusNotModified {
+			t.Errorf("%s: expected 304, got %d", name, rec.Code)
+		}
+	}
+
+	// Старый ETag после правки
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", `"`+p.ID.Hex()+`-r2-raw"`)
+	if writePasteValidators(httptest.NewRecorder(), req, p, pasteRepRaw, nil, true) {
+		t.Error("stale ETag got 304")
+	}
+
+	// Паста с паролем не получает валидаторов
+	p.Password = "hash"
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", "*")
+	rec = httptest.NewRecorder()
+	if writePasteValidators(rec, req, p, pasteRepRaw, nil, true) || rec.Header().Get("ETag") != "" {
+		t.Error("password paste got validators")
+	}
+}
@@ -0,0 +1,296 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"pastebin/models"
+	"pastebin/utils"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	discoveryInterval = 10 * time.Minute   // Как часто пересчитываем рейтинги
+	trendingWindow    = 7 * 24 * time.Hour // Учитываем звёзды и просмотры за неделю
+	trendingHalfLife  = 24 * time.Hour     // Вес события падает вдвое за сутки
+	viewWeight        = 0.2                // Уникальный просмотр весит меньше звезды
+	trendingLimit     = 50
+	popularPageSize   = 50 // Для всех языков и для каждого языка отдельно
+)
+
+// Паста в рейтинге: только метаданные, без содержимого
+type RankedPaste struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Title     string             `bson:"title"`
+	Language  string             `bson:"language"`
+	CreatedAt time.Time          `bson:"createdAt"`
+	Stars     int                `bson:"stars"`
+	Views     int                `bson:"views"`
+	Score     float64            `bson:"score"`
+}
+
+// Последние посчитанные рейтинги
+var (
+	discoveryMu     sync.RWMutex
+	trendingPastes  []RankedPaste
+	popularPastes   map[string][]RankedPaste // По языку; "" — все языки
+	discoveryUpdate time.Time
+)
+
+// Фоновый пересчёт рейтингов; останавливается при отмене контекста
+func StartDiscoveryAggregator(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(discoveryInterval)
+		defer ticker.Stop()
+		for {
+			if err := refreshDiscovery(ctx); err != nil {
+				log.Printf("Ошибка пересчёта рейтингов: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Звёзды и просмотры считаются в базе, в память попадают только
+// верхушки рейтингов
+func refreshDiscovery(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	now := time.Now()
+	trending, err := aggregateRanked(ctx, "stars", trendingPipeline(now))
+	if err != nil {
+		return err
+	}
+	popular := make(map[string][]RankedPaste, len(models.Languages)+1)
+	for _, language := range append([]string{""}, models.Languages...) {
+		list, err := aggregateRanked(ctx, "paste_views", popularPipeline(language, now))
+		if err != nil {
+			return err
+		}
+		popular[language] = list
+	}
+
+	discoveryMu.Lock()
+	trendingPastes = trending
+	popularPastes = popular
+	discoveryUpdate = now
+	discoveryMu.Unlock()
+	return nil
+}
+
+func aggregateRanked(ctx context.Context, collection string, pipeline mongo.Pipeline) ([]RankedPaste, error) {
+	cursor, err := GetCollection(collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var list []RankedPaste
+	err = cursor.All(ctx, &list)
+	return list, err
+}
+
+// Фильтр публичных паст (старые пасты без visibility тоже публичные)
+func publicPasteFilter() bson.M {
+	return bson.M{"visibility": bson.M{"$in": bson.A{models.VisibilityPublic, "", nil}}}
+}
+
+// Пасты, которые можно показывать в рейтингах: публичные, не истёкшие и
+// не сгорающие — иначе список зазывал бы прочитать их до конца
+func rankablePasteFilter(language string, now time.Time) bson.M {
+	filter := publicPasteFilter()
+	filter["$or"] = bson.A{
+		bson.M{"expiresAt": bson.M{"$exists": false}},
+		bson.M{"expiresAt": bson.M{"$gt": now}},
+	}
+	filter["deleteAfter"] = bson.M{"$not": bson.M{"$gt": 0}}
+	if language != "" {
+		filter["language"] = language
+	}
+	return filter
+}
+
+// Вес события с учётом его возраста: вдвое меньше за каждые trendingHalfLife
+func decayExpr(field string, weight float64, now time.Time) bson.M {
+	age := bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{now, field}}}}
+	return bson.M{"$multiply": bson.A{weight, bson.M{"$pow": bson.A{0.5,
+		bson.M{"$divide": bson.A{age, trendingHalfLife.Milliseconds()}}}}}}
+}
+
+// Стадии после группировки по пасте (_id): метаданные подходящей пасты,
+// очки из score, сортировка и limit лучших
+func rankedStages(language string, now time.Time, score interface{}, limit int) mongo.Pipeline {
+	match := rankablePasteFilter(language, now)
+	match["$expr"] = bson.M{"$eq": bson.A{"$_id", "$$id"}}
+	return mongo.Pipeline{
+		{{Key: "$lookup", Value: bson.M{
+			"from":     "pastes",
+			"let":      bson.M{"id": "$_id"},
+			"pipeline": bson.A{bson.M{"$match": match}, bson.M{"$project": bson.M{"title": 1, "language": 1, "createdAt": 1, "stars": 1}}},
+			"as":       "paste",
+		}}},
+		{{Key: "$unwind", Value: "$paste"}},
+		{{Key: "$project", Value: bson.M{
+			"title":     "$paste.title",
+			"language":  "$paste.language",
+			"createdAt": "$paste.createdAt",
+			"stars":     "$paste.stars",
+			"views":     1,
+			"score":     score,
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "createdAt", Value: -1}}}},
+		{{Key: "$limit", Value: limit}},
+	}
+}
+
+// Тренды по коллекции stars: звёзды и уникальные просмотры за неделю,
+// каждое событие весит тем меньше, чем оно старше
+func trendingPipeline(now time.Time) mongo.Pipeline {
+	since := now.Add(-trendingWindow)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"createdAt": bson.M{"$gte": since}}}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "paste_id": 1, "weight": decayExpr("$createdAt", 1, now)}}},
+		{{Key: "$unionWith", Value: bson.M{"coll": "paste_views", "pipeline": bson.A{
+			bson.M{"$match": bson.M{"viewedAt": bson.M{"$gte": since}}},
+			bson.M{"$project": bson.M{"_id": 0, "paste_id": 1, "weight": decayExpr("$viewedAt", viewWeight, now)}},
+		}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$paste_id", "trend": bson.M{"$sum": "$weight"}}}},
+	}
+	pipeline = append(pipeline, rankedStages("", now, "$trend", trendingLimit)...)
+	// Просмотры за всё время — только для показанных паст
+	return append(pipeline,
+		bson.D{{Key: "$lookup", Value: bson.M{
+			"from":     "paste_views",
+			"let":      bson.M{"id": "$_id"},
+			"pipeline": bson.A{bson.M{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$paste_id", "$$id"}}}}, bson.M{"$count": "n"}},
+			"as":       "views",
+		}}},
+		bson.D{{Key: "$set", Value: bson.M{"views": bson.M{"$ifNull": bson.A{bson.M{"$first": "$views.n"}, 0}}}}},
+	)
+}
+
+// Популярное по коллекции paste_views: уникальные просмотры за всё время
+// и звёзды; пасты со звёздами, но без просмотров, тоже участвуют
+func popularPipeline(language string, now time.Time) mongo.Pipeline {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$paste_id", "views": bson.M{"$sum": 1}}}},
+		{{Key: "$unionWith", Value: bson.M{"coll": "pastes", "pipeline": bson.A{
+			bson.M{"$match": bson.M{"stars": bson.M{"$gt": 0}}},
+			bson.M{"$project": bson.M{"_id": 1, "views": bson.M{"$literal": 0}}},
+		}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$_id", "views": bson.M{"$sum": "$views"}}}},
+	}
+	score := bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$paste.stars", 0}}, bson.M{"$multiply": bson.A{viewWeight, "$views"}}}}
+	return append(pipeline, rankedStages(language, now, score, popularPageSize)...)
+}
+
+// Ставим звезду или снимаем уже поставленную; +1 или -1 для счётчика пасты
+func toggleStar(ctx context.Context, pasteID, userID primitive.ObjectID) (int, error) {
+	star := models.Star{PasteID: pasteID, UserID: userID, CreatedAt: time.Now()}
+	_, err := GetCollection("stars").InsertOne(ctx, star)
+	if !mongo.IsDuplicateKeyError(err) {
+		if err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+
+	// Звезда уже стоит: снимаем её
+	result, err := GetCollection("stars").DeleteOne(ctx, bson.M{"paste_id": pasteID, "user_id": userID})
+	if err != nil {
+		return 0, err
+	}
+	if result.DeletedCount == 0 {
+		return 0, errors.New("star disappeared while toggling")
+	}
+	return -1, nil
+}
+
+// Поставить или снять звезду
+func StarPasteHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	pasteID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid paste ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var paste models.Paste
+	err = GetCollection("pastes").FindOne(ctx, bson.M{"_id": pasteID}).Decode(&paste)
+	if err != nil || !paste.CanView(userID) {
+		http.Error(w, "Paste not found", http.StatusNotFound)
+		return
+	}
+
+	delta, err := toggleStar(ctx, pasteID, userID)
+	if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to update star")
+		return
+	}
+
+	_, err = GetCollection("pastes").UpdateOne(ctx, bson.M{"_id": pasteID}, bson.M{"$inc": bson.M{"stars": delta}})
+	if err != nil {
+		log.Printf("Ошибка обновления счётчика звёзд: %v", err)
+	}
+	hotPastes.Invalidate(pasteID)
+
+	http.Redirect(w, r, fmt.Sprintf("/paste/%s", pasteID.Hex()), http.StatusSeeOther)
+}
+
+// Страница трендов
+func TrendingHandler(w http.ResponseWriter, r *http.Request) {
+	discoveryMu.RLock()
+	pastes := trendingPastes
+	updated := discoveryUpdate
+	discoveryMu.RUnlock()
+
+	renderDiscovery(w, r, "Trending", "", pastes, updated)
+}
+
+// Популярные пасты, с фильтром по языку ?lang=go
+func PopularHandler(w http.ResponseWriter, r *http.Request) {
+	language := r.URL.Query().Get("lang")
+
+	discoveryMu.RLock()
+	pastes := popularPastes[language]
+	updated := discoveryUpdate
+	discoveryMu.RUnlock()
+
+	renderDiscovery(w, r, "Popular", language, pastes, updated)
+}
+
+func renderDiscovery(w http.ResponseWriter, r *http.Request, title, language string, pastes []RankedPaste, updated time.Time) {
+	data := struct {
+		Title     string
+		Language  string
+		Languages []string
+		Pastes    []RankedPaste
+		UpdatedAt time.Time
+	}{
+		Title:     title,
+		Language:  language,
+		Languages: models.Languages,
+		Pastes:    pastes,
+		UpdatedAt: updated,
+	}
+	render(w, r, "discover.html", data)
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"pastebin/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestRankablePasteFilter(t *testing.T) {
+	now := time.Now()
+	filter := rankablePasteFilter("go", now)
+	if filter["language"] != "go" {
+		t.Errorf("language = %v, want go", filter["language"])
+	}
+	if _, ok := filter["visibility"]; !ok {
+		t.Error("в фильтре нет условия на видимость")
+	}
+	if _, ok := filter["deleteAfter"]; !ok {
+		t.Error("сгорающие пасты не отсеиваются")
+	}
+	if _, ok := filter["$or"]; !ok {
+		t.Error("истёкшие пасты не отсеиваются")
+	}
+	if _, ok := rankablePasteFilter("", now)["language"]; ok {
+		t.Error("без языка фильтра по языку быть не должно")
+	}
+}
+
+func TestDiscoveryPipelinesLimit(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name     string
+		pipeline []bson.D
+		limit    int
+	}{
+		{"trending", trendingPipeline(now), trendingLimit},
+		{"popular", popularPipeline("go", now), popularPageSize},
+	}
+	for _, c := range cases {
+		// Сортировка и limit делаются в базе, а не в Go
+		var limit interface{}
+		for _, stage := range c.pipeline {
+			if stage[0].Key == "$limit" {
+				limit = stage[0].Value
+			}
+		}
+		if limit != c.limit {
+			t.Errorf("%s: $limit = %v, want %d", c.name, limit, c.limit)
+		}
+	}
+}
+
+func TestRefreshDiscovery(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("рейтинг по языкам", func(mt *mtest.T) {
+		useTestDB(mt)
+		goPaste := bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "title", Value: "hello"},
+			{Key: "language", Value: "go"}, {Key: "stars", Value: 2}, {Key: "views", Value: 5}}
+		// Тренды, все языки, затем по одному запросу на язык
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "pastebin.stars", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "pastebin.paste_views", mtest.FirstBatch, goPaste),
+		)
+		for _, language := range models.Languages {
+			var docs []bson.D
+			if language == "go" {
+				docs = append(docs, goPaste)
+			}
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, "pastebin.paste_views", mtest.FirstBatch, docs...))
+		}
+		if err := refreshDiscovery(context.Background()); err != nil {
+			mt.Fatalf("refreshDiscovery: %v", err)
+		}
+
+		discoveryMu.RLock()
+		defer discoveryMu.RUnlock()
+		if got := popularPastes["go"]; len(got) != 1 || got[0].Stars != 2 || got[0].Views != 5 {
+			mt.Errorf("popular[go] = %+v", got)
+		}
+		if got := popularPastes["python"]; len(got) != 0 {
+			mt.Errorf("popular[python] = %+v, want пусто", got)
+		}
+		if len(trendingPastes) != 0 {
+			mt.Errorf("trending = %+v, want пусто", trendingPastes)
+		}
+	})
+}
+
+func TestToggleStar(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	pasteID, userID := primitive.NewObjectID(), primitive.NewObjectID()
+
+	mt.Run("ставит звезду", func(mt *mtest.T) {
+		useTestDB(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		delta, err := toggleStar(context.Background(), pasteID, userID)
+		if err != nil || delta != 1 {
+			mt.Errorf("toggleStar = %d, %v", delta, err)
+		}
+	})
+
+	mt.Run("снимает поставленную", func(mt *mtest.T) {
+		useTestDB(mt)
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"}),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}},
+		)
+		delta, err := toggleStar(context.Background(), pasteID, userID)
+		if err != nil || delta != -1 {
+			mt.Errorf("toggleStar = %d, %v", delta, err)
+		}
+	})
+
+	mt.Run("звезду сняли параллельно", func(mt *mtest.T) {
+		useTestDB(mt)
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"}),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}},
+		)
+		if _, err := toggleStar(context.Background(), pasteID, userID); err == nil {
+			mt.Error("ожидалась ошибка, когда удалять уже нечего")
+		}
+	})
+}
+
+// Подменяем базу на подставную из mtest на время теста
+func useTestDB(mt *mtest.T) {
+	old := db
+	db = mt.DB
+	mt.Cleanup(func() { db = old })
+}
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"pastebin/models"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Размеры очередей подписчиков
+const (
+	auditQueueSize        = 1000
+	metricsQueueSize      = 1000
+	notificationQueueSize = 100
+	webhookQueueSize      = 1000
+	cleanupQueueSize      = 1000
+	pasteStreamQueueSize  = 256
+)
+
+// Подключаем подписчиков к шине приложения. Аудит, вебхуки и очистка теряют
+// события только если очередь не освободилась за eventBlockTimeout;
+// метрики и письма при перегрузке отбрасываются сразу. Просмотры
+// публикуются на каждом чтении пасты, поэтому ждущие подписчики их не получают
+func StartEventSubscribers() {
+	events.Subscribe("cache", 0, Inline, invalidateCacheOnEvent,
+		models.EventPasteUpdated, models.EventPasteDeleted, models.EventPasteExpired)
+	events.Subscribe("live-edit", 0, Inline, endLiveSessionsOnEvent,
+		models.EventPasteDeleted, models.EventPasteExpired)
+	events.Subscribe("audit", auditQueueSize, BlockWhenFull, auditEvent,
+		models.EventPasteCreated, models.EventPasteUpdated, models.EventPasteDeleted, models.EventPasteExpired,
+		PasteShared{}.Name(), UserSignedUp{}.Name(), ChatClosed{}.Name())
+	events.Subscribe("metrics", metricsQueueSize, DropWhenFull, eventMetrics.count)
+	events.Subscribe("notifications", notificationQueueSize, DropWhenFull, notifyOnEvent,
+		models.EventPasteExpired, PasteShared{}.Name())
+	events.Subscribe("webhooks", webhookQueueSize, BlockWhenFull, webhookOnEvent,
+		models.EventPasteCreated, models.EventPasteUpdated, models.EventPasteDeleted, models.EventPasteExpired,
+		models.EventChatMessage)
+	events.Subscribe("paste-cleanup", cleanupQueueSize, BlockWhenFull, cleanupPasteOnEvent,
+		models.EventPasteDeleted, models.EventPasteExpired)
+	events.Subscribe("paste-stream", pasteStreamQueueSize, DropWhenFull, pasteStream.onEvent, models.EventPasteCreated)
+}
+
+// Дожидаемся подписчиков при остановке сервера
+func CloseEvents(ctx context.Context) error {
+	return events.Close(ctx)
+}
+
+// Сброс кэша сразу в Publish, чтобы следующее чтение увидело изменения
+func invalidateCacheOnEvent(e Event) {
+	switch e := e.(type) {
+	case PasteUpdated:
+		hotPastes.Invalidate(e.Paste.ID)
+	case PasteDeleted:
+		hotPastes.Invalidate(e.Paste.ID)
+	case PasteExpired:
+		hotPastes.Invalidate(e.Paste.ID)
+	}
+}
+
+// Редакторы удалённой пасты отключаются сразу, а не при следующем сохранении
+func endLiveSessionsOnEvent(e Event) {
+	switch e := e.(type) {
+	case PasteDeleted:
+		liveSessions.end(e.Paste.ID, "Paste was deleted")
+	case PasteExpired:
+		liveSessions.end(e.Paste.ID, "Paste has expired")
+	}
+}
+
+// Данные, которые не имеют смысла без пасты: комментарии и открытые
+// предложения правок
+func cleanupPasteOnEvent(e Event) {
+	var pasteID primitive.ObjectID
+	switch e := e.(type) {
+	case PasteDeleted:
+		pasteID = e.Paste.ID
+	case PasteExpired:
+		pasteID = e.Paste.ID
+	default:
+		return
+	}
+	if db == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := deletePasteComments(ctx, pasteID); err != nil {
+		log.Printf("Ошибка удаления комментариев пасты %s: %v", pasteID.Hex(), err)
+	}
+	if err := closePasteProposals(ctx, pasteID); err != nil {
+		log.Printf("Ошибка закрытия предложений правок пасты %s: %v", pasteID.Hex(), err)
+	}
+}
+
+// Журнал действий в pastes.log
+func auditEvent(e Event) {
+	if pasteLogger == nil {
+		return
+	}
+	now := time.Now().Format(time.RFC3339)
+	switch e := e.(type) {
+	case PasteCreated:
+		pasteLogger.Printf("Created paste: ID=%s, Title=%s, Date=%s\n", e.Paste.ID.Hex(), e.Paste.Title, e.Paste.CreatedAt.Format(time.RFC3339))
+	case PasteUpdated:
+		by := ""
+		if e.ByAdmin {
+			by = " by admin"
+		}
+		pasteLogger.Printf("Edited paste%s: ID=%s, Revision=%d, Date=%s\n", by, e.Paste.ID.Hex(), e.Paste.Revision, now)
+	case PasteDeleted:
+		by := ""
+		if e.ByAdmin {
+			by = " by admin"
+		}
+		pasteLogger.Printf("Deleted paste%s: ID=%s, Date=%s\n", by, e.Paste.ID.Hex(), now)
+	case PasteExpired:
+		if e.Reason == "burned" {
+			pasteLogger.Printf("Burned paste after %d reads: ID=%s, Date=%s\n", e.Paste.CurrentReads, e.Paste.ID.Hex(), now)
+		} else {
+			pasteLogger.Printf("Expired paste: ID=%s, Date=%s\n", e.Paste.ID.Hex(), now)
+		}
+	case PasteShared:
+		pasteLogger.Printf("Shared paste: ID=%s, User=%s, Role=%s, Date=%s\n", e.Paste.ID.Hex(), e.Share.UserID.Hex(), e.Share.Role, now)
+	case UserSignedUp:
+		pasteLogger.Printf("Signed up user: ID=%s, Email=%s, Date=%s\n", e.User.ID.Hex(), e.User.Email, now)
+	case ChatClosed:
+		pasteLogger.Printf("Closed chat: ID=%s, Date=%s\n", e.ChatID.Hex(), e.At.Format(time.RFC3339))
+	}
+}
+
+// Счётчики событий с момента запуска
+type eventCounter struct {
+	mu     sync.Mutex
+	counts map[string]int64
+	since  time.Time
+}
+
+var eventMetrics = &eventCounter{counts: make(map[string]int64), since: time.Now()}
+
+func (c *eventCounter) count(e Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[e.Name()]++
+}
+
+func (c *eventCounter) snapshot() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]int64, len(c.counts))
+	for name, n := range c.counts {
+		counts[name] = n
+	}
+	return counts
+}
+
+// Отправка писем; подменяется в тестах
+var sendNotification = SendEmail
+
+// Письма: владельцу, когда его паста с лимитом прочтений сгорела,
+// и приглашённому, когда ему открыли пасту
+func notifyOnEvent(e Event) {
+	if shared, ok := e.(PasteShared); ok {
+		notifyShare(shared)
+		return
+	}
+	expired, ok := e.(PasteExpired)
+	if !ok || expired.Reason != "burned" || expired.Paste.UserID.IsZero() || db == nil {
+		return
+	}
+	email, err := userEmail(expired.Paste.UserID)
+	if err != nil || email == "" {
+		log.Printf("Не удалось найти владельца пасты %s для уведомления: %v", expired.Paste.ID.Hex(), err)
+		return
+	}
+	title := expired.Paste.Title
+	if title == "" {
+		title = expired.Paste.ID.Hex()
+	}
+	body := fmt.Sprintf("Your paste %q was read %d time(s) and has been deleted.", title, expired.Paste.CurrentReads)
+	if err := sendNotification(email, body); err != nil {
+		log.Printf("Ошибка отправки уведомления: %v", err)
+	}
+}
+
+func notifyShare(e PasteShared) {
+	title := e.Paste.Title
+	if title == "" {
+		title = e.Paste.ID.Hex()
+	}
+	owner := "Someone"
+	if db != nil {
+		if email, err := userEmail(e.Paste.UserID); err == nil && email != "" {
+			owner = email
+		}
+	}
+	access := "view"
+	if e.Share.Role == models.ShareEdit {
+		access = "view and edit"
+	}
+	body := fmt.Sprintf("%s invited you to %s the paste %q: %s", owner, access, title, pasteLink(siteBaseURL(), e.Paste.ID))
+	if err := sendNotification(e.Share.Email, body); err != nil {
+		log.Printf("Ошибка отправки приглашения: %v", err)
+	}
+}
+
+func userEmail(userID primitive.ObjectID) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var user models.User
+	err := GetCollection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	return user.Email, err
+}
+
+// Постановка в очередь вебхуков
+func webhookOnEvent(e Event) {
+	var ownerID primitive.ObjectID
+	var data interface{}
+	switch e := e.(type) {
+	case PasteCreated:
+		ownerID, data = e.Paste.UserID, pasteEventData(e.Paste, "")
+	case PasteUpdated:
+		ownerID, data = e.Paste.UserID, pasteEventData(e.Paste, "")
+	case PasteDeleted:
+		ownerID, data = e.Paste.UserID, pasteEventData(e.Paste, "")
+	case PasteExpired:
+		ownerID, data = e.Paste.UserID, pasteEventData(e.Paste, e.Reason)
+	case ChatMessagePosted:
+		ownerID, data = e.Chat.UserID, chatMessageEventData(e.Chat, e.Message)
+	default:
+		return
+	}
+	if db == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := enqueueWebhookEvent(ctx, e.Name(), ownerID, data, time.Now()); err != nil {
+		log.Printf("Ошибка постановки события %s в очередь вебхуков: %v", e.Name(), err)
+	}
+}
+
+// Счётчики событий и состояние очередей подписчиков в JSON
+func EventStatsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Since       time.Time         `json:"since"`
+		Events      map[string]int64  `json:"events"`
+		Subscribers []SubscriberStats `json:"subscribers"`
+	}{
+		Since:       eventMetrics.since,
+		Events:      eventMetrics.snapshot(),
+		Subscribers: events.Stats(),
+	})
+}
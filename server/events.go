@@ -0,0 +1,265 @@
+package server
+
+import (
+	"context"
+	"log"
+	"pastebin/models"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Доменные события. Обработчики публикуют их в шину, побочные эффекты
+// (аудит, метрики, уведомления, кэш, вебхуки) живут в подписчиках
+type Event interface {
+	Name() string
+}
+
+type PasteCreated struct {
+	Paste models.Paste
+}
+
+type PasteUpdated struct {
+	Paste   models.Paste // Уже после изменения
+	ByAdmin bool
+}
+
+type PasteDeleted struct {
+	Paste   models.Paste
+	ByAdmin bool
+}
+
+// Паста удалена по сроку жизни ("expired") или после последнего прочтения ("burned")
+type PasteExpired struct {
+	Paste  models.Paste
+	Reason string
+}
+
+// Владелец открыл пасту пользователю или поменял его роль
+type PasteShared struct {
+	Paste models.Paste
+	Share models.PasteShare
+}
+
+type PasteViewed struct {
+	PasteID primitive.ObjectID
+	At      time.Time
+}
+
+type UserSignedUp struct {
+	User models.User
+}
+
+type ChatMessagePosted struct {
+	Chat    models.Chat // Заполнены только ID и UserID
+	Message models.Message
+}
+
+type ChatClosed struct {
+	ChatID primitive.ObjectID
+	At     time.Time
+}
+
+// Имена совпадают с событиями вебхуков, где они есть
+func (PasteCreated) Name() string      { return models.EventPasteCreated }
+func (PasteUpdated) Name() string      { return models.EventPasteUpdated }
+func (PasteDeleted) Name() string      { return models.EventPasteDeleted }
+func (PasteExpired) Name() string      { return models.EventPasteExpired }
+func (PasteShared) Name() string       { return "paste.shared" }
+func (PasteViewed) Name() string       { return "paste.viewed" }
+func (UserSignedUp) Name() string      { return "user.signed_up" }
+func (ChatMessagePosted) Name() string { return models.EventChatMessage }
+func (ChatClosed) Name() string        { return "chat.closed" }
+
+// Что делать, когда очередь подписчика заполнена
+type Backpressure int
+
+const (
+	// Событие отбрасывается и учитывается в Dropped; публикующий не ждёт
+	DropWhenFull Backpressure = iota
+	// Публикующий ждёт место в очереди до eventBlockTimeout, затем событие отбрасывается
+	BlockWhenFull
+	// Без очереди: подписчик вызывается прямо в Publish. Для быстрых
+	// действий, которые должны завершиться до ответа (сброс кэша)
+	Inline
+)
+
+// Сколько BlockWhenFull ждёт места в очереди
+const eventBlockTimeout = 2 * time.Second
+
+type subscriber struct {
+	name      string
+	policy    Backpressure
+	only      map[string]bool // Имена нужных событий; nil — все
+	handle    func(Event)
+	queue     chan Event
+	delivered atomic.Int64
+	dropped   atomic.Int64
+}
+
+// Состояние подписчика для /admin/events
+type SubscriberStats struct {
+	Name      string `json:"name"`
+	Queued    int    `json:"queued"`
+	Capacity  int    `json:"capacity"`
+	Delivered int64  `json:"delivered"`
+	Dropped   int64  `json:"dropped"`
+}
+
+// Шина событий внутри процесса: у каждого асинхронного подписчика своя
+// ограниченная очередь и горутина, так что медленный подписчик не
+// задерживает остальных
+type EventBus struct {
+	mu         sync.RWMutex
+	subs       []*subscriber
+	closed     bool
+	publishing sync.WaitGroup // Publish, которые ещё кладут событие в очереди
+	wg         sync.WaitGroup
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Шина приложения; подписчики регистрируются в StartEventSubscribers
+var events = NewEventBus()
+
+// Подписываем handle на события с именами only, без них — на все. Ненужные
+// события отсеиваются до очереди и не занимают в ней места. buffer —
+// размер очереди, для Inline не используется
+func (b *EventBus) Subscribe(name string, buffer int, policy Backpressure, handle func(Event), only ...string) {
+	s := &subscriber{name: name, policy: policy, handle: handle}
+	if len(only) > 0 {
+		s.only = make(map[string]bool, len(only))
+		for _, event := range only {
+			s.only[event] = true
+		}
+	}
+	if policy != Inline {
+		s.queue = make(chan Event, buffer)
+		b.wg.Add(1)
+		go b.run(s)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = append(b.subs, s)
+}
+
+func (b *EventBus) run(s *subscriber) {
+	defer b.wg.Done()
+	for e := range s.queue {
+		b.deliver(s, e)
+	}
+}
+
+// Паника подписчика не должна ронять остальных
+func (b *EventBus) deliver(s *subscriber, e Event) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("Подписчик %s упал на событии %s: %v", s.name, e.Name(), p)
+		}
+	}()
+	s.handle(e)
+	s.delivered.Add(1)
+}
+
+// Отдаём событие всем подписчикам. После Close события игнорируются.
+// Ожидание места в очереди BlockWhenFull идёт без блокировки шины
+func (b *EventBus) Publish(e Event) {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return
+	}
+	subs := b.subs
+	b.publishing.Add(1)
+	b.mu.RUnlock()
+	defer b.publishing.Done()
+
+	for _, s := range subs {
+		if s.only != nil && !s.only[e.Name()] {
+			continue
+		}
+		switch s.policy {
+		case Inline:
+			b.deliver(s, e)
+		case BlockWhenFull:
+			select {
+			case s.queue <- e:
+				continue
+			default:
+			}
+			timer := time.NewTimer(eventBlockTimeout)
+			select {
+			case s.queue <- e:
+			case <-timer.C:
+				b.drop(s, e)
+			}
+			timer.Stop()
+		default:
+			select {
+			case s.queue <- e:
+			default:
+				b.drop(s, e)
+			}
+		}
+	}
+}
+
+func (b *EventBus) drop(s *subscriber, e Event) {
+	// Логируем первое и каждое сотое отброшенное, чтобы не засыпать лог
+	if n := s.dropped.Add(1); n == 1 || n%100 == 0 {
+		log.Printf("Очередь подписчика %s переполнена, отброшено событий: %d (последнее %s)", s.name, n, e.Name())
+	}
+}
+
+func (b *EventBus) Stats() []SubscriberStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	stats := make([]SubscriberStats, 0, len(b.subs))
+	for _, s := range b.subs {
+		stats = append(stats, SubscriberStats{
+			Name:      s.name,
+			Queued:    len(s.queue),
+			Capacity:  cap(s.queue),
+			Delivered: s.delivered.Load(),
+			Dropped:   s.dropped.Load(),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// Перестаём принимать события и ждём, пока подписчики разберут очереди
+func (b *EventBus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	wasClosed := b.closed
+	b.closed = true
+	subs := b.subs
+	b.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		// Очереди закрываются, когда начатые Publish в них уже ничего не положат
+		if !wasClosed {
+			b.publishing.Wait()
+			for _, s := range subs {
+				if s.queue != nil {
+					close(s.queue)
+				}
+			}
+		}
+		b.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type recordedEvents struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (r *recordedEvents) handle(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.names = append(r.names, e.Name())
+}
+
+func (r *recordedEvents) len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.names)
+}
+
+func TestEventBusDeliversToAllSubscribers(t *testing.T) {
+	bus := NewEventBus()
+	var inline, async recordedEvents
+	bus.Subscribe("inline", 0, Inline, inline.handle)
+	bus.Subscribe("async", 10, BlockWhenFull, async.handle)
+
+	bus.Publish(ChatClosed{ChatID: primitive.NewObjectID()})
+	// Inline-подписчик отработал до возврата из Publish
+	if inline.len() != 1 {
+		t.Fatalf("inline got %d events", inline.len())
+	}
+	bus.Publish(PasteViewed{PasteID: primitive.NewObjectID()})
+
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if async.len() != 2 || async.names[0] != "chat.closed" || async.names[1] != "paste.viewed" {
+		t.Errorf("async got %v", async.names)
+	}
+
+	// После Close события игнорируются
+	bus.Publish(ChatClosed{})
+	if inline.len() != 2 {
+		t.Errorf("inline got %d events after close", inline.len())
+	}
+}
+
+func TestEventBusDropsWhenFull(t *testing.T) {
+	bus := NewEventBus()
+	release := make(chan struct{})
+	var slow recordedEvents
+	bus.Subscribe("slow", 1, DropWhenFull, func(e Event) {
+		<-release
+		slow.handle(e)
+	})
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		bus.Publish(ChatClosed{})
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Publish blocked on a full DropWhenFull queue")
+	}
+	close(release)
+	bus.Close(context.Background())
+
+	stats := bus.Stats()[0]
+	if stats.Delivered+stats.Dropped != 10 || stats.Dropped < 8 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestEventBusRecoversFromPanics(t *testing.T) {
+	bus := NewEventBus()
+	var after recordedEvents
+	bus.Subscribe("panicky", 0, Inline, func(Event) { panic("boom") })
+	bus.Subscribe("after", 0, Inline, after.handle)
+
+	bus.Publish(ChatClosed{})
+	if after.len() != 1 {
+		t.Error("subscriber after a panicking one was skipped")
+	}
+}
+
+func TestEventBusFiltersBeforeQueueing(t *testing.T) {
+	bus := NewEventBus()
+	release := make(chan struct{})
+	var audit recordedEvents
+	bus.Subscribe("audit", 1, BlockWhenFull, func(e Event) {
+		<-release
+		audit.handle(e)
+	}, "chat.closed")
+
+	// Очередь занята, но просмотры подписчику не нужны и не ждут места
+	bus.Publish(ChatClosed{})
+	bus.Publish(ChatClosed{})
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		bus.Publish(PasteViewed{PasteID: primitive.NewObjectID()})
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Publish waited for a subscriber that filters the event out")
+	}
+
+	// Ожидание места в очереди не держит шину
+	blocked := make(chan struct{})
+	go func() {
+		bus.Publish(ChatClosed{})
+		close(blocked)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	statsDone := make(chan struct{})
+	go func() {
+		bus.Stats()
+		close(statsDone)
+	}()
+	select {
+	case <-statsDone:
+	case <-time.After(time.Second):
+		t.Error("Stats blocked while Publish was waiting for queue space")
+	}
+
+	close(release)
+	<-blocked
+	bus.Close(context.Background())
+	if audit.len() != 3 {
+		t.Errorf("audit got %v", audit.names)
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"pastebin/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Как часто ищем истёкшие пасты
+const expirySweepInterval = time.Minute
+
+// Варианты срока жизни из формы создания пасты
+var expiryOptions = map[string]func(time.Time) time.Time{
+	"1hour":   func(t time.Time) time.Time { return t.Add(time.Hour) },
+	"1day":    func(t time.Time) time.Time { return t.AddDate(0, 0, 1) },
+	"1week":   func(t time.Time) time.Time { return t.AddDate(0, 0, 7) },
+	"1month":  func(t time.Time) time.Time { return t.AddDate(0, 1, 0) },
+	"6months": func(t time.Time) time.Time { return t.AddDate(0, 6, 0) },
+	"1year":   func(t time.Time) time.Time { return t.AddDate(1, 0, 0) },
+}
+
+// Время истечения для варианта срока жизни; "" и "never" — бессрочно
+func expiryTime(option string, now time.Time) (time.Time, error) {
+	if option == "" || option == "never" {
+		return time.Time{}, nil
+	}
+	add, ok := expiryOptions[option]
+	if !ok {
+		return time.Time{}, fmt.Errorf("unknown expiry option %q", option)
+	}
+	return add(now), nil
+}
+
+// Удаляем истёкшую пасту и убираем её из кэша
+func expirePaste(ctx context.Context, id primitive.ObjectID) error {
+	var expired models.Paste
+	err := GetCollection("pastes").FindOneAndDelete(ctx, bson.M{"_id": id, "expiresAt": bson.M{"$lte": time.Now()}}).Decode(&expired)
+	// Сбрасываем после удаления: иначе параллельное чтение успеет
+	// вернуть пасту в кэш из базы
+	hotPastes.Invalidate(id)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	events.Publish(PasteExpired{Paste: expired, Reason: "expired"})
+	return nil
+}
+
+// Фоновое удаление истёкших паст
+func StartExpirySweeper(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(expirySweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := sweepExpiredPastes(ctx); err != nil {
+					log.Printf("Ошибка удаления истёкших паст: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func sweepExpiredPastes(ctx context.Context) error {
+	filter := bson.M{"expiresAt": bson.M{"$lte": time.Now()}}
+	cursor, err := GetCollection("pastes").Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	var expired []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &expired); err != nil {
+		return err
+	}
+	for _, p := range expired {
+		if err := expirePaste(ctx, p.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
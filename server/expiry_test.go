@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"pastebin/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestExpiryTime(t *testing.T) {
+	now := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	for option, want := range map[string]time.Time{
+		"":       {},
+		"never":  {},
+		"1hour":  now.Add(time.Hour),
+		"1week":  now.AddDate(0, 0, 7),
+		"1month": now.AddDate(0, 1, 0),
+	} {
+		if got, err := expiryTime(option, now); err != nil || !got.Equal(want) {
+			t.Errorf("expiryTime(%q) = %v, %v; want %v", option, got, err, want)
+		}
+	}
+	if _, err := expiryTime("forever", now); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного срока")
+	}
+}
+
+func TestSweepExpiredPastes(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("удаляет истёкшие пасты", func(mt *mtest.T) {
+		useTestDB(mt)
+		expired, raced := primitive.NewObjectID(), primitive.NewObjectID()
+
+		var published []Event
+		old := events
+		events = NewEventBus()
+		events.Subscribe("test", 0, Inline, func(e Event) { published = append(published, e) })
+		mt.Cleanup(func() { events = old })
+
+		hotPastes.Add(cachedPaste{Paste: models.Paste{ID: expired, Content: "old"}})
+		mt.Cleanup(func() { hotPastes.Invalidate(expired) })
+
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "pastebin.pastes", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: expired}}, bson.D{{Key: "_id", Value: raced}}),
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{{Key: "_id", Value: expired}, {Key: "title", Value: "old"}}}),
+			// Вторую пасту успели удалить при чтении
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}),
+		)
+		if err := sweepExpiredPastes(context.Background()); err != nil {
+			mt.Fatal(err)
+		}
+
+		if _, ok := hotPastes.Get(expired); ok {
+			mt.Error("Истёкшая паста осталась в кэше")
+		}
+		if len(published) != 1 {
+			mt.Fatalf("Ожидалось одно событие, получено %d", len(published))
+		}
+		if e, ok := published[0].(PasteExpired); !ok || e.Paste.ID != expired || e.Reason != "expired" {
+			mt.Errorf("Неверное событие: %+v", published[0])
+		}
+	})
+}
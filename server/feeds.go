@@ -0,0 +1,316 @@
+package server
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"pastebin/models"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Параметры ленты
+const (
+	feedSize       = 20
+	feedSummaryLen = 500 // Символов содержимого в описании записи
+	feedMaxAge     = 5 * time.Minute
+)
+
+// Запрос ленты: свежие публичные пасты пользователя и/или языка
+type feedQuery struct {
+	UserID   primitive.ObjectID
+	Author   string
+	Language string
+}
+
+// Разбираем ?user=<id>&language=<lang>; пустой запрос — лента всего сайта
+func parseFeedQuery(r *http.Request) (feedQuery, error) {
+	var q feedQuery
+	if user := r.URL.Query().Get("user"); user != "" {
+		id, err := primitive.ObjectIDFromHex(user)
+		if err != nil {
+			return q, fmt.Errorf("invalid user ID")
+		}
+		q.UserID = id
+	}
+	if language := r.URL.Query().Get("language"); language != "" {
+		for _, known := range models.Languages {
+			if language == known {
+				q.Language = language
+			}
+		}
+		if q.Language == "" {
+			return q, fmt.Errorf("unknown language")
+		}
+	}
+	return q, nil
+}
+
+func (q feedQuery) title() string {
+	title := "Recent pastes"
+	if q.Author != "" {
+		title += " by " + q.Author
+	}
+	if q.Language != "" {
+		title += " in " + q.Language
+	}
+	return title
+}
+
+// Параметры запроса для ссылок на саму ленту
+func (q feedQuery) values() string {
+	v := url.Values{}
+	if !q.UserID.IsZero() {
+		v.Set("user", q.UserID.Hex())
+	}
+	if q.Language != "" {
+		v.Set("language", q.Language)
+	}
+	if len(v) == 0 {
+		return ""
+	}
+	return "?" + v.Encode()
+}
+
+func (q feedQuery) filter(now time.Time) bson.M {
+	filter := bson.M{
+		"visibility": bson.M{"$in": bson.A{models.VisibilityPublic, "", nil}},
+		"$or":        bson.A{bson.M{"expiresAt": bson.M{"$exists": false}}, bson.M{"expiresAt": bson.M{"$gt": now}}},
+	}
+	if !q.UserID.IsZero() {
+		filter["user_id"] = q.UserID
+	}
+	if q.Language != "" {
+		filter["language"] = q.Language
+	}
+	return filter
+}
+
+// Имя автора для заголовка ленты; email не раскрываем
+func feedAuthor(user models.User) string {
+	if user.Name != "" {
+		return user.Name
+	}
+	return "user " + user.ID.Hex()
+}
+
+func pasteTitle(p models.Paste) string {
+	if p.Title != "" {
+		return p.Title
+	}
+	return "Untitled"
+}
+
+// Начало содержимого; у паст с паролем и лимитом прочтений не показываем
+func pasteSummary(p models.Paste) string {
+	if p.HasPassword() {
+		return "Password protected paste"
+	}
+	if p.DeleteAfter > 0 {
+		return "Burn-after-reading paste"
+	}
+	content := p.Content
+	if utf8.RuneCountInString(content) > feedSummaryLen {
+		runes := []rune(content)
+		content = string(runes[:feedSummaryLen]) + "…"
+	}
+	return content
+}
+
+// Загружаем пасты ленты; ошибка уже отправлена клиенту, если ok == false
+func loadFeed(w http.ResponseWriter, r *http.Request) (feedQuery, []models.Paste, bool) {
+	q, err := parseFeedQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return q, nil, false
+	}
+
+	if !q.UserID.IsZero() {
+		var user models.User
+		err := GetCollection("users").FindOne(r.Context(), bson.M{"_id": q.UserID}).Decode(&user)
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return q, nil, false
+		} else if err != nil {
+			HandleError(w, err, http.StatusInternalServerError, "Failed to load user")
+			return q, nil, false
+		}
+		q.Author = feedAuthor(user)
+	}
+
+	cursor, err := GetCollection("pastes").Find(r.Context(), q.filter(time.Now()),
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(feedSize),
+	)
+	if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to load pastes")
+		return q, nil, false
+	}
+	var pastes []models.Paste
+	if err := cursor.All(r.Context(), &pastes); err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to decode pastes")
+		return q, nil, false
+	}
+	return q, pastes, true
+}
+
+func writeFeed(w http.ResponseWriter, contentType string, feed interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(feedMaxAge.Seconds())))
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		log.Printf("Ошибка записи ленты: %v", err)
+	}
+}
+
+// Atom 1.0
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title     string        `xml:"title"`
+	ID        string        `xml:"id"`
+	Link      atomLink      `xml:"link"`
+	Published string        `xml:"published"`
+	Updated   string        `xml:"updated"`
+	Author    *atomPerson   `xml:"author,omitempty"`
+	Category  *atomCategory `xml:"category,omitempty"`
+	Summary   string        `xml:"summary"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+func buildAtomFeed(base string, q feedQuery, pastes []models.Paste, now time.Time) atomFeed {
+	self := base + "/feed.atom" + q.values()
+	feed := atomFeed{
+		Title:   q.title(),
+		ID:      self,
+		Updated: now.UTC().Format(time.RFC3339),
+		Links:   []atomLink{{Href: self, Rel: "self"}, {Href: base + "/"}},
+	}
+	if len(pastes) > 0 {
+		feed.Updated = pasteUpdated(pastes[0]).UTC().Format(time.RFC3339)
+	}
+	for _, p := range pastes {
+		link := pasteLink(base, p.ID)
+		entry := atomEntry{
+			Title:     pasteTitle(p),
+			ID:        link,
+			Link:      atomLink{Href: link},
+			Published: p.CreatedAt.UTC().Format(time.RFC3339),
+			Updated:   pasteUpdated(p).UTC().Format(time.RFC3339),
+			Summary:   pasteSummary(p),
+		}
+		if q.Author != "" {
+			entry.Author = &atomPerson{Name: q.Author}
+		} else {
+			entry.Author = &atomPerson{Name: "anonymous"}
+		}
+		if p.Language != "" {
+			entry.Category = &atomCategory{Term: p.Language}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return feed
+}
+
+// RSS 2.0
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Category    string  `xml:"category,omitempty"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+func buildRSSFeed(base string, q feedQuery, pastes []models.Paste, now time.Time) rssFeed {
+	channel := rssChannel{
+		Title:         q.title(),
+		Link:          base + "/",
+		Description:   q.title() + " on pastebin",
+		LastBuildDate: now.UTC().Format(time.RFC1123Z),
+	}
+	for _, p := range pastes {
+		link := pasteLink(base, p.ID)
+		channel.Items = append(channel.Items, rssItem{
+			Title:       pasteTitle(p),
+			Link:        link,
+			GUID:        rssGUID{Value: link, IsPermaLink: true},
+			PubDate:     p.CreatedAt.UTC().Format(time.RFC1123Z),
+			Category:    p.Language,
+			Description: pasteSummary(p),
+		})
+	}
+	return rssFeed{Version: "2.0", Channel: channel}
+}
+
+// Время последнего изменения; у старых паст updatedAt нет
+func pasteUpdated(p models.Paste) time.Time {
+	if p.UpdatedAt.After(p.CreatedAt) {
+		return p.UpdatedAt
+	}
+	return p.CreatedAt
+}
+
+// GET /feed.atom?user=<id>&language=<lang>
+func AtomFeedHandler(w http.ResponseWriter, r *http.Request) {
+	q, pastes, ok := loadFeed(w, r)
+	if !ok {
+		return
+	}
+	writeFeed(w, "application/atom+xml; charset=utf-8", buildAtomFeed(requestBaseURL(r), q, pastes, time.Now()))
+}
+
+// GET /feed.rss?user=<id>&language=<lang>
+func RSSFeedHandler(w http.ResponseWriter, r *http.Request) {
+	q, pastes, ok := loadFeed(w, r)
+	if !ok {
+		return
+	}
+	writeFeed(w, "application/rss+xml; charset=utf-8", buildRSSFeed(requestBaseURL(r), q, pastes, time.Now()))
+}
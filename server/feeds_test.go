@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pastebin/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseFeedQuery(t *testing.T) {
+	userID := primitive.NewObjectID()
+	q, err := parseFeedQuery(httptest.NewRequest("GET", "/feed.atom?user="+userID.Hex()+"&language=go", nil))
+	if err != nil || q.UserID != userID || q.Language != "go" {
+		t.Fatalf("parseFeedQuery = %+v, %v", q, err)
+	}
+	if got := q.values(); got != "?language=go&user="+userID.Hex() {
+		t.Errorf("values = %q", got)
+	}
+
+	for _, bad := range []string{"/feed.rss?user=nope", "/feed.rss?language=cobol"} {
+		if _, err := parseFeedQuery(httptest.NewRequest("GET", bad, nil)); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestFeedsHideProtectedContent(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	pastes := []models.Paste{
+		{ID: primitive.NewObjectID(), Title: "open", Content: "fmt.Println()", Language: "go", CreatedAt: now},
+		{ID: primitive.NewObjectID(), Content: "secret", Password: "hash", CreatedAt: now.Add(-time.Hour)},
+	}
+	q := feedQuery{Author: "alice"}
+
+	atom, err := xml.Marshal(buildAtomFeed("https://paste.test", q, pastes, now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsedAtom atomFeed
+	if err := xml.Unmarshal(atom, &parsedAtom); err != nil {
+		t.Fatal(err)
+	}
+	if parsedAtom.Title != "Recent pastes by alice" || len(parsedAtom.Entries) != 2 {
+		t.Fatalf("atom = %+v", parsedAtom)
+	}
+	if e := parsedAtom.Entries[0]; e.Summary != "fmt.Println()" || e.Category.Term != "go" || e.ID != "https://paste.test/paste/"+pastes[0].ID.Hex() {
+		t.Errorf("entry = %+v", e)
+	}
+
+	rss, err := xml.Marshal(buildRSSFeed("https://paste.test", q, pastes, now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(rss), "secret") || strings.Contains(string(atom), "secret") {
+		t.Error("password-protected content leaked into the feed")
+	}
+	if !strings.Contains(string(rss), "<title>Untitled</title>") {
+		t.Errorf("rss = %s", rss)
+	}
+}
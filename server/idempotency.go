@@ -0,0 +1,232 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"pastebin/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Параметры Idempotency-Key
+const (
+	idempotencyHeader    = "Idempotency-Key"
+	idempotencyTTL       = 24 * time.Hour
+	idempotencyLockTTL   = time.Minute // Ключ брошенного запроса (упал сервер) снова можно занять
+	idempotencyMaxKeyLen = 255
+)
+
+// Заголовки ответа, которые повторяем при воспроизведении
+var idempotentHeaders = []string{"Content-Type", "Location", "Set-Cookie"}
+
+var (
+	errIdempotencyMismatch   = errors.New("idempotency key reused with a different request")
+	errIdempotencyInProgress = errors.New("request with this idempotency key is in progress")
+)
+
+// Ответ с ошибкой в формате вызывающего обработчика: WriteAPIError для API
+type idempotencyErrorFunc func(w http.ResponseWriter, status int, code, message string)
+
+func webIdempotencyError(w http.ResponseWriter, status int, code, message string) {
+	http.Error(w, message, status)
+}
+
+// Хэш запроса: тот же ключ с другим телом или на другом адресе — ошибка клиента
+func idempotencyRequestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+"\n"+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Занимаем ключ пользователя. claimed == false — запрос с ключом уже
+// выполнен, и record содержит его ответ
+func claimIdempotencyKey(ctx context.Context, userID primitive.ObjectID, key, hash string, now time.Time) (record models.IdempotencyKey, claimed bool, err error) {
+	collection := GetCollection("idempotency_keys")
+	for attempt := 0; attempt < 2; attempt++ {
+		record = models.IdempotencyKey{
+			ID:          primitive.NewObjectID(),
+			UserID:      userID,
+			Key:         key,
+			RequestHash: hash,
+			LockedAt:    now,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(idempotencyTTL),
+		}
+		_, err = collection.InsertOne(ctx, record)
+		if err == nil {
+			return record, true, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return record, false, err
+		}
+
+		var existing models.IdempotencyKey
+		err = collection.FindOne(ctx, bson.M{"user_id": userID, "key": key}).Decode(&existing)
+		if err == mongo.ErrNoDocuments {
+			continue // Удалён TTL-индексом между запросами
+		} else if err != nil {
+			return record, false, err
+		}
+		// TTL-индекс удаляет записи с задержкой до минуты
+		if !existing.ExpiresAt.After(now) {
+			if _, err := collection.DeleteOne(ctx, bson.M{"_id": existing.ID, "expiresAt": existing.ExpiresAt}); err != nil {
+				return record, false, err
+			}
+			continue
+		}
+		if existing.RequestHash != hash {
+			return existing, false, errIdempotencyMismatch
+		}
+		if existing.Completed {
+			return existing, false, nil
+		}
+		if now.Sub(existing.LockedAt) < idempotencyLockTTL {
+			return existing, false, errIdempotencyInProgress
+		}
+		// Забираем ключ брошенного запроса, если его не забрал кто-то другой
+		res, err := collection.UpdateOne(ctx,
+			bson.M{"_id": existing.ID, "completed": false, "lockedAt": existing.LockedAt},
+			bson.M{"$set": bson.M{"lockedAt": now}},
+		)
+		if err != nil {
+			return existing, false, err
+		}
+		if res.ModifiedCount == 0 {
+			return existing, false, errIdempotencyInProgress
+		}
+		existing.LockedAt = now
+		return existing, true, nil
+	}
+	return record, false, errIdempotencyInProgress
+}
+
+// Заголовки ответа для сохранения
+func idempotentResponseHeader(header http.Header) map[string][]string {
+	saved := make(map[string][]string)
+	for _, name := range idempotentHeaders {
+		if values := header.Values(name); len(values) > 0 {
+			saved[name] = values
+		}
+	}
+	return saved
+}
+
+// Запоминаем ответ на запрос
+func completeIdempotencyKey(ctx context.Context, id primitive.ObjectID, status int, header http.Header, body []byte) error {
+	_, err := GetCollection("idempotency_keys").UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"completed": true,
+		"status":    status,
+		"header":    idempotentResponseHeader(header),
+		"body":      body,
+	}})
+	return err
+}
+
+// Освобождаем ключ, если запрос не удался по вине сервера: повтор выполнится заново
+func releaseIdempotencyKey(ctx context.Context, id primitive.ObjectID) error {
+	_, err := GetCollection("idempotency_keys").DeleteOne(ctx, bson.M{"_id": id, "completed": false})
+	return err
+}
+
+// Повторяем сохранённый ответ
+func replayIdempotent(w http.ResponseWriter, record models.IdempotencyKey) {
+	for name, values := range record.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(record.Status)
+	w.Write(record.Body)
+}
+
+// Записывает ответ обработчика и одновременно копирует его для сохранения
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+// Обработка Idempotency-Key в начале обработчика. Без заголовка ничего не
+// делает. Иначе занимает ключ пользователя и возвращает ResponseWriter,
+// через который нужно писать ответ, и finish, который его сохраняет.
+// ok == false — ответ уже отправлен: воспроизведён или это ошибка
+func beginIdempotent(w http.ResponseWriter, r *http.Request, userID primitive.ObjectID, fail idempotencyErrorFunc) (out http.ResponseWriter, finish func(), ok bool) {
+	key := r.Header.Get(idempotencyHeader)
+	if key == "" {
+		return w, func() {}, true
+	}
+	if len(key) > idempotencyMaxKeyLen {
+		fail(w, http.StatusBadRequest, "invalid_idempotency_key", "Idempotency-Key must be at most 255 characters")
+		return w, nil, false
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, apiMaxBodyBytes))
+	if err != nil {
+		fail(w, http.StatusRequestEntityTooLarge, "body_too_large", "Request body is too large")
+		return w, nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	record, claimed, err := claimIdempotencyKey(ctx, userID, key, idempotencyRequestHash(r, body), time.Now())
+	switch {
+	case err == errIdempotencyMismatch:
+		fail(w, http.StatusUnprocessableEntity, "idempotency_key_reused", "Idempotency-Key was already used with a different request")
+		return w, nil, false
+	case err == errIdempotencyInProgress:
+		w.Header().Set("Retry-After", "1")
+		fail(w, http.StatusConflict, "idempotency_key_in_use", "A request with this Idempotency-Key is still in progress")
+		return w, nil, false
+	case err != nil:
+		log.Printf("Ошибка проверки Idempotency-Key: %v", err)
+		fail(w, http.StatusInternalServerError, "internal", "Database error")
+		return w, nil, false
+	case !claimed:
+		replayIdempotent(w, record)
+		return w, nil, false
+	}
+
+	rec := &idempotencyRecorder{ResponseWriter: w}
+	finish = func() {
+		// Клиент мог уже отключиться по таймауту — именно тогда он и повторит
+		// запрос, поэтому сохраняем ответ вне контекста запроса
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+			err = releaseIdempotencyKey(ctx, record.ID)
+		} else {
+			err = completeIdempotencyKey(ctx, record.ID, rec.status, rec.Header(), rec.body.Bytes())
+		}
+		if err != nil {
+			log.Printf("Ошибка сохранения Idempotency-Key: %v", err)
+		}
+	}
+	return rec, finish, true
+}
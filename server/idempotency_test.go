@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pastebin/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestIdempotencyRequestHash(t *testing.T) {
+	hash := func(method, path, body string) string {
+		return idempotencyRequestHash(httptest.NewRequest(method, path, nil), []byte(body))
+	}
+	base := hash("POST", "/api/v1/pastes", `{"content":"a"}`)
+	if base != hash("POST", "/api/v1/pastes", `{"content":"a"}`) {
+		t.Error("hash is not stable")
+	}
+	if base == hash("POST", "/api/v1/pastes", `{"content":"b"}`) || base == hash("POST", "/create-paste", `{"content":"a"}`) {
+		t.Error("hash ignores body or path")
+	}
+}
+
+func TestBeginIdempotentWithoutKey(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/api/v1/pastes", strings.NewReader("{}"))
+	out, finish, ok := beginIdempotent(w, r, primitive.NewObjectID(), WriteAPIError)
+	if !ok || out != w {
+		t.Fatal("request without Idempotency-Key must pass through")
+	}
+	finish()
+
+	r.Header.Set(idempotencyHeader, strings.Repeat("k", idempotencyMaxKeyLen+1))
+	if _, _, ok := beginIdempotent(w, r, primitive.NewObjectID(), WriteAPIError); ok || w.Code != http.StatusBadRequest {
+		t.Errorf("long key: ok=%v, status %d", ok, w.Code)
+	}
+}
+
+func TestIdempotencyRecorderAndReplay(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := &idempotencyRecorder{ResponseWriter: w}
+	rec.Header().Set("Location", "/api/v1/pastes/1")
+	rec.Header().Set("Content-Type", "application/json")
+	rec.Header().Set("X-Request-Id", "abc")
+	WriteJSON(rec, http.StatusCreated, map[string]string{"id": "1"})
+	if rec.status != http.StatusCreated || rec.body.String() != w.Body.String() {
+		t.Fatalf("recorded %d %q, sent %q", rec.status, rec.body.String(), w.Body.String())
+	}
+
+	saved := idempotentResponseHeader(rec.Header())
+	replay := httptest.NewRecorder()
+	replayIdempotent(replay, models.IdempotencyKey{Status: rec.status, Header: saved, Body: rec.body.Bytes()})
+	if replay.Code != http.StatusCreated || replay.Body.String() != w.Body.String() {
+		t.Errorf("replay = %d %q", replay.Code, replay.Body.String())
+	}
+	if replay.Header().Get("Location") != "/api/v1/pastes/1" || replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("replay headers = %v", replay.Header())
+	}
+	if replay.Header().Get("X-Request-Id") != "" {
+		t.Error("unrelated headers must not be replayed")
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import "strings"
+
+// Строка построчной разницы двух текстов
+type diffLine struct {
+	Kind    string // "same" / "add" / "del"
+	OldLine int    // Номер строки в старом тексте; 0 — там её нет
+	NewLine int    // Номер строки в новом тексте; 0 — там её нет
+	Text    string
+}
+
+// Несколько изменённых строк вместе с контекстом вокруг них
+type diffHunk struct {
+	Lines []diffLine
+}
+
+// Предел таблицы LCS: если изменённая середина больше, она показывается
+// целиком как замена
+const diffMaxCells = 4_000_000
+
+// Сколько неизменённых строк показывать вокруг изменений
+const diffContext = 3
+
+func splitLines(content string) []string {
+	return strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+}
+
+// Построчная разница: общее начало и конец отрезаются, середина
+// сравнивается по наибольшей общей подпоследовательности
+func lineDiff(old, new string) []diffLine {
+	a, b := splitLines(old), splitLines(new)
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	out := make([]diffLine, 0, len(a)+len(b)-prefix-suffix)
+	for i := 0; i < prefix; i++ {
+		out = append(out, diffLine{Kind: "same", OldLine: i + 1, NewLine: i + 1, Text: a[i]})
+	}
+	out = append(out, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], prefix, prefix)...)
+	for i := 0; i < suffix; i++ {
+		oi, ni := len(a)-suffix+i, len(b)-suffix+i
+		out = append(out, diffLine{Kind: "same", OldLine: oi + 1, NewLine: ni + 1, Text: a[oi]})
+	}
+	return out
+}
+
+// Разница изменённой середины; oldStart и newStart — сколько строк до неё
+func diffMiddle(a, b []string, oldStart, newStart int) []diffLine {
+	var out []diffLine
+	del := func(i int) { out = append(out, diffLine{Kind: "del", OldLine: oldStart + i + 1, Text: a[i]}) }
+	add := func(j int) { out = append(out, diffLine{Kind: "add", NewLine: newStart + j + 1, Text: b[j]}) }
+
+	if len(a)*len(b) > diffMaxCells {
+		for i := range a {
+			del(i)
+		}
+		for j := range b {
+			add(j)
+		}
+		return out
+	}
+
+	// lcs[i][j] — длина общей подпоследовательности a[i:] и b[j:]
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, diffLine{Kind: "same", OldLine: oldStart + i + 1, NewLine: newStart + j + 1, Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			del(i)
+			i++
+		default:
+			add(j)
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		del(i)
+	}
+	for ; j < len(b); j++ {
+		add(j)
+	}
+	return out
+}
+
+// Разбиваем разницу на куски вокруг изменений, как в unified diff
+func diffHunks(lines []diffLine, context int) []diffHunk {
+	var hunks []diffHunk
+	start, end := -1, -1
+	flush := func() {
+		if start >= 0 {
+			hunks = append(hunks, diffHunk{Lines: lines[start:end]})
+		}
+	}
+	for i, line := range lines {
+		if line.Kind == "same" {
+			continue
+		}
+		from, to := max(i-context, 0), min(i+context+1, len(lines))
+		if start >= 0 && from <= end {
+			end = to
+			continue
+		}
+		flush()
+		start, end = from, to
+	}
+	flush()
+	return hunks
+}
@@ -0,0 +1,699 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"pastebin/models"
+	"pastebin/utils"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Параметры совместного редактирования
+const (
+	liveCheckpointInterval = 30 * time.Second // Как часто изменения сохраняются новой ревизией пасты
+	liveHistoryLimit       = 1000             // Сколько последних операций хранится для отставших клиентов
+	liveClientBuffer       = 256
+	liveMaxDocument        = 1 << 20 // Кодовых единиц UTF-16
+	liveReadLimit          = 4 << 20 // Байт в одном сообщении
+	liveWriteTimeout       = 10 * time.Second
+	livePingInterval       = 30 * time.Second
+	livePongWait           = 60 * time.Second
+)
+
+var (
+	errLiveResync   = errors.New("revision is too old, reload the editor")
+	errLiveTooLarge = errors.New("paste is too large")
+	errLiveClosed   = errors.New("live editing is shutting down")
+)
+
+// Выделение в документе; pos == end — просто курсор
+type liveCursor struct {
+	Pos int `json:"pos"`
+	End int `json:"end"`
+}
+
+type liveUser struct {
+	ClientID string      `json:"client_id"`
+	Name     string      `json:"name"`
+	Cursor   *liveCursor `json:"cursor,omitempty"`
+}
+
+// Сообщение протокола в обе стороны.
+// Клиент: op (операция над ревизией revision), cursor.
+// Сервер: init, ack (операция клиента принята как revision), op, cursor,
+// join, leave, checkpoint (сохранена ревизия пасты), error
+type liveMessage struct {
+	Type          string      `json:"type"`
+	Revision      int         `json:"revision"`
+	Op            *textOp     `json:"op,omitempty"`
+	Cursor        *liveCursor `json:"cursor,omitempty"`
+	ClientID      string      `json:"client_id,omitempty"`
+	Name          string      `json:"name,omitempty"`
+	Content       *string     `json:"content,omitempty"`
+	Users         []liveUser  `json:"users,omitempty"`
+	PasteRevision int         `json:"paste_revision,omitempty"`
+	Message       string      `json:"message,omitempty"`
+}
+
+// Подключение редактора
+type liveClient struct {
+	id     string
+	userID primitive.ObjectID
+	name   string
+	send   chan []byte
+	cursor *liveCursor
+}
+
+// Документ, который сейчас редактируют. Порядок операций задаёт сервер:
+// операция клиента, основанная на старой ревизии, переносится через все
+// принятые после неё
+type liveSession struct {
+	pasteID primitive.ObjectID
+	done    chan struct{}
+
+	mu       sync.Mutex
+	doc      []uint16
+	revision int      // Операций принято с начала сессии
+	history  []textOp // Последние операции: history[i] переводит ревизию first+i в first+i+1
+	first    int
+	clients  map[*liveClient]struct{}
+	closed   bool
+
+	// Состояние в базе: содержимое и ревизия пасты при последнем сохранении
+	// и операции после него
+	saved         []uint16
+	pasteRevision int
+	unsaved       []textOp
+}
+
+func newLiveSession(p models.Paste) *liveSession {
+	doc := utf16.Encode([]rune(p.Content))
+	return &liveSession{
+		pasteID:       p.ID,
+		done:          make(chan struct{}),
+		doc:           doc,
+		clients:       make(map[*liveClient]struct{}),
+		saved:         doc,
+		pasteRevision: p.Revision,
+	}
+}
+
+// Все открытые сессии
+type liveRegistry struct {
+	mu       sync.Mutex
+	sessions map[primitive.ObjectID]*liveSession
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+var liveSessions = &liveRegistry{sessions: make(map[primitive.ObjectID]*liveSession)}
+
+// Подключаем клиента к сессии пасты, открывая её при необходимости.
+// reg.mu не держится, пока ждём s.mu: сессия может быть занята сохранением
+func (reg *liveRegistry) join(p models.Paste, c *liveClient) (*liveSession, error) {
+	for {
+		reg.mu.Lock()
+		if reg.closed {
+			reg.mu.Unlock()
+			return nil, errLiveClosed
+		}
+		s, ok := reg.sessions[p.ID]
+		if !ok {
+			s = newLiveSession(p)
+			reg.sessions[p.ID] = s
+			reg.wg.Add(1)
+			go func() {
+				defer reg.wg.Done()
+				s.run()
+			}()
+		}
+		reg.mu.Unlock()
+
+		if s.add(c) {
+			return s, nil
+		}
+		// Последний клиент только что ушёл: сессия закрыта, но ещё в реестре
+		reg.remove(s)
+	}
+}
+
+// Убираем закрытую сессию из реестра, если её ещё не заменили новой
+func (reg *liveRegistry) remove(s *liveSession) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.sessions[s.pasteID] == s {
+		delete(reg.sessions, s.pasteID)
+	}
+}
+
+// Отключаем клиента; последний закрывает сессию с сохранением изменений
+func (reg *liveRegistry) leave(s *liveSession, c *liveClient) {
+	s.mu.Lock()
+	if _, ok := s.clients[c]; ok {
+		s.drop(c)
+		s.broadcast(nil, liveMessage{Type: "leave", ClientID: c.id})
+	}
+	empty := len(s.clients) == 0 && !s.closed
+	if empty {
+		s.closed = true
+		close(s.done)
+	}
+	s.mu.Unlock()
+	if empty {
+		reg.remove(s)
+	}
+}
+
+// Закрываем сессии пасты: выключен режим или паста удалена
+func (reg *liveRegistry) end(pasteID primitive.ObjectID, reason string) {
+	reg.mu.Lock()
+	s, ok := reg.sessions[pasteID]
+	reg.mu.Unlock()
+	if ok {
+		s.disconnectAll(reason)
+	}
+}
+
+// Отключаем от сессии пасты клиентов пользователя, у которого забрали доступ
+func (reg *liveRegistry) kick(pasteID, userID primitive.ObjectID, reason string) {
+	reg.mu.Lock()
+	s, ok := reg.sessions[pasteID]
+	reg.mu.Unlock()
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		if c.userID == userID {
+			s.sendTo(c, liveMessage{Type: "error", Message: reason})
+			s.drop(c)
+		}
+	}
+}
+
+// Закрываем все сессии при остановке сервера и дожидаемся их сохранения
+func CloseLiveSessions(ctx context.Context) error {
+	reg := liveSessions
+	reg.mu.Lock()
+	reg.closed = true
+	sessions := make([]*liveSession, 0, len(reg.sessions))
+	for _, s := range reg.sessions {
+		sessions = append(sessions, s)
+	}
+	reg.mu.Unlock()
+	for _, s := range sessions {
+		s.disconnectAll("Server is restarting")
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		reg.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Периодически сохраняем документ; после ухода последнего клиента — в последний раз
+func (s *liveSession) run() {
+	ticker := time.NewTicker(liveCheckpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.checkpoint()
+		case <-s.done:
+			s.checkpoint()
+			return
+		}
+	}
+}
+
+func encodeLiveMessage(msg liveMessage) []byte {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Ошибка кодирования сообщения редактора: %v", err)
+	}
+	return data
+}
+
+// Отправка без ожидания; отстающий клиент отключается и при
+// переподключении получит документ заново. Вызывается под s.mu
+func (s *liveSession) sendTo(c *liveClient, msg liveMessage) {
+	select {
+	case c.send <- encodeLiveMessage(msg):
+	default:
+		s.drop(c)
+	}
+}
+
+// Всем, кроме except
+func (s *liveSession) broadcast(except *liveClient, msg liveMessage) {
+	data := encodeLiveMessage(msg)
+	for c := range s.clients {
+		if c == except {
+			continue
+		}
+		select {
+		case c.send <- data:
+		default:
+			s.drop(c)
+		}
+	}
+}
+
+// Под s.mu; закрытый канал отправки закрывает соединение
+func (s *liveSession) drop(c *liveClient) {
+	if _, ok := s.clients[c]; ok {
+		delete(s.clients, c)
+		close(c.send)
+	}
+}
+
+func (s *liveSession) disconnectAll(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		s.sendTo(c, liveMessage{Type: "error", Message: reason})
+		s.drop(c)
+	}
+}
+
+// Добавляем клиента; false — сессия уже закрыта
+func (s *liveSession) add(c *liveClient) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	users := make([]liveUser, 0, len(s.clients))
+	for other := range s.clients {
+		users = append(users, liveUser{ClientID: other.id, Name: other.name, Cursor: other.cursor})
+	}
+	s.clients[c] = struct{}{}
+
+	content := string(utf16.Decode(s.doc))
+	s.sendTo(c, liveMessage{Type: "init", Revision: s.revision, Content: &content, ClientID: c.id, Users: users, PasteRevision: s.pasteRevision})
+	s.broadcast(c, liveMessage{Type: "join", ClientID: c.id, Name: c.name})
+	return true
+}
+
+// Операции, принятые после ревизии revision
+func (s *liveSession) since(revision int) ([]textOp, error) {
+	if revision < s.first || revision > s.revision {
+		return nil, errLiveResync
+	}
+	return s.history[revision-s.first:], nil
+}
+
+// Применяем операцию к документу и рассылаем её; author == nil — правка
+// не из сессии. Вызывается под s.mu
+func (s *liveSession) commit(author *liveClient, op textOp) error {
+	doc, err := op.apply(s.doc)
+	if err != nil {
+		return err
+	}
+	if len(doc) > liveMaxDocument {
+		return errLiveTooLarge
+	}
+	s.doc = doc
+	s.revision++
+	s.history = append(s.history, op)
+	if extra := len(s.history) - liveHistoryLimit; extra > 0 {
+		s.history = append([]textOp(nil), s.history[extra:]...)
+		s.first += extra
+	}
+	for c := range s.clients {
+		if c.cursor != nil {
+			c.cursor = &liveCursor{Pos: op.transformIndex(c.cursor.Pos), End: op.transformIndex(c.cursor.End)}
+		}
+	}
+
+	msg := liveMessage{Type: "op", Revision: s.revision, Op: &op}
+	if author != nil {
+		msg.ClientID = author.id
+		s.sendTo(author, liveMessage{Type: "ack", Revision: s.revision})
+	}
+	s.broadcast(author, msg)
+	return nil
+}
+
+// Операция клиента над ревизией revision
+func (s *liveSession) receive(c *liveClient, revision int, op textOp) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.clients[c]; !ok {
+		return errLiveClosed // Клиент уже отключён
+	}
+	concurrent, err := s.since(revision)
+	if err != nil {
+		return err
+	}
+	for _, other := range concurrent {
+		if op, _, err = transformOps(op, other); err != nil {
+			return err
+		}
+	}
+	if err := s.commit(c, op); err != nil {
+		return err
+	}
+	s.unsaved = append(s.unsaved, op)
+	return nil
+}
+
+// Курсор клиента в ревизии revision
+func (s *liveSession) moveCursor(c *liveClient, revision int, cursor liveCursor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	concurrent, err := s.since(revision)
+	if err != nil {
+		return err
+	}
+	for _, op := range concurrent {
+		cursor = liveCursor{Pos: op.transformIndex(cursor.Pos), End: op.transformIndex(cursor.End)}
+	}
+	cursor.Pos = max(0, min(cursor.Pos, len(s.doc)))
+	cursor.End = max(0, min(cursor.End, len(s.doc)))
+	c.cursor = &cursor
+	s.broadcast(c, liveMessage{Type: "cursor", ClientID: c.id, Name: c.name, Cursor: &cursor})
+	return nil
+}
+
+// Сохраняем документ новой ревизией пасты. Если паста изменилась вне
+// сессии, переносим ту правку поверх несохранённых операций и пробуем снова.
+// Запросы к базе идут без s.mu: редакторы продолжают работать, а операции,
+// принятые во время записи, остаются несохранёнными до следующего раза.
+// Вызывается только из run, поэтому два сохранения не пересекаются
+func (s *liveSession) checkpoint() {
+	if db == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := GetCollection("pastes")
+	for attempt := 0; attempt < 3; attempt++ {
+		s.mu.Lock()
+		doc, revision, pasteRevision, saving := s.doc, s.revision, s.pasteRevision, len(s.unsaved)
+		s.mu.Unlock()
+		if saving == 0 {
+			return
+		}
+
+		var updated models.Paste
+		err := collection.FindOneAndUpdate(ctx,
+			bson.M{"_id": s.pasteID, "revision": pasteRevision},
+			bson.M{
+				"$set":   bson.M{"content": string(utf16.Decode(doc)), "updatedAt": time.Now()},
+				"$inc":   bson.M{"revision": 1},
+				"$unset": bson.M{"updatedBy": "", "updatedByName": ""}, // У совместной правки нет одного автора
+			},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&updated)
+		if err == nil {
+			s.mu.Lock()
+			s.saved, s.pasteRevision = doc, updated.Revision
+			s.unsaved = append([]textOp(nil), s.unsaved[saving:]...)
+			s.broadcast(nil, liveMessage{Type: "checkpoint", Revision: revision, PasteRevision: updated.Revision})
+			s.mu.Unlock()
+			events.Publish(PasteUpdated{Paste: updated})
+			return
+		} else if err != mongo.ErrNoDocuments {
+			log.Printf("Ошибка сохранения совместной правки пасты %s: %v", s.pasteID.Hex(), err)
+			return
+		}
+
+		var current models.Paste
+		err = collection.FindOne(ctx, bson.M{"_id": s.pasteID}).Decode(&current)
+		if err == mongo.ErrNoDocuments {
+			s.mu.Lock()
+			for c := range s.clients {
+				s.sendTo(c, liveMessage{Type: "error", Message: "Paste was deleted"})
+				s.drop(c)
+			}
+			s.unsaved = nil
+			s.mu.Unlock()
+			return
+		} else if err != nil {
+			log.Printf("Ошибка загрузки пасты %s: %v", s.pasteID.Hex(), err)
+			return
+		}
+		s.mu.Lock()
+		err = s.mergeExternal(current)
+		s.mu.Unlock()
+		if err != nil {
+			log.Printf("Не удалось объединить правку пасты %s: %v", s.pasteID.Hex(), err)
+			return
+		}
+	}
+}
+
+// Правка из формы или API, сделанная поверх сохранённого содержимого:
+// переносим её через несохранённые операции сессии и применяем к документу.
+// Вызывается под s.mu
+func (s *liveSession) mergeExternal(p models.Paste) error {
+	external := utf16.Encode([]rune(p.Content))
+	op := diffOp(s.saved, external)
+	rebased := make([]textOp, len(s.unsaved))
+	for i, local := range s.unsaved {
+		var err error
+		if op, rebased[i], err = transformOps(op, local); err != nil {
+			return err
+		}
+	}
+	if err := s.commit(nil, op); err != nil {
+		return err
+	}
+	// Несохранённые операции теперь отсчитываются от содержимого в базе
+	s.saved, s.pasteRevision, s.unsaved = external, p.Revision, rebased
+	return nil
+}
+
+// Имя в списке редакторов; email не показываем целиком
+func liveUserName(u *models.User) string {
+	if u.Name != "" {
+		return u.Name
+	}
+	name, _, _ := strings.Cut(u.Email, "@")
+	return name
+}
+
+func generateLiveKey() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Паста и пользователь, которому можно редактировать её вживую: владелец
+// или тот, кому паста открыта на редактирование. Ключ из ссылки доступа
+// не даёт, но WebSocket всегда требует его: CheckOrigin у upgrader
+// пропускает любой сайт, а куки браузер отправит и чужой странице.
+// Иначе ответ уже отправлен
+func liveEditTarget(w http.ResponseWriter, r *http.Request, requireKey bool) (models.Paste, *models.User, bool) {
+	var paste models.Paste
+	user := currentUser(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return paste, nil, false
+	}
+	pasteID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid paste ID", http.StatusBadRequest)
+		return paste, nil, false
+	}
+	err = GetCollection("pastes").FindOne(r.Context(), bson.M{"_id": pasteID}).Decode(&paste)
+	if err == mongo.ErrNoDocuments || (err == nil && (paste.IsExpired(time.Now()) || paste.LiveKey == "")) {
+		http.Error(w, "Live editing is not enabled for this paste", http.StatusNotFound)
+		return paste, nil, false
+	} else if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to load paste")
+		return paste, nil, false
+	}
+	// Редакторам пароль пасты не нужен, остальных сюда не пускаем вовсе
+	if !paste.CanEdit(user.ID) || !pasteUnlocked(paste, user.ID, "") {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return paste, nil, false
+	}
+	key := r.URL.Query().Get("key")
+	if requireKey && subtle.ConstantTimeCompare([]byte(key), []byte(paste.LiveKey)) != 1 {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return paste, nil, false
+	}
+	return paste, user, true
+}
+
+// Меняем ключ совместного редактирования, если режим включён: старая
+// ссылка, которую видел лишившийся доступа пользователь, больше не работает
+func rotateLiveKey(ctx context.Context, pasteID primitive.ObjectID) error {
+	key, err := generateLiveKey()
+	if err != nil {
+		return err
+	}
+	_, err = GetCollection("pastes").UpdateOne(ctx,
+		bson.M{"_id": pasteID, "liveKey": bson.M{"$exists": true}},
+		bson.M{"$set": bson.M{"liveKey": key}})
+	return err
+}
+
+// POST /pastes/{id}/live — владелец включает (enabled=true) или выключает
+// совместное редактирование; при включении создаётся новая ссылка
+func ToggleLiveEditHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	pasteID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid paste ID", http.StatusBadRequest)
+		return
+	}
+
+	filter := bson.M{"_id": pasteID, "user_id": userID}
+	update := bson.M{"$unset": bson.M{"liveKey": ""}}
+	message := "Live editing disabled"
+	if r.FormValue("enabled") == "true" {
+		key, err := generateLiveKey()
+		if err != nil {
+			HandleError(w, err, http.StatusInternalServerError, "Failed to generate link")
+			return
+		}
+		// Содержимое паст с лимитом прочтений нельзя раздавать редакторам
+		filter["deleteAfter"] = bson.M{"$lte": 0}
+		update = bson.M{"$set": bson.M{"liveKey": key}}
+		message = "Live editing enabled"
+	}
+
+	result, err := GetCollection("pastes").UpdateOne(r.Context(), filter, update)
+	if err != nil || result.MatchedCount == 0 {
+		http.Error(w, "Paste not found, unauthorized or burn-after-reading", http.StatusForbidden)
+		return
+	}
+	hotPastes.Invalidate(pasteID)
+	if r.FormValue("enabled") != "true" {
+		liveSessions.end(pasteID, "Live editing was disabled by the owner")
+	}
+
+	setFlash(w, message)
+	http.Redirect(w, r, fmt.Sprintf("/paste/%s", pasteID.Hex()), http.StatusSeeOther)
+}
+
+// GET /paste/{id}/live?key= — страница редактора
+func LiveEditPageHandler(w http.ResponseWriter, r *http.Request) {
+	paste, _, ok := liveEditTarget(w, r, false)
+	if !ok {
+		return
+	}
+	w.Header().Set("Cache-Control", "private, no-store")
+	render(w, r, "liveedit.html", paste)
+}
+
+// GET /paste/{id}/live/ws?key= — WebSocket редактора
+func LiveEditSocketHandler(w http.ResponseWriter, r *http.Request) {
+	paste, user, ok := liveEditTarget(w, r, true)
+	if !ok {
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("Ошибка WebSocket соединения:", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(liveReadLimit)
+
+	id, err := generateLiveKey()
+	if err != nil {
+		log.Printf("Ошибка генерации ID клиента: %v", err)
+		return
+	}
+	client := &liveClient{id: id[:12], userID: user.ID, name: liveUserName(user), send: make(chan []byte, liveClientBuffer)}
+	session, err := liveSessions.join(paste, client)
+	if err != nil {
+		conn.WriteJSON(liveMessage{Type: "error", Message: err.Error()})
+		return
+	}
+	defer liveSessions.leave(session, client)
+
+	go writeLiveMessages(conn, client.send)
+
+	conn.SetReadDeadline(time.Now().Add(livePongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(livePongWait))
+	})
+	for {
+		var msg liveMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("Ошибка чтения сообщения редактора: %v", err)
+			}
+			return
+		}
+		switch msg.Type {
+		case "op":
+			if msg.Op != nil {
+				err = session.receive(client, msg.Revision, *msg.Op)
+			}
+		case "cursor":
+			if msg.Cursor != nil {
+				err = session.moveCursor(client, msg.Revision, *msg.Cursor)
+			}
+		}
+		if err != nil {
+			// Клиент рассинхронизировался: он переподключится и получит документ заново
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()),
+				time.Now().Add(liveWriteTimeout))
+			return
+		}
+	}
+}
+
+// Единственный писатель в соединение; закрытие канала закрывает соединение
+func writeLiveMessages(conn *websocket.Conn, send <-chan []byte) {
+	ping := time.NewTicker(livePingInterval)
+	defer ping.Stop()
+	defer conn.Close()
+	for {
+		select {
+		case data, ok := <-send:
+			conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pastebin/models"
+	"pastebin/utils"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func newTestLiveClient(id string) *liveClient {
+	return &liveClient{id: id, name: id, send: make(chan []byte, liveClientBuffer)}
+}
+
+// Сообщения, отправленные клиенту
+func drainLive(t *testing.T, c *liveClient) []liveMessage {
+	t.Helper()
+	var out []liveMessage
+	for {
+		select {
+		case data, ok := <-c.send:
+			if !ok {
+				return out
+			}
+			var msg liveMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, msg)
+		default:
+			return out
+		}
+	}
+}
+
+func TestLiveSessionConcurrentOps(t *testing.T) {
+	s := newLiveSession(models.Paste{ID: primitive.NewObjectID(), Content: "ab", Revision: 3})
+	alice, bob := newTestLiveClient("alice"), newTestLiveClient("bob")
+	s.add(alice)
+	s.add(bob)
+	if msgs := drainLive(t, alice); len(msgs) != 2 || msgs[0].Type != "init" || *msgs[0].Content != "ab" || msgs[1].Type != "join" {
+		t.Fatalf("alice got %+v", msgs)
+	}
+	drainLive(t, bob)
+
+	// Оба правят ревизию 0, не зная друг о друге
+	var opA, opB textOp
+	opA.retain(1).insert(u16("X")).retain(1)
+	opB.retain(2).insert(u16("Y"))
+	if err := s.receive(alice, 0, opA); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.receive(bob, 0, opB); err != nil {
+		t.Fatal(err)
+	}
+	if got := str16(s.doc); got != "aXbY" || s.revision != 2 || len(s.unsaved) != 2 {
+		t.Fatalf("doc %q, revision %d, unsaved %d", got, s.revision, len(s.unsaved))
+	}
+
+	msgs := drainLive(t, alice)
+	if len(msgs) != 2 || msgs[0].Type != "ack" || msgs[1].Type != "op" || msgs[1].ClientID != "bob" {
+		t.Fatalf("alice got %+v", msgs)
+	}
+	// Правка Боба пришла Алисе уже перенесённой через её вставку
+	doc, err := msgs[1].Op.apply(u16("aXb"))
+	if err != nil || str16(doc) != "aXbY" {
+		t.Errorf("alice applies %q, %v", str16(doc), err)
+	}
+
+	if err := s.receive(alice, 5, opA); err != errLiveResync {
+		t.Errorf("будущая ревизия: %v", err)
+	}
+}
+
+func TestLiveSessionMergesExternalEdit(t *testing.T) {
+	s := newLiveSession(models.Paste{ID: primitive.NewObjectID(), Content: "one\ntwo\n", Revision: 1})
+	c := newTestLiveClient("c")
+	s.add(c)
+
+	var op textOp
+	op.retain(8).insert(u16("three\n"))
+	if err := s.receive(c, 0, op); err != nil {
+		t.Fatal(err)
+	}
+
+	// Пока сессия не сохранилась, пасту поправили через форму
+	if err := s.mergeExternal(models.Paste{Content: "ONE\ntwo\n", Revision: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if got := str16(s.doc); got != "ONE\ntwo\nthree\n" {
+		t.Errorf("doc = %q", got)
+	}
+	// Несохранённые операции теперь ведут от содержимого в базе к документу
+	doc := s.saved
+	for _, op := range s.unsaved {
+		var err error
+		if doc, err = op.apply(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if str16(doc) != str16(s.doc) || s.pasteRevision != 2 {
+		t.Errorf("saved+unsaved = %q, revision %d", str16(doc), s.pasteRevision)
+	}
+}
+
+func TestLiveRegistryClosesEmptySession(t *testing.T) {
+	reg := &liveRegistry{sessions: make(map[primitive.ObjectID]*liveSession)}
+	paste := models.Paste{ID: primitive.NewObjectID(), Content: "x"}
+	c := newTestLiveClient("c")
+	s, err := reg.join(paste, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.leave(s, c)
+	reg.wg.Wait()
+	if len(reg.sessions) != 0 || !s.closed {
+		t.Error("сессия без клиентов должна закрыться")
+	}
+}
+
+func TestLiveRegistryKicksRevokedUser(t *testing.T) {
+	reg := &liveRegistry{sessions: make(map[primitive.ObjectID]*liveSession)}
+	paste := models.Paste{ID: primitive.NewObjectID(), Content: "x"}
+	revoked, stays := newTestLiveClient("revoked"), newTestLiveClient("stays")
+	revoked.userID, stays.userID = primitive.NewObjectID(), primitive.NewObjectID()
+	s, _ := reg.join(paste, revoked)
+	reg.join(paste, stays)
+
+	reg.kick(paste.ID, revoked.userID, "access removed")
+	if _, ok := s.clients[revoked]; ok {
+		t.Error("клиент без доступа остался в сессии")
+	}
+	if msgs := drainLive(t, revoked); len(msgs) == 0 || msgs[len(msgs)-1].Type != "error" {
+		t.Errorf("отключённый клиент получил %+v", msgs)
+	}
+	if _, ok := s.clients[stays]; !ok {
+		t.Error("отключён чужой клиент")
+	}
+	reg.leave(s, stays)
+	reg.wg.Wait()
+}
+
+func TestLiveRegistryReplacesClosingSession(t *testing.T) {
+	reg := &liveRegistry{sessions: make(map[primitive.ObjectID]*liveSession)}
+	paste := models.Paste{ID: primitive.NewObjectID(), Content: "x"}
+	first, _ := reg.join(paste, newTestLiveClient("a"))
+
+	// Последний клиент ушёл, сессия сохраняется, но ещё в реестре
+	first.mu.Lock()
+	first.closed = true
+	close(first.done)
+	first.mu.Unlock()
+
+	c := newTestLiveClient("b")
+	second, err := reg.join(paste, c)
+	if err != nil || second == first || reg.sessions[paste.ID] != second {
+		t.Fatalf("join в закрывающуюся сессию: %v", err)
+	}
+	reg.remove(first)
+	if reg.sessions[paste.ID] != second {
+		t.Error("старая сессия убрала из реестра новую")
+	}
+	reg.leave(second, c)
+	reg.wg.Wait()
+}
+
+func TestLiveSessionsEndWhenPasteDeleted(t *testing.T) {
+	paste := models.Paste{ID: primitive.NewObjectID(), Content: "x"}
+	c := newTestLiveClient("c")
+	s, err := liveSessions.join(paste, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ждём последнего сохранения сессии: оно читает общую db, которую
+	// подменяют следующие тесты
+	defer liveSessions.wg.Wait()
+	defer liveSessions.leave(s, c)
+
+	endLiveSessionsOnEvent(PasteDeleted{Paste: paste})
+	msgs := drainLive(t, c)
+	if len(msgs) == 0 || msgs[len(msgs)-1].Message != "Paste was deleted" {
+		t.Errorf("редактор удалённой пасты получил %+v", msgs)
+	}
+	if _, ok := s.clients[c]; ok {
+		t.Error("редактор удалённой пасты остался в сессии")
+	}
+}
+
+func TestLiveEditTargetAccess(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	pasteID, owner, editor, stranger := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	paste := bson.D{
+		{Key: "_id", Value: pasteID}, {Key: "user_id", Value: owner},
+		{Key: "liveKey", Value: "secret"}, {Key: "password", Value: "$2a$10$hash"},
+		{Key: "shares", Value: bson.A{bson.D{{Key: "user_id", Value: editor}, {Key: "role", Value: models.ShareEdit}}}},
+	}
+
+	cases := []struct {
+		name       string
+		userID     primitive.ObjectID
+		key        string
+		requireKey bool
+		want       int
+	}{
+		{"владелец без ключа", owner, "", false, http.StatusOK},
+		{"редактор по WebSocket с ключом", editor, "secret", true, http.StatusOK},
+		{"редактор по WebSocket без ключа", editor, "", true, http.StatusForbidden},
+		{"чужой с ключом", stranger, "secret", false, http.StatusForbidden},
+		{"чужой по WebSocket с ключом", stranger, "secret", true, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		mt.Run(c.name, func(mt *mtest.T) {
+			useTestDB(mt)
+			mt.AddMockResponses(
+				mtest.CreateCursorResponse(0, "pastebin.users", mtest.FirstBatch, bson.D{{Key: "_id", Value: c.userID}}),
+				mtest.CreateCursorResponse(0, "pastebin.pastes", mtest.FirstBatch, paste),
+			)
+			req := httptest.NewRequest(http.MethodGet, "/paste/"+pasteID.Hex()+"/live?key="+c.key, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": pasteID.Hex()})
+			req.AddCookie(&http.Cookie{Name: "token", Value: utils.GenerateToken(c.userID, "user@example.com")})
+			rec := httptest.NewRecorder()
+			_, user, ok := liveEditTarget(rec, req, c.requireKey)
+			if ok != (c.want == http.StatusOK) || rec.Code != c.want {
+				mt.Errorf("ok = %v, статус %d, want %d", ok, rec.Code, c.want)
+			}
+			if ok && user.ID != c.userID {
+				mt.Errorf("user = %v, want %v", user.ID, c.userID)
+			}
+		})
+	}
+}
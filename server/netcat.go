@@ -0,0 +1,250 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"pastebin/models"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Приём паст по голому TCP: cat build.log | nc host 9999
+const (
+	netcatIdleTimeout = 5 * time.Second  // Тишина дольше этого считается концом ввода
+	netcatMaxDuration = 60 * time.Second // Общий лимит на одно соединение
+	netcatMaxConns    = 64
+)
+
+// Ссылка, если BASE_URL не задан: у TCP-соединения нет заголовка Host
+const defaultBaseURL = "http://localhost:8080"
+
+// Адрес сайта для ссылок из приёма паст вне HTTP
+func listenerBaseURL(service string) string {
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+		log.Printf("BASE_URL не задан, %s отвечает ссылками на %s", service, baseURL)
+	}
+	return baseURL
+}
+
+type NetcatServer struct {
+	listener    net.Listener
+	allow       func(ip string) bool // Общий с HTTP лимит запросов
+	save        func(ctx context.Context, paste models.Paste) error
+	baseURL     string
+	idleTimeout time.Duration
+	maxDuration time.Duration
+	maxBytes    int64
+	slots       chan struct{}
+	conns       connGroup
+}
+
+// Открытые соединения фонового сервера, чтобы дождаться или закрыть их при остановке
+type connGroup struct {
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool // wait уже начался, новые соединения не принимаются
+}
+
+// Учитываем соединение; false — сервер останавливается и его надо закрыть
+func (g *connGroup) add(conn net.Conn) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return false
+	}
+	if g.conns == nil {
+		g.conns = make(map[net.Conn]struct{})
+	}
+	g.conns[conn] = struct{}{}
+	g.wg.Add(1)
+	return true
+}
+
+func (g *connGroup) done(conn net.Conn) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.conns, conn)
+	g.wg.Done()
+}
+
+// Ждём завершения соединений; по истечении ctx закрываем оставшиеся
+func (g *connGroup) wait(ctx context.Context) error {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		for conn := range g.conns {
+			conn.Close()
+		}
+		g.mu.Unlock()
+		<-finished
+		return ctx.Err()
+	}
+}
+
+// Слушаем addr и принимаем пасты в фоне до вызова Shutdown
+func StartNetcatServer(addr string, allow func(ip string) bool) (*NetcatServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := newNetcatServer(listener, allow, insertPaste, listenerBaseURL("netcat"))
+	log.Printf("Приём паст через netcat на %s", listener.Addr())
+	go s.serve()
+	return s, nil
+}
+
+func newNetcatServer(listener net.Listener, allow func(string) bool, save func(context.Context, models.Paste) error, baseURL string) *NetcatServer {
+	return &NetcatServer{
+		listener:    listener,
+		allow:       allow,
+		save:        save,
+		baseURL:     baseURL,
+		idleTimeout: netcatIdleTimeout,
+		maxDuration: netcatMaxDuration,
+		maxBytes:    rawUploadMaxBytes,
+		slots:       make(chan struct{}, netcatMaxConns),
+	}
+}
+
+func (s *NetcatServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+func (s *NetcatServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			log.Printf("Ошибка приёма netcat-соединения: %v", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		// Слишком много одновременных соединений — сразу закрываем
+		select {
+		case s.slots <- struct{}{}:
+		default:
+			fmt.Fprintln(conn, "Server is busy, try again later")
+			conn.Close()
+			continue
+		}
+
+		if !s.conns.add(conn) {
+			<-s.slots
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer s.conns.done(conn)
+			defer func() { <-s.slots }()
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *NetcatServer) handle(conn net.Conn) {
+	ip, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil || (s.allow != nil && !s.allow(ip)) {
+		fmt.Fprintln(conn, "Too many requests")
+		return
+	}
+
+	content, err := s.readContent(conn)
+	if err != nil {
+		fmt.Fprintln(conn, err.Error())
+		return
+	}
+	if len(content) == 0 {
+		fmt.Fprintln(conn, "Nothing to paste")
+		return
+	}
+
+	paste, err := newPaste(primitive.NilObjectID, pasteInput{Content: string(content)}, time.Now())
+	if err != nil {
+		fmt.Fprintln(conn, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.save(ctx, paste); err != nil {
+		log.Printf("Ошибка сохранения netcat-пасты: %v", err)
+		fmt.Fprintln(conn, "Failed to save paste")
+		return
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(s.idleTimeout))
+	fmt.Fprintln(conn, pasteLink(s.baseURL, paste.ID))
+}
+
+var (
+	errNetcatTooLarge = errors.New("Paste is too large")
+	errNetcatTooSlow  = errors.New("Upload took too long")
+)
+
+// Читаем до EOF или паузы дольше idleTimeout. Не уложившаяся в общий
+// лимит времени загрузка не сохраняется: текст был бы обрезан
+func (s *NetcatServer) readContent(conn net.Conn) ([]byte, error) {
+	var buf bytes.Buffer
+	chunk := make([]byte, 32<<10)
+	deadline := time.Now().Add(s.maxDuration)
+	for {
+		readDeadline := time.Now().Add(s.idleTimeout)
+		if readDeadline.After(deadline) {
+			readDeadline = deadline
+		}
+		conn.SetReadDeadline(readDeadline)
+
+		n, err := conn.Read(chunk)
+		buf.Write(chunk[:n])
+		if int64(buf.Len()) > s.maxBytes {
+			return nil, errNetcatTooLarge
+		}
+
+		var netErr net.Error
+		switch {
+		case err == nil:
+			continue
+		case errors.As(err, &netErr) && netErr.Timeout() && readDeadline.Equal(deadline):
+			return nil, errNetcatTooSlow
+		case errors.Is(err, io.EOF), errors.As(err, &netErr) && netErr.Timeout():
+			return buf.Bytes(), nil
+		default:
+			return nil, err
+		}
+	}
+}
+
+// Перестаём принимать соединения и ждём начатые; по истечении ctx
+// закрываем оставшиеся принудительно
+func (s *NetcatServer) Shutdown(ctx context.Context) error {
+	err := s.listener.Close()
+	if waitErr := s.conns.wait(ctx); waitErr != nil {
+		return waitErr
+	}
+	return err
+}
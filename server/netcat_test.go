@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pastebin/models"
+)
+
+type savedPastes struct {
+	mu     sync.Mutex
+	pastes []models.Paste
+}
+
+func (s *savedPastes) save(ctx context.Context, p models.Paste) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pastes = append(s.pastes, p)
+	return nil
+}
+
+func startTestNetcat(t *testing.T, allow func(string) bool, idle time.Duration) (*NetcatServer, *savedPastes) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &savedPastes{}
+	s := newNetcatServer(listener, allow, store.save, "http://paste.test")
+	s.idleTimeout = idle
+	s.maxBytes = 64
+	go s.serve()
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	return s, store
+}
+
+// Пишем данные и читаем ответ сервера; closeWrite — как nc -N
+func netcatExchange(t *testing.T, addr net.Addr, data string, closeWrite bool) string {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte(data))
+	if closeWrite {
+		conn.(*net.TCPConn).CloseWrite()
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reply, _ := io.ReadAll(conn)
+	return string(reply)
+}
+
+func TestNetcatCreatesPaste(t *testing.T) {
+	s, store := startTestNetcat(t, nil, 200*time.Millisecond)
+
+	// До EOF
+	reply := netcatExchange(t, s.Addr(), "hello\n", true)
+	if !strings.HasPrefix(reply, "http://paste.test/paste/") {
+		t.Fatalf("Ожидалась ссылка, получено %q", reply)
+	}
+	// До паузы: клиент не закрывает соединение
+	reply = netcatExchange(t, s.Addr(), "idle", false)
+	if !strings.HasPrefix(reply, "http://paste.test/paste/") {
+		t.Fatalf("Ожидалась ссылка после паузы, получено %q", reply)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if len(store.pastes) != 2 || store.pastes[0].Content != "hello\n" || store.pastes[1].Content != "idle" {
+		t.Errorf("Сохранены неверные пасты: %+v", store.pastes)
+	}
+	if !strings.HasSuffix(reply, store.pastes[1].ID.Hex()+"\n") {
+		t.Errorf("Ссылка не совпадает с ID пасты: %q", reply)
+	}
+}
+
+func TestNetcatLimits(t *testing.T) {
+	s, store := startTestNetcat(t, func(ip string) bool { return ip != "127.0.0.1" }, 200*time.Millisecond)
+	if reply := netcatExchange(t, s.Addr(), "x", true); reply != "Too many requests\n" {
+		t.Errorf("Ожидался отказ по лимиту, получено %q", reply)
+	}
+
+	s, store = startTestNetcat(t, nil, 200*time.Millisecond)
+	if reply := netcatExchange(t, s.Addr(), strings.Repeat("x", 100), true); reply != "Paste is too large\n" {
+		t.Errorf("Ожидался отказ по размеру, получено %q", reply)
+	}
+	if reply := netcatExchange(t, s.Addr(), "", true); reply != "Nothing to paste\n" {
+		t.Errorf("Ожидался отказ для пустого ввода, получено %q", reply)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if len(store.pastes) != 0 {
+		t.Errorf("Не должно быть сохранённых паст: %d", len(store.pastes))
+	}
+}
+
+func TestNetcatRejectsSlowUpload(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &savedPastes{}
+	s := newNetcatServer(listener, nil, store.save, "http://paste.test")
+	s.idleTimeout = 200 * time.Millisecond
+	s.maxDuration = 300 * time.Millisecond
+	go s.serve()
+	defer s.Shutdown(context.Background())
+
+	// Клиент всё время что-то шлёт, но не укладывается в общий лимит
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for i := 0; i < 5; i++ {
+		conn.Write([]byte("x"))
+		time.Sleep(100 * time.Millisecond)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if reply, _ := io.ReadAll(conn); string(reply) != "Upload took too long\n" {
+		t.Errorf("Ожидался отказ по общему лимиту, получено %q", reply)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if len(store.pastes) != 0 {
+		t.Errorf("Не должно быть сохранённых паст: %d", len(store.pastes))
+	}
+}
+
+func TestConnGroupRejectsAfterShutdown(t *testing.T) {
+	var g connGroup
+	client, server := net.Pipe()
+	defer client.Close()
+	if err := g.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if g.add(server) {
+		t.Error("соединение принято после начала остановки")
+	}
+}
+
+func TestNetcatShutdown(t *testing.T) {
+	s, _ := startTestNetcat(t, nil, time.Minute)
+
+	// Зависшее соединение закрывается, когда истекает контекст остановки
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("partial"))
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Ожидалось принудительное закрытие, получено %v", err)
+	}
+	if _, err := net.Dial("tcp", s.Addr().String()); err == nil {
+		t.Error("Listener должен быть закрыт")
+	}
+}
@@ -0,0 +1,391 @@
+package server
+
+import (
+	"net/http"
+	"pastebin/models"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Описание одной операции JSON API для спецификации OpenAPI
+type apiOperation struct {
+	Method       string
+	Path         string
+	ID           string
+	Summary      string
+	Scope        string // Право API-токена; "" — достаточно любого токена
+	OptionalAuth bool
+	NotFound     bool // Может вернуть 404
+	Params       []apiParam
+	Request      interface{} // Пример типа тела запроса
+	Status       int
+	Response     interface{} // Пример типа тела ответа; nil — без тела
+}
+
+type apiParam struct {
+	Name        string
+	In          string // "path" / "query" / "header"
+	Type        string
+	Description string
+}
+
+var pasteIDParam = apiParam{Name: "id", In: "path", Type: "string", Description: "Paste ID"}
+
+// Все маршруты /api/v1; main_test.go сверяет их с setupRoutes
+var apiOperations = []apiOperation{
+	{Method: "GET", Path: "/api/v1/pastes", ID: "listPastes", Summary: "List your pastes, public pastes with public=true, or pastes shared with you with shared=true", Scope: models.ScopePastesRead,
+		Params: []apiParam{
+			{Name: "public", In: "query", Type: "boolean", Description: "List public pastes of all users instead of your own"},
+			{Name: "shared", In: "query", Type: "boolean", Description: "List other users' pastes shared with you"},
+			{Name: "limit", In: "query", Type: "integer", Description: "Page size, 1-100 (default 20)"},
+			{Name: "cursor", In: "query", Type: "string", Description: "next_cursor from the previous page"},
+		},
+		Status: http.StatusOK, Response: APIPasteList{}},
+	{Method: "POST", Path: "/api/v1/pastes", ID: "createPaste", Summary: "Create a paste", Scope: models.ScopePastesWrite,
+		Params:  []apiParam{{Name: idempotencyHeader, In: "header", Type: "string", Description: "Retrying with the same key returns the original response instead of creating another paste; keys live 24 hours"}},
+		Request: pasteInput{}, Status: http.StatusCreated, Response: APIPaste{}},
+	{Method: "POST", Path: "/api/v1/pastes/batch", ID: "batchCreatePastes", Summary: "Create up to 100 pastes; each item gets its own status in the report", Scope: models.ScopePastesWrite,
+		Params:  []apiParam{{Name: idempotencyHeader, In: "header", Type: "string", Description: "Retrying with the same key returns the original report instead of creating the pastes again"}},
+		Request: apiBatchCreate{}, Status: http.StatusOK, Response: APIBulkReport{}},
+	{Method: "POST", Path: "/api/v1/pastes/bulk", ID: "bulkUpdatePastes", Summary: "Delete, change expiry or change visibility of up to 100 of your pastes", Scope: models.ScopePastesWrite,
+		Request: apiBulkAction{}, Status: http.StatusOK, Response: APIBulkReport{}},
+	{Method: "GET", Path: "/api/v1/pastes/{id}", ID: "getPaste", NotFound: true, Summary: "Read a paste; counts as a view", Scope: models.ScopePastesRead, OptionalAuth: true,
+		Params: []apiParam{pasteIDParam, {Name: pastePasswordHeader, In: "header", Type: "string", Description: "Password of a protected paste"}},
+		Status: http.StatusOK, Response: APIPaste{}},
+	{Method: "PATCH", Path: "/api/v1/pastes/{id}", ID: "updatePaste", NotFound: true, Summary: "Update a paste you own or may edit; omitted fields are left unchanged, and only the owner may change visibility and expiry", Scope: models.ScopePastesWrite,
+		Params: []apiParam{pasteIDParam}, Request: apiPasteUpdate{}, Status: http.StatusOK, Response: APIPaste{}},
+	{Method: "DELETE", Path: "/api/v1/pastes/{id}", ID: "deletePaste", NotFound: true, Summary: "Delete your paste", Scope: models.ScopePastesWrite,
+		Params: []apiParam{pasteIDParam}, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/api/v1/pastes/{id}/edit", ID: "getEditablePaste", NotFound: true, Summary: "Read a paste you own or may edit without counting a view", Scope: models.ScopePastesRead,
+		Params: []apiParam{pasteIDParam}, Status: http.StatusOK, Response: APIPaste{}},
+	{Method: "GET", Path: "/api/v1/pastes/{id}/shares", ID: "listPasteShares", NotFound: true, Summary: "Users your paste is shared with", Scope: models.ScopePastesRead,
+		Params: []apiParam{pasteIDParam}, Status: http.StatusOK, Response: APIPasteShareList{}},
+	{Method: "POST", Path: "/api/v1/pastes/{id}/shares", ID: "sharePaste", NotFound: true, Summary: "Share your paste with a registered user by email, or change their role; they get an invitation email. The response is the same whether or not the email is registered", Scope: models.ScopePastesWrite,
+		Params: []apiParam{pasteIDParam}, Request: apiShareInput{}, Status: http.StatusAccepted, Response: APIShareInvite{}},
+	{Method: "DELETE", Path: "/api/v1/pastes/{id}/shares/{user_id}", ID: "unsharePaste", NotFound: true, Summary: "Remove a user's access to your paste, or give up your own access", Scope: models.ScopePastesWrite,
+		Params: []apiParam{pasteIDParam, {Name: "user_id", In: "path", Type: "string", Description: "ID of the user whose access is removed"}}, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/api/v1/chat", ID: "getChat", NotFound: true, Summary: "Your active support chat", Scope: models.ScopeChat,
+		Status: http.StatusOK, Response: APIChat{}},
+	{Method: "POST", Path: "/api/v1/chat/messages", ID: "postChatMessage", NotFound: true, Summary: "Send a message to your active support chat", Scope: models.ScopeChat,
+		Request: apiChatMessageInput{}, Status: http.StatusCreated, Response: APIChatMessage{}},
+	{Method: "GET", Path: "/api/v1/me", ID: "getMe", NotFound: true, Summary: "The user the token belongs to",
+		Status: http.StatusOK, Response: APIUser{}},
+}
+
+// Имена схем в components; вложенные структуры ссылаются на них через $ref
+var apiSchemaNames = map[reflect.Type]string{
+	reflect.TypeOf(APIPaste{}):            "Paste",
+	reflect.TypeOf(APIPasteList{}):        "PasteList",
+	reflect.TypeOf(pasteInput{}):          "PasteInput",
+	reflect.TypeOf(apiPasteUpdate{}):      "PasteUpdate",
+	reflect.TypeOf(apiBatchCreate{}):      "BatchCreate",
+	reflect.TypeOf(apiBulkAction{}):       "BulkAction",
+	reflect.TypeOf(APIBulkReport{}):       "BulkReport",
+	reflect.TypeOf(APIPasteShare{}):       "PasteShare",
+	reflect.TypeOf(APIPasteShareList{}):   "PasteShareList",
+	reflect.TypeOf(apiShareInput{}):       "ShareInput",
+	reflect.TypeOf(APIBulkItem{}):         "BulkItem",
+	reflect.TypeOf(APIUser{}):             "User",
+	reflect.TypeOf(APIChat{}):             "Chat",
+	reflect.TypeOf(APIChatMessage{}):      "ChatMessage",
+	reflect.TypeOf(apiChatMessageInput{}): "ChatMessageInput",
+	reflect.TypeOf(APIError{}):            "Error",
+	reflect.TypeOf(APIErrorBody{}):        "ErrorBody",
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Схема JSON для типа Go по тем же тегам json, что использует encoding/json
+func schemaFor(t reflect.Type, inline bool) map[string]interface{} {
+	if name, ok := apiSchemaNames[t]; ok && !inline {
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := schemaFor(t.Elem(), false)
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Int32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), false)}
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		}
+		properties := map[string]interface{}{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = schemaFor(field.Type, false)
+			if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Ptr {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]interface{}{}
+}
+
+// Допустимые значения поля схемы
+func setEnum(schemas map[string]interface{}, schema, field string, values []string) {
+	properties := schemas[schema].(map[string]interface{})["properties"].(map[string]interface{})
+	properties[field].(map[string]interface{})["enum"] = values
+}
+
+func buildOpenAPISpec() map[string]interface{} {
+	schemas := map[string]interface{}{}
+	for t, name := range apiSchemaNames {
+		schemas[name] = schemaFor(t, true)
+	}
+	visibilities := []string{models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate}
+	expiries := []string{"never"}
+	for option := range expiryOptions {
+		expiries = append(expiries, option)
+	}
+	sort.Strings(expiries)
+	for _, name := range []string{"Paste", "PasteInput", "PasteUpdate"} {
+		setEnum(schemas, name, "visibility", visibilities)
+	}
+	for _, name := range []string{"PasteInput", "PasteUpdate"} {
+		setEnum(schemas, name, "language", models.Languages)
+		setEnum(schemas, name, "expires", expiries)
+	}
+	setEnum(schemas, "BulkAction", "action", []string{bulkActionDelete, bulkActionExpire, bulkActionVisibility})
+	setEnum(schemas, "BulkAction", "visibility", visibilities)
+	setEnum(schemas, "BulkAction", "expires", expiries)
+	for _, name := range []string{"PasteShare", "ShareInput"} {
+		setEnum(schemas, name, "role", []string{models.ShareView, models.ShareEdit})
+	}
+
+	errorResponse := func(description string) map[string]interface{} {
+		return map[string]interface{}{
+			"description": description,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schemaFor(reflect.TypeOf(APIError{}), false)},
+			},
+		}
+	}
+	responses := map[string]interface{}{
+		"BadRequest":   errorResponse("Invalid request"),
+		"Unauthorized": errorResponse("Missing, invalid, expired or revoked token"),
+		"Forbidden":    errorResponse("Token lacks the required scope, the resource belongs to someone else, or the paste password is missing or wrong"),
+		"NotFound":     errorResponse("Resource not found"),
+		"Internal":     errorResponse("Server error"),
+	}
+	ref := func(name string) map[string]interface{} {
+		return map[string]interface{}{"$ref": "#/components/responses/" + name}
+	}
+
+	paths := map[string]interface{}{}
+	for _, op := range apiOperations {
+		item, ok := paths[op.Path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[op.Path] = item
+		}
+
+		opResponses := map[string]interface{}{
+			"400": ref("BadRequest"),
+			"500": ref("Internal"),
+		}
+		if !op.OptionalAuth {
+			opResponses["401"] = ref("Unauthorized")
+		}
+		if op.Scope != "" {
+			opResponses["403"] = ref("Forbidden")
+		}
+		if op.NotFound {
+			opResponses["404"] = ref("NotFound")
+		}
+		success := map[string]interface{}{"description": http.StatusText(op.Status)}
+		if op.Response != nil {
+			success["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schemaFor(reflect.TypeOf(op.Response), false)},
+			}
+		}
+		opResponses[strconv.Itoa(op.Status)] = success
+
+		security := []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+		if op.OptionalAuth {
+			security = append(security, map[string]interface{}{})
+		}
+		description := "Any valid token."
+		if op.Scope != "" {
+			description = "API tokens need the `" + op.Scope + "` scope."
+		}
+		if op.OptionalAuth {
+			description = "Authentication is optional; it is needed for private pastes. " + description
+		}
+
+		operation := map[string]interface{}{
+			"operationId": op.ID,
+			"summary":     op.Summary,
+			"description": description,
+			"security":    security,
+			"responses":   opResponses,
+		}
+		if op.Scope != "" {
+			operation["x-required-scope"] = op.Scope
+		}
+		if len(op.Params) > 0 {
+			var params []interface{}
+			for _, p := range op.Params {
+				params = append(params, map[string]interface{}{
+					"name":        p.Name,
+					"in":          p.In,
+					"required":    p.In == "path",
+					"description": p.Description,
+					"schema":      map[string]interface{}{"type": p.Type},
+				})
+			}
+			operation["parameters"] = params
+		}
+		if op.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemaFor(reflect.TypeOf(op.Request), false)},
+				},
+			}
+		}
+		item[strings.ToLower(op.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Pastebin API",
+			"version":     "1.0.0",
+			"description": "Errors are always returned as `{\"error\": {\"code\": \"...\", \"message\": \"...\"}}`.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas":   schemas,
+			"responses": responses,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "Login JWT, or a personal API token (`pb_...`) created on the profile page. API tokens are limited to their scopes: " + strings.Join(models.Scopes, ", ") + ".",
+				},
+			},
+		},
+	}
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPISpec map[string]interface{}
+)
+
+// Спецификация не меняется во время работы — собираем один раз
+func OpenAPISpec() map[string]interface{} {
+	openAPIOnce.Do(func() {
+		openAPISpec = buildOpenAPISpec()
+	})
+	return openAPISpec
+}
+
+// GET /api/openapi.json
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	WriteJSON(w, http.StatusOK, OpenAPISpec())
+}
+
+// GET /api/docs — страница документации по той же спецификации
+func APIDocsHandler(w http.ResponseWriter, r *http.Request) {
+	schemas := OpenAPISpec()["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	type docSchema struct {
+		Name   string
+		Fields []docField
+	}
+	var docSchemas []docSchema
+	for _, name := range names {
+		schema := schemas[name].(map[string]interface{})
+		properties, _ := schema["properties"].(map[string]interface{})
+		required := map[string]bool{}
+		if list, ok := schema["required"].([]string); ok {
+			for _, field := range list {
+				required[field] = true
+			}
+		}
+		fields := make([]docField, 0, len(properties))
+		for field, value := range properties {
+			fields = append(fields, docField{Name: field, Type: schemaTypeName(value.(map[string]interface{})), Required: required[field]})
+		}
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+		docSchemas = append(docSchemas, docSchema{Name: name, Fields: fields})
+	}
+
+	render(w, r, "apidocs.html", struct {
+		Operations []apiOperation
+		Schemas    []docSchema
+		Scopes     []string
+	}{
+		Operations: apiOperations,
+		Schemas:    docSchemas,
+		Scopes:     models.Scopes,
+	})
+}
+
+type docField struct {
+	Name     string
+	Type     string
+	Required bool
+}
+
+// Короткое имя типа поля для страницы документации
+func schemaTypeName(schema map[string]interface{}) string {
+	if ref, ok := schema["$ref"].(string); ok {
+		return strings.TrimPrefix(ref, "#/components/schemas/")
+	}
+	if all, ok := schema["allOf"].([]interface{}); ok && len(all) > 0 {
+		return schemaTypeName(all[0].(map[string]interface{})) + "?"
+	}
+	name, _ := schema["type"].(string)
+	if name == "array" {
+		name = schemaTypeName(schema["items"].(map[string]interface{})) + "[]"
+	}
+	if format, ok := schema["format"].(string); ok {
+		name += " (" + format + ")"
+	}
+	if schema["nullable"] == true {
+		name += "?"
+	}
+	if enum, ok := schema["enum"].([]string); ok {
+		name += ": " + strings.Join(enum, " | ")
+	}
+	return name
+}
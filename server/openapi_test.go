@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	OpenAPIHandler(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Неверный статус: %d", rec.Code)
+	}
+
+	var spec struct {
+		OpenAPI    string                            `json:"openapi"`
+		Paths      map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&spec); err != nil {
+		t.Fatalf("Спецификация не JSON: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("Неверная версия OpenAPI: %q", spec.OpenAPI)
+	}
+	for _, op := range apiOperations {
+		if _, ok := spec.Paths[op.Path][strings.ToLower(op.Method)]; !ok {
+			t.Errorf("Нет операции %s %s", op.Method, op.Path)
+		}
+	}
+
+	// Схема пасты содержит все поля ответа API
+	paste := spec.Components.Schemas["Paste"]
+	apiPaste := reflect.TypeOf(APIPaste{})
+	for i := 0; i < apiPaste.NumField(); i++ {
+		name, _, _ := strings.Cut(apiPaste.Field(i).Tag.Get("json"), ",")
+		if _, ok := paste.Properties[name]; !ok {
+			t.Errorf("В схеме Paste нет поля %q", name)
+		}
+	}
+}
+
+func TestAPIDocsPage(t *testing.T) {
+	if err := loadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	APIDocsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/docs", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Неверный статус: %d %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "/api/v1/pastes/{id}") {
+		t.Error("На странице нет маршрутов API")
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Курсор keyset-пагинации по (createdAt, _id)
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        primitive.ObjectID
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// Непрозрачная строка курсора для клиента
+func encodeCursor(createdAt time.Time, id primitive.ObjectID) string {
+	raw := fmt.Sprintf("%d_%s", createdAt.UnixMilli(), id.Hex())
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(value string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+	millis, hexID, ok := strings.Cut(string(raw), "_")
+	if !ok {
+		return pageCursor{}, errInvalidCursor
+	}
+	ms, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+	id, err := primitive.ObjectIDFromHex(hexID)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+	return pageCursor{CreatedAt: time.UnixMilli(ms).UTC(), ID: id}, nil
+}
+
+// Условие "после курсора" для сортировки по (createdAt, _id);
+// order = -1 — от новых к старым, 1 — от старых к новым
+func (c pageCursor) after(order int) bson.M {
+	op := "$lt"
+	if order > 0 {
+		op = "$gt"
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{"createdAt": bson.M{op: c.CreatedAt}},
+		bson.M{"createdAt": c.CreatedAt, "_id": bson.M{op: c.ID}},
+	}}
+}
+
+// Размер страницы из параметра запроса с ограничениями
+func parsePageSize(value string, def, max int) int {
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 1 {
+		return def
+	}
+	if size > max {
+		return max
+	}
+	return size
+}
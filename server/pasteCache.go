@@ -0,0 +1,196 @@
+package server
+
+import (
+	"container/list"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"pastebin/models"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Общий объём кэша паст: содержимое + отрендеренный HTML
+const pasteCacheMaxBytes = 64 << 20
+
+// Кэшированная паста вместе с HTML её содержимого.
+// HTML зависит только от ревизии, поэтому ключ — ID + ревизия
+type cachedPaste struct {
+	Paste models.Paste
+	Body  template.HTML
+}
+
+type pasteCacheKey struct {
+	ID       primitive.ObjectID
+	Revision int
+}
+
+type pasteCacheItem struct {
+	key   pasteCacheKey
+	value cachedPaste
+	size  int
+}
+
+// Статистика кэша для мониторинга
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Bytes     int    `json:"bytes"`
+	MaxBytes  int    `json:"max_bytes"`
+}
+
+// LRU-кэш паст, ограниченный по суммарному размеру
+type pasteCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	bytes    int
+	ll       *list.List
+	items    map[primitive.ObjectID]*list.Element // Одна актуальная ревизия на пасту
+	loads    map[primitive.ObjectID]*pasteLoads   // Чтения из базы, которые ещё не положили результат
+	stats    CacheStats
+}
+
+// Незавершённые загрузки пасты из базы. Invalidate увеличивает gen, и
+// загрузка, начатая раньше, не кладёт в кэш то, что успела прочитать:
+// ревизия меняется не при каждой записи (видимость, доступ, пароль)
+type pasteLoads struct {
+	n   int
+	gen uint64
+}
+
+// Загрузка одной пасты из базы в кэш
+type pasteLoad struct {
+	c   *pasteCache
+	id  primitive.ObjectID
+	gen uint64
+}
+
+func newPasteCache(maxBytes int) *pasteCache {
+	return &pasteCache{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[primitive.ObjectID]*list.Element),
+		loads:    make(map[primitive.ObjectID]*pasteLoads),
+	}
+}
+
+var hotPastes = newPasteCache(pasteCacheMaxBytes)
+
+// Получить пасту из кэша по ID
+func (c *pasteCache) Get(id primitive.ObjectID) (cachedPaste, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[id]
+	if !ok {
+		c.stats.Misses++
+		return cachedPaste{}, false
+	}
+	c.stats.Hits++
+	c.ll.MoveToFront(el)
+	return el.Value.(*pasteCacheItem).value, true
+}
+
+// Начинаем читать пасту из базы; результат кладём через Add загрузки,
+// а по окончании обязательно вызываем Done
+func (c *pasteCache) StartLoad(id primitive.ObjectID) *pasteLoad {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.loads[id]
+	if !ok {
+		l = &pasteLoads{}
+		c.loads[id] = l
+	}
+	l.n++
+	return &pasteLoad{c: c, id: id, gen: l.gen}
+}
+
+// Кладём прочитанное, если паста не менялась с начала загрузки
+func (l *pasteLoad) Add(value cachedPaste) {
+	l.c.mu.Lock()
+	defer l.c.mu.Unlock()
+	if l.c.loads[l.id].gen == l.gen {
+		l.c.add(value)
+	}
+}
+
+func (l *pasteLoad) Done() {
+	l.c.mu.Lock()
+	defer l.c.mu.Unlock()
+	loads := l.c.loads[l.id]
+	loads.n--
+	if loads.n == 0 {
+		delete(l.c.loads, l.id)
+	}
+}
+
+// Положить пасту в кэш; более старая ревизия той же пасты вытесняется
+func (c *pasteCache) Add(value cachedPaste) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(value)
+}
+
+func (c *pasteCache) add(value cachedPaste) {
+	key := pasteCacheKey{ID: value.Paste.ID, Revision: value.Paste.Revision}
+	size := len(value.Paste.Content) + len(value.Paste.Title) + len(value.Body)
+	if size > c.maxBytes {
+		return
+	}
+
+	if el, ok := c.items[key.ID]; ok {
+		item := el.Value.(*pasteCacheItem)
+		if item.key.Revision > key.Revision {
+			// Не затираем более свежую ревизию
+			return
+		}
+		c.removeElement(el)
+	}
+
+	el := c.ll.PushFront(&pasteCacheItem{key: key, value: value, size: size})
+	c.items[key.ID] = el
+	c.bytes += size
+
+	for c.bytes > c.maxBytes {
+		c.removeElement(c.ll.Back())
+		c.stats.Evictions++
+	}
+}
+
+// Удалить пасту из кэша (редактирование, удаление, истечение срока, сжигание)
+func (c *pasteCache) Invalidate(id primitive.ObjectID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[id]; ok {
+		c.removeElement(el)
+	}
+	if l, ok := c.loads[id]; ok {
+		l.gen++
+	}
+}
+
+func (c *pasteCache) removeElement(el *list.Element) {
+	item := el.Value.(*pasteCacheItem)
+	c.ll.Remove(el)
+	delete(c.items, item.key.ID)
+	c.bytes -= item.size
+}
+
+func (c *pasteCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.ll.Len()
+	stats.Bytes = c.bytes
+	stats.MaxBytes = c.maxBytes
+	return stats
+}
+
+// Статистика кэша паст в JSON
+func CacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hotPastes.Stats())
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"pastebin/models"
+)
+
+func cachedWithContent(id primitive.ObjectID, revision int, content string) cachedPaste {
+	return cachedPaste{Paste: models.Paste{ID: id, Revision: revision, Content: content}}
+}
+
+func TestPasteCacheHitMiss(t *testing.T) {
+	c := newPasteCache(1024)
+	id := primitive.NewObjectID()
+
+	if _, ok := c.Get(id); ok {
+		t.Fatal("Пустой кэш вернул значение")
+	}
+	c.Add(cachedWithContent(id, 1, "hello"))
+	got, ok := c.Get(id)
+	if !ok || got.Paste.Content != "hello" {
+		t.Fatalf("Ожидалось попадание, получено %+v, %v", got, ok)
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("Неверная статистика: %+v", stats)
+	}
+
+	c.Invalidate(id)
+	if _, ok := c.Get(id); ok {
+		t.Error("Паста осталась в кэше после инвалидации")
+	}
+}
+
+func TestPasteCacheRevisions(t *testing.T) {
+	c := newPasteCache(1024)
+	id := primitive.NewObjectID()
+
+	c.Add(cachedWithContent(id, 2, "new"))
+	c.Add(cachedWithContent(id, 1, "old"))
+	if got, _ := c.Get(id); got.Paste.Revision != 2 {
+		t.Errorf("Старая ревизия затёрла новую: %d", got.Paste.Revision)
+	}
+
+	c.Add(cachedWithContent(id, 3, "newer"))
+	if got, _ := c.Get(id); got.Paste.Revision != 3 {
+		t.Errorf("Ожидалась ревизия 3, получена %d", got.Paste.Revision)
+	}
+	if c.Stats().Entries != 1 {
+		t.Errorf("Ожидалась одна запись, получено %d", c.Stats().Entries)
+	}
+}
+
+func TestPasteCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newPasteCache(30)
+	a, b, d := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+
+	c.Add(cachedWithContent(a, 1, strings.Repeat("a", 10)))
+	c.Add(cachedWithContent(b, 1, strings.Repeat("b", 10)))
+	c.Get(a) // a становится самой свежей
+	c.Add(cachedWithContent(d, 1, strings.Repeat("d", 15)))
+
+	if _, ok := c.Get(b); ok {
+		t.Error("Давно не использованная паста не была вытеснена")
+	}
+	if _, ok := c.Get(a); !ok {
+		t.Error("Недавно использованная паста была вытеснена")
+	}
+	if stats := c.Stats(); stats.Bytes > 30 || stats.Evictions != 1 {
+		t.Errorf("Неверная статистика: %+v", stats)
+	}
+}
+
+func TestPasteCacheDropsLoadsStartedBeforeInvalidate(t *testing.T) {
+	c := newPasteCache(1024)
+	id := primitive.NewObjectID()
+
+	// Чтение из базы началось, затем паста стала приватной без смены ревизии
+	stale := c.StartLoad(id)
+	c.Invalidate(id)
+	fresh := c.StartLoad(id)
+
+	stale.Add(cachedWithContent(id, 1, "public"))
+	stale.Done()
+	if _, ok := c.Get(id); ok {
+		t.Fatal("Прочитанное до инвалидации попало в кэш")
+	}
+
+	fresh.Add(cachedWithContent(id, 1, "private"))
+	fresh.Done()
+	if got, ok := c.Get(id); !ok || got.Paste.Content != "private" {
+		t.Errorf("Загрузка после инвалидации не попала в кэш: %+v, %v", got, ok)
+	}
+	if len(c.loads) != 0 {
+		t.Errorf("Остались незавершённые загрузки: %d", len(c.loads))
+	}
+}
@@ -1,13 +1,19 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"github.com/dgrijalva/jwt-go"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
 	"log"
+	"math"
+	"mime"
 	"os"
 	"pastebin/utils"
 	"strconv"
+	"strings"
 
 	"fmt"
 	"html/template"
@@ -18,6 +24,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
 	"pastebin/models"
 )
 
@@ -47,10 +54,109 @@ func HandleError(w http.ResponseWriter, err error, statusCode int, message strin
 	http.Error(w, message, statusCode)
 }
 
+// Публичная ссылка на пасту: BASE_URL из .env или хост текущего запроса
+func pasteURL(r *http.Request, id primitive.ObjectID) string {
+	return pasteLink(requestBaseURL(r), id)
+}
+
+// Адрес сайта: BASE_URL или схема и хост запроса
+func requestBaseURL(r *http.Request) string {
+	if base := os.Getenv("BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func pasteLink(base string, id primitive.ObjectID) string {
+	return fmt.Sprintf("%s/paste/%s", strings.TrimRight(base, "/"), id.Hex())
+}
+
 // Главная страница
 func MainPageHandler(w http.ResponseWriter, r *http.Request) {
-	tmpl := template.Must(template.ParseFiles(templatesDir + "/home.html"))
-	tmpl.Execute(w, nil)
+	render(w, r, "home.html", struct {
+		Languages []string
+	}{
+		Languages: models.Languages,
+	})
+}
+
+// Параметры новой пасты: из формы или из JSON API
+type pasteInput struct {
+	Title       string `json:"title,omitempty"`
+	Content     string `json:"content"`
+	Language    string `json:"language,omitempty"`
+	Visibility  string `json:"visibility,omitempty"`
+	Expires     string `json:"expires,omitempty"`
+	DeleteAfter int    `json:"delete_after,omitempty"`
+	Password    string `json:"password,omitempty"`
+}
+
+// Проверяем параметры и собираем пасту; ошибки можно показать пользователю
+func newPaste(userID primitive.ObjectID, in pasteInput, now time.Time) (models.Paste, error) {
+	// Проверка обязательных полей
+	if in.Content == "" {
+		return models.Paste{}, errors.New("Content is required")
+	}
+
+	visibility := in.Visibility
+	switch visibility {
+	case "":
+		visibility = models.VisibilityPublic
+	case models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate:
+	default:
+		return models.Paste{}, errors.New("Invalid visibility")
+	}
+
+	// Срок жизни пасты
+	expiresAt, err := expiryTime(in.Expires, now)
+	if err != nil {
+		return models.Paste{}, errors.New("Invalid expiry")
+	}
+
+	if in.DeleteAfter < 0 || in.DeleteAfter > math.MaxInt32 {
+		return models.Paste{}, errors.New("Invalid read limit")
+	}
+
+	// Пароль храним только в виде bcrypt-хэша
+	passwordHash := ""
+	if in.Password != "" {
+		passwordHash, err = utils.HashPassword(in.Password)
+		if err != nil {
+			return models.Paste{}, err
+		}
+	}
+
+	return models.Paste{
+		ID:          primitive.NewObjectID(),
+		Title:       in.Title,
+		Content:     in.Content,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Expires:     in.Expires,
+		ExpiresAt:   expiresAt,
+		Revision:    1,
+		UserID:      userID,
+		Language:    in.Language,
+		Visibility:  visibility,
+		DeleteAfter: int32(in.DeleteAfter),
+		Password:    passwordHash,
+	}, nil
+}
+
+// Сохраняем новую пасту и пишем в лог
+func insertPaste(ctx context.Context, paste models.Paste) error {
+	_, err := GetCollection("pastes").InsertOne(ctx, paste)
+	if err != nil {
+		log.Printf("Ошибка сохранения пасты: %v", err)
+		return err
+	}
+
+	events.Publish(PasteCreated{Paste: paste})
+	return nil
 }
 
 // Страница создания пасты
@@ -65,6 +171,13 @@ func CreatePasteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Повтор с тем же Idempotency-Key получает исходный ответ
+	w, finish, ok := beginIdempotent(w, r, userID, webIdempotencyError)
+	if !ok {
+		return
+	}
+	defer finish()
+
 	// Установка таймаута для контекста
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -76,44 +189,215 @@ func CreatePasteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	title := r.FormValue("title")
-	content := r.FormValue("content")
+	// Удаление после N прочтений
+	deleteAfter := 0
+	if value := r.FormValue("deleteAfter"); value != "" {
+		deleteAfter, err = strconv.Atoi(value)
+		if err != nil {
+			HandleError(w, err, http.StatusBadRequest, "Invalid read limit")
+			return
+		}
+	}
 
-	// Проверка обязательных полей
-	if content == "" {
-		HandleError(w, nil, http.StatusBadRequest, "Content is required")
+	paste, err := newPaste(userID, pasteInput{
+		Title:       r.FormValue("title"),
+		Content:     r.FormValue("content"),
+		Language:    r.FormValue("language"),
+		Visibility:  r.FormValue("visibility"),
+		Expires:     r.FormValue("expires"),
+		DeleteAfter: deleteAfter,
+		Password:    r.FormValue("password"),
+	}, time.Now())
+	if err != nil {
+		HandleError(w, err, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Получаем коллекцию из MongoDB
-	collection := GetCollection("pastes")
+	// Сохранение пасты в базе данных
+	if err := insertPaste(ctx, paste); err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to save paste")
+		return
+	}
+
+	// Перенаправление на страницу пасты
+	setFlash(w, "Paste created")
+	http.Redirect(w, r, fmt.Sprintf("/paste/%s", paste.ID.Hex()), http.StatusSeeOther)
+}
 
-	// Создание объекта пасты
-	paste := models.Paste{
-		ID:        primitive.NewObjectID(),
-		Title:     title,
-		Content:   content,
-		CreatedAt: time.Now(),
-		UserID:    userID,
+// Ограничение размера пасты, загружаемой через curl
+const rawUploadMaxBytes = 10 << 20
+
+// Параметр загрузки из query (?expires=1day) или заголовка (X-Expires: 1day)
+func rawUploadOption(r *http.Request, name, header string) string {
+	if value := r.URL.Query().Get(name); value != "" {
+		return value
 	}
+	return r.Header.Get(header)
+}
 
-	// Сохранение пасты в базе данных
-	_, err = collection.InsertOne(ctx, paste)
+// Текст пасты из тела запроса: multipart (curl -F 'f=<-') — первое поле
+// или файл, всё остальное (curl --data-binary) — тело целиком
+func rawUploadContent(r *http.Request) (string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		body, err := io.ReadAll(r.Body)
+		return string(body), err
+	}
+
+	if err := r.ParseMultipartForm(rawUploadMaxBytes); err != nil {
+		return "", err
+	}
+	for _, values := range r.MultipartForm.Value {
+		if len(values) > 0 {
+			return values[0], nil
+		}
+	}
+	for _, files := range r.MultipartForm.File {
+		if len(files) == 0 {
+			continue
+		}
+		file, err := files[0].Open()
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		body, err := io.ReadAll(file)
+		return string(body), err
+	}
+	return "", nil
+}
+
+// POST / — загрузка в стиле termbin/sprunge:
+//
+//	curl --data-binary @build.log 'https://host/?expires=1day&lang=bash'
+//	cmd | curl -F 'f=<-' -H 'X-Burn: 1' https://host/
+//
+// В ответ — только ссылка на пасту текстом
+func RawUploadHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, rawUploadMaxBytes)
+	content, err := rawUploadContent(r)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		HandleError(w, err, http.StatusRequestEntityTooLarge, "Paste is too large")
+		return
+	} else if err != nil {
+		HandleError(w, err, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+
+	deleteAfter := 0
+	if value := rawUploadOption(r, "burn", "X-Burn"); value != "" {
+		deleteAfter, err = strconv.Atoi(value)
+		if err != nil {
+			HandleError(w, err, http.StatusBadRequest, "Invalid read limit")
+			return
+		}
+	}
+
+	// Без токена паста анонимная
+	paste, err := newPaste(utils.UserIDFromContext(r.Context()), pasteInput{
+		Title:       rawUploadOption(r, "title", "X-Title"),
+		Content:     content,
+		Language:    rawUploadOption(r, "lang", "X-Language"),
+		Visibility:  rawUploadOption(r, "visibility", "X-Visibility"),
+		Expires:     rawUploadOption(r, "expires", "X-Expires"),
+		DeleteAfter: deleteAfter,
+		Password:    r.Header.Get(pastePasswordHeader),
+	}, time.Now())
 	if err != nil {
-		log.Printf("Ошибка сохранения пасты: %v", err)
+		HandleError(w, err, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	if err := insertPaste(ctx, paste); err != nil {
 		HandleError(w, err, http.StatusInternalServerError, "Failed to save paste")
 		return
 	}
 
-	// Логируем создание пасты
-	if pasteLogger != nil {
-		pasteLogger.Printf("Created paste: ID=%s, Title=%s, Date=%s\n", paste.ID.Hex(), paste.Title, paste.CreatedAt.Format(time.RFC3339))
-	} else {
-		log.Printf("Логгер не инициализирован: создана паста с ID=%s", paste.ID.Hex())
+	link := pasteURL(r, paste.ID)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Location", link)
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintln(w, link)
+}
+
+var (
+	errPasteNotFound = errors.New("paste not found")
+	errPasteLocked   = errors.New("paste password required")
+)
+
+// Подходит ли пароль к пасте; владельцу и тем, кому паста открыта,
+// пароль не нужен
+func pasteUnlocked(paste models.Paste, viewerID primitive.ObjectID, password string) bool {
+	if !paste.HasPassword() || paste.IsOwner(viewerID) || paste.ShareRole(viewerID) != "" {
+		return true
 	}
+	return bcrypt.CompareHashAndPassword([]byte(paste.Password), []byte(password)) == nil
+}
 
-	// Перенаправление на страницу пасты
-	http.Redirect(w, r, fmt.Sprintf("/paste/%s", paste.ID.Hex()), http.StatusSeeOther)
+// Может ли пользователь читать пасту: срок жизни, видимость и пароль.
+// Истёкшая паста удаляется при первом обращении
+func checkPasteAccess(ctx context.Context, paste models.Paste, viewerID primitive.ObjectID, password string) error {
+	if paste.IsExpired(time.Now()) {
+		if err := expirePaste(ctx, paste.ID); err != nil {
+			log.Printf("Ошибка удаления истёкшей пасты: %v", err)
+		}
+		return errPasteNotFound
+	}
+	// Приватную пасту видят только владелец и те, кому она открыта
+	if !paste.CanView(viewerID) {
+		return errPasteNotFound
+	}
+	if !pasteUnlocked(paste, viewerID, password) {
+		return errPasteLocked
+	}
+	return nil
+}
+
+// Загружаем пасту для чтения: через кэш, с проверкой срока жизни,
+// видимости и пароля, и засчитываем прочтение. Недоступная паста —
+// errPasteNotFound, неверный пароль — errPasteLocked
+func readPaste(ctx context.Context, id, viewerID primitive.ObjectID, password string) (cachedPaste, error) {
+	// Сначала смотрим в кэш горячих паст
+	cached, ok := hotPastes.Get(id)
+	if !ok {
+		// Если пасту поменяют, пока мы её читаем, прочитанное в кэш не попадёт
+		load := hotPastes.StartLoad(id)
+		defer load.Done()
+		var paste models.Paste
+		err := GetCollection("pastes").FindOne(ctx, bson.M{"_id": id}).Decode(&paste)
+		if err == mongo.ErrNoDocuments {
+			return cached, errPasteNotFound
+		} else if err != nil {
+			return cached, err
+		}
+		cached = cachedPaste{Paste: paste, Body: renderPasteBody(paste.Content)}
+		load.Add(cached)
+	}
+
+	// Прочтение засчитываем только после проверки доступа и пароля
+	if err := checkPasteAccess(ctx, cached.Paste, viewerID, password); err != nil {
+		return cached, err
+	}
+
+	// Счетчик кол-во просмотров
+	if cached.Paste.DeleteAfter > 0 {
+		// Для паст с лимитом прочтений нужен точный счёт, поэтому атомарно
+		paste, err := consumeLimitedRead(ctx, cached.Paste)
+		if err == mongo.ErrNoDocuments {
+			hotPastes.Invalidate(id)
+			return cached, errPasteNotFound
+		} else if err != nil {
+			return cached, err
+		}
+		cached.Paste = paste
+	} else {
+		// Остальные просмотры копятся в памяти и пишутся пачками
+		pendingViews.addRead(id)
+	}
+	return cached, nil
 }
 
 // Просмотр пасты по ID
@@ -128,111 +412,155 @@ func ViewPasteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	collection := GetCollection("pastes")
-	var paste models.Paste
-	err = collection.FindOne(r.Context(), bson.M{"_id": objID}).Decode(&paste)
-	if err == mongo.ErrNoDocuments {
-		HandleError(w, err, http.StatusInternalServerError, "Paste not found")
+	viewerID, _ := utils.GetUserIDFromToken(r)
+	cached, err := readPaste(r.Context(), objID, viewerID, r.PostFormValue("password"))
+	if err == errPasteNotFound {
+		HandleError(w, err, http.StatusNotFound, "Paste not found")
+		return
+	} else if err == errPasteLocked {
+		// Пароль вводится формой, чтобы он не попадал в URL и логи
+		render(w, r, "pastepassword.html", struct {
+			ID    string
+			Retry bool
+		}{
+			ID:    id,
+			Retry: r.Method == http.MethodPost,
+		})
 		return
 	} else if err != nil {
 		HandleError(w, err, http.StatusInternalServerError, "BD connection error")
 		return
 	}
-	// Счетчик кол-во просмотров
-	_, err = collection.UpdateOne(
-		r.Context(),
-		bson.M{"_id": objID},
-		bson.M{"$inc": bson.M{"current_reads": 1}},
-	)
-	if err != nil {
-		log.Printf("Ошибка при обновлении счётчика просмотров: %v", err)
-		// Не прерываем выполнение, так как это не критично
+	paste := cached.Paste
+
+	if err := recordView(r.Context(), paste.ID, r); err != nil {
+		log.Printf("Ошибка записи статистики просмотра: %v", err)
 	}
 
-	// Отображаем страницу с данными пасты
-	tmpl := template.Must(template.ParseFiles("web/readpaste.html"))
-	err = tmpl.Execute(w, paste)
+	// Комментарии к строкам
+	comments, err := loadCommentThreads(r.Context(), paste, viewerID)
 	if err != nil {
-		http.Error(w, "Ошибка рендеринга шаблона", http.StatusInternalServerError)
+		log.Printf("Ошибка загрузки комментариев: %v", err)
 	}
-}
-
-func AllPastesHandler(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
 
-	// Получаем параметры сортировки, фильтрации и пагинации из URL
-	sortOrder, _ := strconv.Atoi(r.URL.Query().Get("sort"))
-	if sortOrder != 1 && sortOrder != -1 {
-		sortOrder = 1 // Значение по умолчанию
+	// Отображаем страницу с данными пасты
+	body, ok := renderPage(w, r, "readpaste.html", struct {
+		models.Paste
+		Body          template.HTML
+		Comments      []*CommentView
+		LoggedIn      bool
+		IsOwner       bool
+		CanEdit       bool
+		CanPropose    bool
+		EditedBy      string // Автор последней ревизии, если это не владелец
+		NeedsPassword bool   // Комментарий к пасте с паролем отправляется вместе с паролем
+	}{
+		Paste:         paste,
+		Body:          cached.Body,
+		Comments:      comments,
+		LoggedIn:      !viewerID.IsZero(),
+		IsOwner:       paste.IsOwner(viewerID),
+		CanEdit:       paste.CanEdit(viewerID),
+		CanPropose:    !viewerID.IsZero() && proposalBlocked(paste, viewerID) == "",
+		EditedBy:      editedBy(paste),
+		NeedsPassword: !pasteUnlocked(paste, viewerID, ""),
+	})
+	if !ok {
+		return
 	}
-
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1 // Первая страница по умолчанию
+	// Страница зависит от вошедшего пользователя
+	w.Header().Add("Vary", "Cookie")
+	if writePasteValidators(w, r, paste, pasteRepHTML, body, viewerID.IsZero()) {
+		return
 	}
+	w.Write(body)
+}
 
-	limit := 5 // Количество паст на одной странице
-	skip := (page - 1) * limit
-
-	filter := r.URL.Query().Get("filter")
-	now := time.Now()
-	var filterCondition bson.M
-
-	switch filter {
-	case "last-year":
-		filterCondition = bson.M{"createdAt": bson.M{"$gte": now.AddDate(-1, 0, 0)}}
-	case "last-month":
-		filterCondition = bson.M{"createdAt": bson.M{"$gte": now.AddDate(0, -1, 0)}}
-	case "last-week":
-		filterCondition = bson.M{"createdAt": bson.M{"$gte": now.AddDate(0, 0, -7)}}
-	case "last-day":
-		filterCondition = bson.M{"createdAt": bson.M{"$gte": now.AddDate(0, 0, -1)}}
-	default:
-		filterCondition = bson.M{} // Без фильтрации
+// Имя автора последней ревизии для подписи; пусто, если правил владелец
+func editedBy(paste models.Paste) string {
+	if paste.UpdatedBy.IsZero() || paste.UpdatedBy == paste.UserID {
+		return ""
 	}
+	return paste.UpdatedByName
+}
 
-	// Настройки сортировки, лимита и пропуска
-	findOptions := options.Find()
-	findOptions.SetSort(bson.D{{Key: "createdAt", Value: sortOrder}})
-	findOptions.SetLimit(int64(limit))
-	findOptions.SetSkip(int64(skip))
-
-	collection := GetCollection("pastes")
-	cursor, err := collection.Find(ctx, filterCondition, findOptions)
+// GET /paste/{id}/raw — содержимое пасты как text/plain; пароль в X-Paste-Password
+func RawPasteHandler(w http.ResponseWriter, r *http.Request) {
+	objID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
 	if err != nil {
-		log.Printf("Error fetching pastes: %v\n", err)
-		http.Error(w, "Failed to fetch pastes", http.StatusInternalServerError)
+		http.Error(w, "Invalid paste ID", http.StatusBadRequest)
 		return
 	}
-	defer cursor.Close(ctx)
 
-	var pastes []models.Paste
-	if err := cursor.All(ctx, &pastes); err != nil {
-		log.Printf("Error decoding pastes: %v\n", err)
-		http.Error(w, "Failed to decode pastes", http.StatusInternalServerError)
+	viewerID, _ := utils.GetUserIDFromToken(r)
+	cached, err := readPaste(r.Context(), objID, viewerID, r.Header.Get(pastePasswordHeader))
+	if err == errPasteNotFound {
+		http.Error(w, "Paste not found", http.StatusNotFound)
+		return
+	} else if err == errPasteLocked {
+		w.Header().Set("Cache-Control", "private, no-store")
+		http.Error(w, "Paste is password protected; send the password in "+pastePasswordHeader, http.StatusForbidden)
+		return
+	} else if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "BD connection error")
 		return
 	}
+	paste := cached.Paste
 
-	// Рендеринг шаблона
-	tmpl := template.Must(template.ParseFiles("web/allpastes.html"))
-	data := struct {
-		Pastes []models.Paste
-		Page   int
-		Next   int
-		Prev   int
-	}{
-		Pastes: pastes,
-		Page:   page,
-		Next:   page + 1,
-		Prev:   page - 1,
+	if err := recordView(r.Context(), paste.ID, r); err != nil {
+		log.Printf("Ошибка записи статистики просмотра: %v", err)
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	header.Set("X-Content-Type-Options", "nosniff")
+	if writePasteValidators(w, r, paste, pasteRepRaw, nil, true) {
+		return
 	}
+	io.WriteString(w, paste.Content)
+}
+
+// Пронумерованные строки пасты; зависят только от содержимого,
+// поэтому кэшируются вместе с ревизией
+var pasteBodyTemplate = template.Must(template.New("body").Parse(`{{range .}}
+<div class="paste-line" id="L{{.Number}}">
+  <a class="line-number" href="#L{{.Number}}" onclick="selectLine({{.Number}}, event)">{{.Number}}</a>
+  <span class="line-text">{{.Text}}</span>
+</div>{{end}}`))
+
+func renderPasteBody(content string) template.HTML {
+	var buf bytes.Buffer
+	if err := pasteBodyTemplate.Execute(&buf, pasteLines(content)); err != nil {
+		log.Printf("Ошибка рендеринга пасты: %v", err)
+		return template.HTML(template.HTMLEscapeString(content))
+	}
+	return template.HTML(buf.String())
+}
 
-	err = tmpl.Execute(w, data)
+// Атомарно засчитываем прочтение пасты с лимитом deleteAfter.
+// Последнее разрешённое прочтение удаляет пасту; когда лимит уже
+// исчерпан, возвращается mongo.ErrNoDocuments
+func consumeLimitedRead(ctx context.Context, paste models.Paste) (models.Paste, error) {
+	collection := GetCollection("pastes")
+	var updated models.Paste
+	err := collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": paste.ID, "currentReads": bson.M{"$lt": paste.DeleteAfter}},
+		bson.M{"$inc": bson.M{"currentReads": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
 	if err != nil {
-		log.Printf("Error rendering template: %v\n", err)
-		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		return paste, err
+	}
+
+	if updated.CurrentReads >= updated.DeleteAfter {
+		if _, err := collection.DeleteOne(ctx, bson.M{"_id": updated.ID}); err != nil {
+			log.Printf("Ошибка удаления прочитанной пасты %s: %v", updated.ID.Hex(), err)
+		} else {
+			events.Publish(PasteExpired{Paste: updated, Reason: "burned"})
+		}
 	}
+	return updated, nil
 }
 
 func DeletePasteHandlerAdmin(w http.ResponseWriter, r *http.Request) {
@@ -258,29 +586,22 @@ func DeletePasteHandlerAdmin(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	collection := GetCollection("pastes")
-	result, err := collection.DeleteOne(ctx, bson.M{"_id": objID})
-	if err != nil {
-		log.Printf("Failed to delete paste with ID %s: %v", id, err)
-		HandleError(w, err, http.StatusInternalServerError, "Failed to delete paste")
-		return
-	}
-
+	var deleted models.Paste
+	err = collection.FindOneAndDelete(ctx, bson.M{"_id": objID}).Decode(&deleted)
 	// Проверка, была ли паста удалена
-	if result.DeletedCount == 0 {
+	if err == mongo.ErrNoDocuments {
 		log.Printf("No paste found with ID %s to delete", id)
 		http.Error(w, "Paste not found", http.StatusNotFound)
 		return
+	} else if err != nil {
+		log.Printf("Failed to delete paste with ID %s: %v", id, err)
+		HandleError(w, err, http.StatusInternalServerError, "Failed to delete paste")
+		return
 	}
-
-	// Логирование удаления
-	if pasteLogger != nil {
-		pasteLogger.Printf("Deleted paste: ID=%s, Date=%s\n", objID.Hex(), time.Now().Format(time.RFC3339))
-	} else {
-		log.Printf("Логгер не инициализирован: удалена паста с ID=%s", objID.Hex())
-	}
+	events.Publish(PasteDeleted{Paste: deleted, ByAdmin: true})
 
 	// Редирект на список паст
-	http.Redirect(w, r, "/all-pastes", http.StatusSeeOther)
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
 }
 func DeletePasteHandler(w http.ResponseWriter, r *http.Request) {
 	userID, err := utils.GetUserIDFromToken(r)
@@ -296,20 +617,34 @@ func DeletePasteHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	filter := bson.M{"_id": pasteID, "user_id": userID}
-	result, err := db.Collection("pastes").DeleteOne(context.TODO(), filter)
-	if err != nil || result.DeletedCount == 0 {
+	var deleted models.Paste
+	err = db.Collection("pastes").FindOneAndDelete(context.TODO(), filter).Decode(&deleted)
+	if err != nil {
 		http.Error(w, "Paste not found or unauthorized", http.StatusForbidden)
 		return
 	}
-	// Логирование удаления
-	if pasteLogger != nil {
-		pasteLogger.Printf("Deleted paste: ID=%s, Date=%s\n", pasteID, time.Now().Format(time.RFC3339))
-	} else {
-		log.Printf("Логгер не инициализирован: удалена паста с ID=%s", pasteID)
-	}
+	events.Publish(PasteDeleted{Paste: deleted})
 	w.WriteHeader(http.StatusOK)
 }
 
+// Автор новой ревизии в обновлении пасты. У правки админа и совместной
+// правки автора нет — прежний снимаем
+func setRevisionAuthor(ctx context.Context, update bson.M, userID primitive.ObjectID) {
+	if userID.IsZero() {
+		unset, _ := update["$unset"].(bson.M)
+		if unset == nil {
+			unset = bson.M{}
+			update["$unset"] = unset
+		}
+		unset["updatedBy"] = ""
+		unset["updatedByName"] = ""
+		return
+	}
+	set := update["$set"].(bson.M)
+	set["updatedBy"] = userID
+	set["updatedByName"] = displayName(ctx, userID)
+}
+
 func EditPasteHandlerAdmin(w http.ResponseWriter, r *http.Request) {
 	// Получение ID из URL
 	vars := mux.Vars(r)
@@ -339,12 +674,7 @@ func EditPasteHandlerAdmin(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Отображаем форму редактирования
-		tmpl := template.Must(template.ParseFiles("web/editpaste.html"))
-		if err := tmpl.Execute(w, paste); err != nil {
-			pasteLogger.Printf("Template execution error for paste ID=%s: %v\n", id, err)
-			http.Error(w, "Failed to render template", http.StatusInternalServerError)
-			return
-		}
+		render(w, r, "editpaste.html", paste)
 		return
 	}
 
@@ -357,19 +687,26 @@ func EditPasteHandlerAdmin(w http.ResponseWriter, r *http.Request) {
 		// Обновляем данные в базе
 		update := bson.M{
 			"$set": bson.M{
-				"title":   title,
-				"content": content,
+				"title":     title,
+				"content":   content,
+				"updatedAt": time.Now(),
 			},
+			"$inc": bson.M{"revision": 1},
 		}
-		_, err := collection.UpdateOne(r.Context(), bson.M{"_id": objID}, update)
-		if err != nil {
+		setRevisionAuthor(r.Context(), update, primitive.NilObjectID)
+		var updated models.Paste
+		err := collection.FindOneAndUpdate(r.Context(), bson.M{"_id": objID}, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&updated)
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "Paste not found", http.StatusNotFound)
+			return
+		} else if err != nil {
 			pasteLogger.Printf("Database update error for paste ID=%s: %v\n", id, err)
 			http.Error(w, "Failed to update paste", http.StatusInternalServerError)
 			return
 		}
-
-		// Логгирование
-		pasteLogger.Printf("Edited paste: ID=%s, Date=%s\n", id, time.Now().Format(time.RFC3339))
+		events.Publish(PasteUpdated{Paste: updated, ByAdmin: true})
 
 		// Редирект на страницу просмотра пасты
 		http.Redirect(w, r, fmt.Sprintf("/paste/%s", id), http.StatusSeeOther)
@@ -423,9 +760,9 @@ func EditPasteHandler(w http.ResponseWriter, r *http.Request) {
 
 	collection := GetCollection("pastes")
 
-	// Проверяем, принадлежит ли паста пользователю
+	// Править может владелец и те, кому паста открыта на редактирование
 	var paste models.Paste
-	err = collection.FindOne(r.Context(), bson.M{"_id": objID, "user_id": userID}).Decode(&paste)
+	err = collection.FindOne(r.Context(), editablePasteFilter(objID, userID)).Decode(&paste)
 	if err == mongo.ErrNoDocuments {
 		http.Error(w, "Paste not found or access denied", http.StatusForbidden)
 		return
@@ -436,11 +773,7 @@ func EditPasteHandler(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method == http.MethodGet {
 		// Отображаем форму редактирования
-		tmpl := template.Must(template.ParseFiles("web/editpaste.html"))
-		if err := tmpl.Execute(w, paste); err != nil {
-			http.Error(w, "Failed to render template", http.StatusInternalServerError)
-			return
-		}
+		render(w, r, "editpaste.html", paste)
 		return
 	}
 
@@ -449,13 +782,22 @@ func EditPasteHandler(w http.ResponseWriter, r *http.Request) {
 		title := r.FormValue("title")
 		content := r.FormValue("content")
 
-		update := bson.M{"$set": bson.M{"title": title, "content": content}}
-		_, err := collection.UpdateOne(r.Context(), bson.M{"_id": objID, "user_id": userID}, update)
+		update := bson.M{
+			"$set": bson.M{"title": title, "content": content, "updatedAt": time.Now()},
+			"$inc": bson.M{"revision": 1},
+		}
+		setRevisionAuthor(r.Context(), update, userID)
+		var updated models.Paste
+		err := collection.FindOneAndUpdate(r.Context(), editablePasteFilter(objID, userID), update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&updated)
 		if err != nil {
 			http.Error(w, "Failed to update paste", http.StatusInternalServerError)
 			return
 		}
+		events.Publish(PasteUpdated{Paste: updated})
 
+		setFlash(w, "Paste updated")
 		http.Redirect(w, r, fmt.Sprintf("/paste/%s", pasteID), http.StatusSeeOther)
 		return
 	}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRawUploadContent(t *testing.T) {
+	// curl --data-binary шлёт x-www-form-urlencoded, но тело берём как есть
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("a=b&c\nline 2\n"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	got, err := rawUploadContent(req)
+	if err != nil || got != "a=b&c\nline 2\n" {
+		t.Errorf("Тело целиком: получено %q, %v", got, err)
+	}
+
+	// curl -F 'f=<-'
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("f", "from stdin\n")
+	mw.Close()
+	req = httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	got, err = rawUploadContent(req)
+	if err != nil || got != "from stdin\n" {
+		t.Errorf("Поле формы: получено %q, %v", got, err)
+	}
+
+	// curl -F 'f=@file'
+	body.Reset()
+	mw = multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("f", "build.log")
+	part.Write([]byte("file body"))
+	mw.Close()
+	req = httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	got, err = rawUploadContent(req)
+	if err != nil || got != "file body" {
+		t.Errorf("Файл: получено %q, %v", got, err)
+	}
+}
+
+func TestRawUploadOption(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/?expires=1day", nil)
+	req.Header.Set("X-Expires", "1week")
+	req.Header.Set("X-Burn", "3")
+	if got := rawUploadOption(req, "expires", "X-Expires"); got != "1day" {
+		t.Errorf("Query-параметр важнее заголовка, получено %q", got)
+	}
+	if got := rawUploadOption(req, "burn", "X-Burn"); got != "3" {
+		t.Errorf("Ожидался заголовок X-Burn, получено %q", got)
+	}
+}
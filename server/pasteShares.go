@@ -0,0 +1,322 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"pastebin/models"
+	"pastebin/utils"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Сколько пользователей может получить доступ к одной пасте
+const pasteMaxShares = 50
+
+var (
+	errShareRole        = errors.New("Role must be view or edit")
+	errShareUnknownUser = errors.New("No user with this email")
+	errShareOwner       = errors.New("The owner already has full access")
+	errShareLimit       = fmt.Errorf("A paste can be shared with at most %d people", pasteMaxShares)
+)
+
+// Доступ к пасте в ответах API
+type APIPasteShare struct {
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Ответ на приглашение: одинаковый, есть ли пользователь с таким email или нет
+type APIShareInvite struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type APIPasteShareList struct {
+	Shares []APIPasteShare `json:"shares"`
+}
+
+// Кому и какой доступ выдать
+type apiShareInput struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// Паста, открытая пользователю, для профиля
+type sharedPaste struct {
+	models.Paste
+	Role string
+}
+
+func toAPIPasteShare(s models.PasteShare) APIPasteShare {
+	return APIPasteShare{UserID: s.UserID.Hex(), Email: s.Email, Role: s.Role, CreatedAt: s.CreatedAt}
+}
+
+// Пасты, которые пользователь может править: свои и открытые ему на редактирование
+func editablePasteFilter(pasteID, userID primitive.ObjectID) bson.M {
+	return bson.M{"_id": pasteID, "$or": bson.A{
+		bson.M{"user_id": userID},
+		bson.M{"shares": bson.M{"$elemMatch": bson.M{"user_id": userID, "role": models.ShareEdit}}},
+	}}
+}
+
+// Открываем пасту владельца пользователю с этим email или меняем его роль.
+// Приглашённый получает письмо, если доступ действительно изменился.
+// errShareUnknownUser обработчики показывают как успех, чтобы по ответу
+// нельзя было проверить, зарегистрирован ли email
+func sharePaste(ctx context.Context, paste models.Paste, email, role string) error {
+	if role != models.ShareView && role != models.ShareEdit {
+		return errShareRole
+	}
+	email = strings.TrimSpace(email)
+	var user models.User
+	err := GetCollection("users").FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	if err == mongo.ErrNoDocuments || email == "" {
+		return errShareUnknownUser
+	} else if err != nil {
+		return err
+	}
+	if user.ID == paste.UserID {
+		return errShareOwner
+	}
+	share := models.PasteShare{UserID: user.ID, Email: user.Email, Role: role, CreatedAt: time.Now()}
+
+	collection := GetCollection("pastes")
+	after := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var updated models.Paste
+	// Уже есть доступ с другой ролью — меняем только роль
+	err = collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": paste.ID, "user_id": paste.UserID,
+			"shares": bson.M{"$elemMatch": bson.M{"user_id": user.ID, "role": bson.M{"$ne": role}}}},
+		bson.M{"$set": bson.M{"shares.$.role": role}},
+		after,
+	).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		err = collection.FindOneAndUpdate(ctx,
+			bson.M{
+				"_id":            paste.ID,
+				"user_id":        paste.UserID,
+				"shares.user_id": bson.M{"$ne": user.ID},
+				fmt.Sprintf("shares.%d", pasteMaxShares-1): bson.M{"$exists": false},
+			},
+			bson.M{"$push": bson.M{"shares": share}},
+			after,
+		).Decode(&updated)
+	}
+	if err == mongo.ErrNoDocuments {
+		// Либо доступ с этой ролью уже выдан, либо мест не осталось
+		n, err := collection.CountDocuments(ctx, bson.M{"_id": paste.ID,
+			"shares": bson.M{"$elemMatch": bson.M{"user_id": user.ID, "role": role}}})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errShareLimit
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Соавтора перевели в читатели
+	if paste.ShareRole(user.ID) == models.ShareEdit && role == models.ShareView {
+		revokeLiveAccess(ctx, paste.ID, user.ID)
+	}
+	hotPastes.Invalidate(paste.ID)
+	events.Publish(PasteShared{Paste: updated, Share: share})
+	return nil
+}
+
+// Пользователь больше не может править пасту: ссылка на совместное
+// редактирование меняется, его открытые редакторы отключаются
+func revokeLiveAccess(ctx context.Context, pasteID, userID primitive.ObjectID) {
+	if err := rotateLiveKey(ctx, pasteID); err != nil {
+		log.Printf("Ошибка смены ключа совместного редактирования пасты %s: %v", pasteID.Hex(), err)
+	}
+	liveSessions.kick(pasteID, userID, "Your edit access to this paste was removed")
+}
+
+// Закрываем пользователю доступ к пасте. Убрать можно любого, если ты
+// владелец, и себя самого
+func unsharePaste(ctx context.Context, pasteID, callerID, userID primitive.ObjectID) error {
+	filter := bson.M{"_id": pasteID, "user_id": callerID}
+	if callerID == userID {
+		filter = bson.M{"_id": pasteID}
+	}
+	result, err := GetCollection("pastes").UpdateOne(ctx, filter,
+		bson.M{"$pull": bson.M{"shares": bson.M{"user_id": userID}}})
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		return errPasteNotFound
+	}
+	revokeLiveAccess(ctx, pasteID, userID)
+	hotPastes.Invalidate(pasteID)
+	return nil
+}
+
+// Пасты других пользователей, открытые этому пользователю
+func listSharedPastes(ctx context.Context, userID primitive.ObjectID) ([]sharedPaste, error) {
+	cursor, err := GetCollection("pastes").Find(ctx,
+		bson.M{"shares.user_id": userID, "$or": bson.A{
+			bson.M{"expiresAt": bson.M{"$exists": false}},
+			bson.M{"expiresAt": bson.M{"$gt": time.Now()}},
+		}},
+		options.Find().SetSort(bson.D{{Key: "updatedAt", Value: -1}}).SetProjection(bson.M{"content": 0}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var pastes []models.Paste
+	if err := cursor.All(ctx, &pastes); err != nil {
+		return nil, err
+	}
+	shared := make([]sharedPaste, 0, len(pastes))
+	for _, p := range pastes {
+		shared = append(shared, sharedPaste{Paste: p, Role: p.ShareRole(userID)})
+	}
+	return shared, nil
+}
+
+// POST /pastes/{id}/shares — владелец открывает пасту пользователю (email, role)
+func SharePasteHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	pasteID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid paste ID", http.StatusBadRequest)
+		return
+	}
+
+	var paste models.Paste
+	err = GetCollection("pastes").FindOne(r.Context(), bson.M{"_id": pasteID, "user_id": userID}).Decode(&paste)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Paste not found or access denied", http.StatusForbidden)
+		return
+	} else if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	email, role := strings.TrimSpace(r.FormValue("email")), r.FormValue("role")
+	err = sharePaste(r.Context(), paste, email, role)
+	switch err {
+	case nil, errShareUnknownUser:
+		setFlash(w, fmt.Sprintf("Shared with %s (%s)", email, role))
+	case errShareRole, errShareOwner, errShareLimit:
+		setFlash(w, err.Error())
+	default:
+		HandleError(w, err, http.StatusInternalServerError, "Failed to share paste")
+		return
+	}
+	http.Redirect(w, r, "/paste/"+pasteID.Hex(), http.StatusSeeOther)
+}
+
+// POST /pastes/{id}/shares/{user}/delete — владелец закрывает доступ,
+// приглашённый может отказаться от него сам
+func UnsharePasteHandler(w http.ResponseWriter, r *http.Request) {
+	callerID, err := utils.GetUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	vars := mux.Vars(r)
+	pasteID, err1 := primitive.ObjectIDFromHex(vars["id"])
+	userID, err2 := primitive.ObjectIDFromHex(vars["user"])
+	if err1 != nil || err2 != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	err = unsharePaste(r.Context(), pasteID, callerID, userID)
+	if err == errPasteNotFound {
+		http.Error(w, "Paste not found or access denied", http.StatusForbidden)
+		return
+	} else if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to remove access")
+		return
+	}
+	if callerID == userID {
+		setFlash(w, "You no longer have access to this paste")
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+		return
+	}
+	setFlash(w, "Access removed")
+	http.Redirect(w, r, "/paste/"+pasteID.Hex(), http.StatusSeeOther)
+}
+
+// GET /api/v1/pastes/{id}/shares
+func APIListPasteSharesHandler(w http.ResponseWriter, r *http.Request) {
+	paste, ok := findOwnedPaste(w, r)
+	if !ok {
+		return
+	}
+	list := APIPasteShareList{Shares: make([]APIPasteShare, 0, len(paste.Shares))}
+	for _, s := range paste.Shares {
+		list.Shares = append(list.Shares, toAPIPasteShare(s))
+	}
+	WriteJSON(w, http.StatusOK, list)
+}
+
+// POST /api/v1/pastes/{id}/shares — выдать доступ или поменять роль.
+// Незарегистрированный email получает тот же 202, что и настоящее приглашение
+func APISharePasteHandler(w http.ResponseWriter, r *http.Request) {
+	paste, ok := findOwnedPaste(w, r)
+	if !ok {
+		return
+	}
+	var in apiShareInput
+	if !decodeJSONBody(w, r, &in) {
+		return
+	}
+
+	err := sharePaste(r.Context(), paste, in.Email, in.Role)
+	switch err {
+	case nil, errShareUnknownUser:
+		WriteJSON(w, http.StatusAccepted, APIShareInvite{Email: strings.TrimSpace(in.Email), Role: in.Role})
+	case errShareRole, errShareOwner:
+		WriteAPIError(w, http.StatusBadRequest, "invalid_share", err.Error())
+	case errShareLimit:
+		WriteAPIError(w, http.StatusConflict, "share_limit", err.Error())
+	default:
+		log.Printf("Ошибка выдачи доступа к пасте %s: %v", paste.ID.Hex(), err)
+		WriteAPIError(w, http.StatusInternalServerError, "internal", "Failed to share paste")
+	}
+}
+
+// DELETE /api/v1/pastes/{id}/shares/{user_id}
+func APIUnsharePasteHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	pasteID, err1 := primitive.ObjectIDFromHex(vars["id"])
+	userID, err2 := primitive.ObjectIDFromHex(vars["user_id"])
+	if err1 != nil || err2 != nil {
+		WriteAPIError(w, http.StatusBadRequest, "invalid_id", "Invalid ID")
+		return
+	}
+
+	err := unsharePaste(r.Context(), pasteID, utils.UserIDFromContext(r.Context()), userID)
+	if err == errPasteNotFound {
+		WriteAPIError(w, http.StatusNotFound, "not_found", "Paste or access entry not found")
+		return
+	} else if err != nil {
+		log.Printf("Ошибка удаления доступа к пасте %s: %v", pasteID.Hex(), err)
+		WriteAPIError(w, http.StatusInternalServerError, "internal", "Failed to remove access")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pastebin/models"
+	"pastebin/utils"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestPasteShareAccess(t *testing.T) {
+	owner, viewer, editor, stranger := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	hash, err := utils.HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paste := models.Paste{
+		UserID:     owner,
+		Visibility: models.VisibilityPrivate,
+		Password:   hash,
+		Shares: []models.PasteShare{
+			{UserID: viewer, Role: models.ShareView},
+			{UserID: editor, Role: models.ShareEdit},
+		},
+	}
+
+	cases := []struct {
+		name                      string
+		user                      primitive.ObjectID
+		view, edit, skipsPassword bool
+	}{
+		{"владелец", owner, true, true, true},
+		{"читатель", viewer, true, false, true},
+		{"соавтор", editor, true, true, true},
+		{"чужой", stranger, false, false, false},
+		{"аноним", primitive.NilObjectID, false, false, false},
+	}
+	for _, c := range cases {
+		if got := paste.CanView(c.user); got != c.view {
+			t.Errorf("%s: CanView = %v", c.name, got)
+		}
+		if got := paste.CanEdit(c.user); got != c.edit {
+			t.Errorf("%s: CanEdit = %v", c.name, got)
+		}
+		if got := pasteUnlocked(paste, c.user, ""); got != c.skipsPassword {
+			t.Errorf("%s: pasteUnlocked без пароля = %v", c.name, got)
+		}
+	}
+
+	// У анонимной пасты нулевой user_id не делает анонима владельцем
+	anonymous := models.Paste{Visibility: models.VisibilityPrivate}
+	if anonymous.CanEdit(primitive.NilObjectID) || anonymous.CanView(primitive.NilObjectID) {
+		t.Error("аноним получил доступ к анонимной приватной пасте")
+	}
+}
+
+func TestNotifyShareSendsInvitation(t *testing.T) {
+	t.Setenv("BASE_URL", "https://paste.example")
+	var to, body string
+	old := sendNotification
+	sendNotification = func(addr, text string) error {
+		to, body = addr, text
+		return nil
+	}
+	defer func() { sendNotification = old }()
+
+	paste := models.Paste{ID: primitive.NewObjectID(), Title: "deploy.sh"}
+	notifyOnEvent(PasteShared{Paste: paste, Share: models.PasteShare{Email: "bob@example.com", Role: models.ShareEdit}})
+
+	if to != "bob@example.com" {
+		t.Errorf("письмо ушло на %q", to)
+	}
+	for _, want := range []string{`"deploy.sh"`, "view and edit", "https://paste.example/paste/" + paste.ID.Hex()} {
+		if !strings.Contains(body, want) {
+			t.Errorf("в письме нет %q: %s", want, body)
+		}
+	}
+}
+
+func TestAPISharePaste(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	pasteID, owner, bob := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	paste := bson.D{{Key: "_id", Value: pasteID}, {Key: "user_id", Value: owner}}
+	bobUser := bson.D{{Key: "_id", Value: bob}, {Key: "email", Value: "bob@example.com"}}
+	shared := bson.D{{Key: "_id", Value: pasteID}, {Key: "user_id", Value: owner}, {Key: "shares", Value: bson.A{
+		bson.D{{Key: "user_id", Value: bob}, {Key: "email", Value: "bob@example.com"}, {Key: "role", Value: models.ShareEdit}},
+	}}}
+
+	share := func(mt *mtest.T, responses ...bson.D) (*httptest.ResponseRecorder, []Event) {
+		useTestDB(mt)
+		var published []Event
+		old := events
+		events = NewEventBus()
+		events.Subscribe("test", 0, Inline, func(e Event) { published = append(published, e) })
+		mt.Cleanup(func() { events = old })
+
+		mt.AddMockResponses(append([]bson.D{mtest.CreateCursorResponse(0, "pastebin.pastes", mtest.FirstBatch, paste)}, responses...)...)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/pastes/"+pasteID.Hex()+"/shares",
+			strings.NewReader(`{"email": "bob@example.com", "role": "edit"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req = mux.SetURLVars(req, map[string]string{"id": pasteID.Hex()})
+		req = req.WithContext(utils.ContextWithUserID(req.Context(), owner))
+		rec := httptest.NewRecorder()
+		APISharePasteHandler(rec, req)
+		return rec, published
+	}
+
+	var invited string
+	mt.Run("новый доступ", func(mt *mtest.T) {
+		rec, published := share(mt,
+			mtest.CreateCursorResponse(0, "pastebin.users", mtest.FirstBatch, bobUser),
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}),
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: shared}),
+		)
+		if rec.Code != http.StatusAccepted || len(published) != 1 {
+			mt.Errorf("статус %d, событий %d", rec.Code, len(published))
+		}
+		invited = rec.Body.String()
+	})
+
+	mt.Run("роль не изменилась", func(mt *mtest.T) {
+		rec, published := share(mt,
+			mtest.CreateCursorResponse(0, "pastebin.users", mtest.FirstBatch, bobUser),
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}),
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}),
+			mtest.CreateCursorResponse(0, "pastebin.pastes", mtest.FirstBatch, bson.D{{Key: "n", Value: 1}}),
+		)
+		if rec.Code != http.StatusAccepted || len(published) != 0 {
+			mt.Errorf("статус %d, событий %d: повторное приглашение не должно слать письмо", rec.Code, len(published))
+		}
+	})
+
+	mt.Run("email не зарегистрирован", func(mt *mtest.T) {
+		rec, published := share(mt, mtest.CreateCursorResponse(0, "pastebin.users", mtest.FirstBatch))
+		if rec.Code != http.StatusAccepted || rec.Body.String() != invited || len(published) != 0 {
+			mt.Errorf("статус %d, тело %q, событий %d; ответ должен совпадать с приглашением %q",
+				rec.Code, rec.Body.String(), len(published), invited)
+		}
+	})
+}
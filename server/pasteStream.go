@@ -0,0 +1,198 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"pastebin/models"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Параметры потока новых паст
+const (
+	pasteStreamClientBuffer = 32
+	pasteStreamHeartbeat    = 25 * time.Second
+	pasteStreamBackfill     = 100 // Сколько пропущенных паст досылаем по Last-Event-ID
+	pasteStreamRetryMillis  = 5000
+)
+
+// Рассылка новых публичных паст подключённым SSE-клиентам. ID события —
+// ID пасты, поэтому переподключение с Last-Event-ID досылает пропущенное
+// из базы даже после перезапуска сервера
+type pasteStreamHub struct {
+	mu      sync.Mutex
+	clients map[chan models.Paste]struct{}
+	closed  bool
+}
+
+var pasteStream = &pasteStreamHub{clients: make(map[chan models.Paste]struct{})}
+
+// Закрываем все потоки при остановке: иначе Shutdown ждал бы их до таймаута
+func ClosePasteStreams() {
+	pasteStream.close()
+}
+
+func (h *pasteStreamHub) subscribe() (chan models.Paste, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, false
+	}
+	ch := make(chan models.Paste, pasteStreamClientBuffer)
+	h.clients[ch] = struct{}{}
+	return ch, true
+}
+
+func (h *pasteStreamHub) unsubscribe(ch chan models.Paste) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[ch]; ok {
+		delete(h.clients, ch)
+		close(ch)
+	}
+}
+
+// Отстающего клиента отключаем: браузер переподключится с Last-Event-ID
+// и получит пропущенное из базы
+func (h *pasteStreamHub) broadcast(p models.Paste) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.clients {
+		select {
+		case ch <- p:
+		default:
+			delete(h.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+func (h *pasteStreamHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for ch := range h.clients {
+		delete(h.clients, ch)
+		close(ch)
+	}
+}
+
+// Подписчик шины событий
+func (h *pasteStreamHub) onEvent(e Event) {
+	if created, ok := e.(PasteCreated); ok && created.Paste.IsPublic() {
+		h.broadcast(created.Paste)
+	}
+}
+
+// Метаданные пасты в потоке, без содержимого
+func streamPaste(r *http.Request, p models.Paste) APIPaste {
+	out := toAPIPaste(r, p)
+	out.Content = ""
+	return out
+}
+
+func writePasteEvent(w http.ResponseWriter, r *http.Request, p models.Paste) error {
+	data, err := json.Marshal(streamPaste(r, p))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: paste\ndata: %s\n\n", p.ID.Hex(), data)
+	return err
+}
+
+// Публичные пасты, созданные после пасты с ID after, от старых к новым
+func pastesAfter(ctx context.Context, after primitive.ObjectID) ([]models.Paste, error) {
+	cursor, err := GetCollection("pastes").Find(ctx,
+		bson.M{"_id": bson.M{"$gt": after}, "visibility": bson.M{"$in": bson.A{models.VisibilityPublic, "", nil}}},
+		options.Find().
+			SetSort(bson.D{{Key: "_id", Value: 1}}).
+			SetLimit(pasteStreamBackfill).
+			SetProjection(bson.M{"content": 0}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var pastes []models.Paste
+	err = cursor.All(ctx, &pastes)
+	return pastes, err
+}
+
+// GET /events/pastes — поток новых публичных паст (Server-Sent Events)
+func PasteEventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// Подписываемся до догрузки из базы, чтобы не потерять пасты между ними
+	ch, ok := pasteStream.subscribe()
+	if !ok {
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer pasteStream.unsubscribe(ch)
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // nginx не должен буферизовать поток
+	fmt.Fprintf(w, "retry: %d\n\n", pasteStreamRetryMillis)
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id") // Для клиентов без заголовков
+	}
+	var last primitive.ObjectID
+	if id, err := primitive.ObjectIDFromHex(lastID); err == nil {
+		missed, err := pastesAfter(r.Context(), id)
+		if err != nil {
+			log.Printf("Ошибка догрузки паст для потока: %v", err)
+		}
+		for _, p := range missed {
+			if p.IsExpired(time.Now()) {
+				continue
+			}
+			if err := writePasteEvent(w, r, p); err != nil {
+				return
+			}
+			last = p.ID
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(pasteStreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			// Комментарий держит соединение открытым через прокси
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case p, ok := <-ch:
+			if !ok {
+				return
+			}
+			// Уже отправлена при догрузке
+			if bytes.Compare(p.ID[:], last[:]) <= 0 {
+				continue
+			}
+			if err := writePasteEvent(w, r, p); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pastebin/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPasteEventsStreamsPublicPastes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(PasteEventsHandler))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	reader := bufio.NewReader(resp.Body)
+	if line, _ := reader.ReadString('\n'); !strings.HasPrefix(line, "retry:") {
+		t.Fatalf("first line = %q", line)
+	}
+
+	private := models.Paste{ID: primitive.NewObjectID(), Visibility: models.VisibilityPrivate, Content: "x"}
+	public := models.Paste{ID: primitive.NewObjectID(), Title: "hello", Content: "secret body", CreatedAt: time.Now()}
+	pasteStream.onEvent(PasteCreated{Paste: private})
+	pasteStream.onEvent(PasteCreated{Paste: public})
+
+	var id, data string
+	for data == "" {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimSpace(strings.TrimPrefix(line, "id: "))
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	if id != public.ID.Hex() {
+		t.Errorf("event id = %q, want the public paste", id)
+	}
+	var got APIPaste
+	if err := json.Unmarshal([]byte(data), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "hello" || got.Content != "" {
+		t.Errorf("event data = %+v", got)
+	}
+}
+
+func TestPasteStreamDisconnectsSlowClients(t *testing.T) {
+	hub := &pasteStreamHub{clients: make(map[chan models.Paste]struct{})}
+	ch, _ := hub.subscribe()
+	for i := 0; i < pasteStreamClientBuffer+1; i++ {
+		hub.broadcast(models.Paste{})
+	}
+	n := 0
+	for range ch {
+		n++
+	}
+	if n != pasteStreamClientBuffer {
+		t.Errorf("received %d events before disconnect", n)
+	}
+	hub.unsubscribe(ch) // Повторное отключение безопасно
+}
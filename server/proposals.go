@@ -0,0 +1,445 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"pastebin/models"
+	"pastebin/utils"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var errProposalConflict = errors.New("The paste has changed since this edit was proposed, and the changes overlap")
+
+// Предложение хранит в одном документе и исходный, и новый текст, а MongoDB
+// ограничивает документ 16 МБ
+const proposalMaxBytes = 15 << 20
+
+// Предложение на странице: разница с текстом, от которого оно сделано
+type proposalPage struct {
+	models.Proposal
+	Hunks     []diffHunk
+	Added     int
+	Removed   int
+	IsOwner   bool
+	IsAuthor  bool
+	Outdated  bool // Паста изменилась после предложения
+	PasteGone bool
+}
+
+// Можно ли пользователю предложить правку этой пасты. Пусто — можно,
+// иначе причина для пользователя
+func proposalBlocked(paste models.Paste, userID primitive.ObjectID) string {
+	switch {
+	case paste.IsOwner(userID):
+		return "You own this paste; edit it directly"
+	case paste.UserID.IsZero():
+		return "Anonymous pastes have no owner to review edits"
+	case paste.DeleteAfter > 0:
+		return "Burn-after-reading pastes can't be edited"
+	case paste.HasPassword() && paste.ShareRole(userID) == "":
+		return "Edits to password-protected pastes can only be proposed by people it is shared with"
+	}
+	return ""
+}
+
+// Паста, к которой пользователь предлагает правку; иначе ответ уже отправлен
+func proposalTarget(w http.ResponseWriter, r *http.Request, userID primitive.ObjectID) (models.Paste, bool) {
+	var paste models.Paste
+	pasteID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid paste ID", http.StatusBadRequest)
+		return paste, false
+	}
+	err = GetCollection("pastes").FindOne(r.Context(), bson.M{"_id": pasteID}).Decode(&paste)
+	if err == mongo.ErrNoDocuments || (err == nil && (paste.IsExpired(time.Now()) || !paste.CanView(userID))) {
+		http.Error(w, "Paste not found", http.StatusNotFound)
+		return paste, false
+	} else if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Database error")
+		return paste, false
+	}
+	if reason := proposalBlocked(paste, userID); reason != "" {
+		http.Error(w, reason, http.StatusForbidden)
+		return paste, false
+	}
+	return paste, true
+}
+
+// Форма предложения правки
+type proposalForm struct {
+	models.Paste
+	Message string
+	Changed bool // Паста изменилась, пока правку писали
+}
+
+// GET /paste/{id}/propose — форма с текущим текстом пасты
+func ProposeEditPageHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetUserIDFromToken(r)
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	paste, ok := proposalTarget(w, r, userID)
+	if !ok {
+		return
+	}
+	render(w, r, "proposeedit.html", proposalForm{Paste: paste})
+}
+
+// POST /paste/{id}/proposals — сохраняем предложенную правку
+func CreateProposalHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetUserIDFromToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	// Те же ограничения, что и у новой пасты
+	r.Body = http.MaxBytesReader(w, r.Body, rawUploadMaxBytes)
+	if err := r.ParseForm(); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "Paste is too large", http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "Failed to parse form data", http.StatusBadRequest)
+		}
+		return
+	}
+	paste, ok := proposalTarget(w, r, userID)
+	if !ok {
+		return
+	}
+
+	title := r.FormValue("title")
+	content := r.FormValue("content")
+	message := strings.TrimSpace(r.FormValue("message"))
+	if content == "" {
+		http.Error(w, "Content is required", http.StatusBadRequest)
+		return
+	}
+
+	// Текст, от которого писалась правка, уже перезаписан: показываем форму
+	// снова поверх новой ревизии, чтобы автор сверил свою правку
+	if baseRevision, err := strconv.Atoi(r.FormValue("base_revision")); err != nil || baseRevision != paste.Revision {
+		form := proposalForm{Paste: paste, Message: message, Changed: true}
+		form.Title, form.Content = title, content
+		render(w, r, "proposeedit.html", form)
+		return
+	}
+	if title == paste.Title && content == paste.Content {
+		http.Error(w, "Nothing has changed", http.StatusBadRequest)
+		return
+	}
+	if len(paste.Title)+len(paste.Content)+len(title)+len(content)+len(message) > proposalMaxBytes {
+		http.Error(w, "This paste is too large to propose edits to", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	proposal := models.Proposal{
+		ID:           primitive.NewObjectID(),
+		PasteID:      paste.ID,
+		OwnerID:      paste.UserID,
+		AuthorID:     userID,
+		AuthorName:   displayName(r.Context(), userID),
+		Message:      message,
+		Title:        title,
+		Content:      content,
+		BaseRevision: paste.Revision,
+		BaseTitle:    paste.Title,
+		BaseContent:  paste.Content,
+		Status:       models.ProposalOpen,
+		CreatedAt:    time.Now(),
+	}
+	if _, err := GetCollection("proposals").InsertOne(r.Context(), proposal); err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to save proposal")
+		return
+	}
+	setFlash(w, "Edit proposed; the owner will review it")
+	http.Redirect(w, r, "/proposals/"+proposal.ID.Hex(), http.StatusSeeOther)
+}
+
+// Предложение из URL, если пользователь — его автор или владелец пасты.
+// Иначе ответ уже отправлен
+func findProposal(w http.ResponseWriter, r *http.Request) (models.Proposal, primitive.ObjectID, bool) {
+	var proposal models.Proposal
+	userID, err := utils.GetUserIDFromToken(r)
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return proposal, userID, false
+	}
+	proposalID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid proposal ID", http.StatusBadRequest)
+		return proposal, userID, false
+	}
+	err = GetCollection("proposals").FindOne(r.Context(), bson.M{"_id": proposalID}).Decode(&proposal)
+	if err == mongo.ErrNoDocuments || (err == nil && userID != proposal.OwnerID && userID != proposal.AuthorID) {
+		http.Error(w, "Proposal not found", http.StatusNotFound)
+		return proposal, userID, false
+	} else if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Database error")
+		return proposal, userID, false
+	}
+	return proposal, userID, true
+}
+
+// GET /proposals/{id} — разница, обсуждение и решение владельца
+func ProposalHandler(w http.ResponseWriter, r *http.Request) {
+	proposal, userID, ok := findProposal(w, r)
+	if !ok {
+		return
+	}
+
+	page := proposalPage{
+		Proposal: proposal,
+		IsOwner:  userID == proposal.OwnerID,
+		IsAuthor: userID == proposal.AuthorID,
+	}
+	lines := lineDiff(proposal.BaseContent, proposal.Content)
+	for _, line := range lines {
+		switch line.Kind {
+		case "add":
+			page.Added++
+		case "del":
+			page.Removed++
+		}
+	}
+	page.Hunks = diffHunks(lines, diffContext)
+
+	var paste models.Paste
+	err := GetCollection("pastes").FindOne(r.Context(), bson.M{"_id": proposal.PasteID},
+		options.FindOne().SetProjection(bson.M{"revision": 1})).Decode(&paste)
+	if err == mongo.ErrNoDocuments {
+		page.PasteGone = true
+	} else if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Database error")
+		return
+	}
+	page.Outdated = !page.PasteGone && paste.Revision != proposal.BaseRevision
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	render(w, r, "proposal.html", page)
+}
+
+// POST /proposals/{id}/comments — комментарий автора или владельца
+func ProposalCommentHandler(w http.ResponseWriter, r *http.Request) {
+	proposal, userID, ok := findProposal(w, r)
+	if !ok {
+		return
+	}
+	content := strings.TrimSpace(r.FormValue("content"))
+	if content == "" {
+		http.Error(w, "Content is required", http.StatusBadRequest)
+		return
+	}
+
+	comment := models.ProposalComment{
+		UserID:     userID,
+		AuthorName: displayName(r.Context(), userID),
+		Content:    content,
+		CreatedAt:  time.Now(),
+	}
+	_, err := GetCollection("proposals").UpdateOne(r.Context(), bson.M{"_id": proposal.ID},
+		bson.M{"$push": bson.M{"comments": comment}})
+	if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to save comment")
+		return
+	}
+	http.Redirect(w, r, "/proposals/"+proposal.ID.Hex(), http.StatusSeeOther)
+}
+
+// Название и текст пасты после принятия правки. Если паста изменилась
+// после предложения, правка переносится на текущий текст, когда изменения
+// не пересекаются
+func mergeProposal(p models.Proposal, current models.Paste) (string, string, error) {
+	title := p.Title
+	if current.Title != p.BaseTitle && p.Title != current.Title {
+		if p.Title != p.BaseTitle {
+			return "", "", errProposalConflict
+		}
+		title = current.Title
+	}
+	if current.Content == p.BaseContent || current.Content == p.Content {
+		return title, p.Content, nil
+	}
+
+	base := utf16.Encode([]rune(p.BaseContent))
+	proposed := utf16.Encode([]rune(p.Content))
+	now := utf16.Encode([]rune(current.Content))
+	minePrefix, mineSuffix := commonEnds(base, proposed)
+	theirPrefix, theirSuffix := commonEnds(base, now)
+	if !(len(base)-mineSuffix < theirPrefix || len(base)-theirSuffix < minePrefix) {
+		return "", "", errProposalConflict
+	}
+	_, mine, err := transformOps(diffOp(base, now), diffOp(base, proposed))
+	if err != nil {
+		return "", "", err
+	}
+	merged, err := mine.apply(now)
+	if err != nil {
+		return "", "", err
+	}
+	return title, string(utf16.Decode(merged)), nil
+}
+
+// POST /proposals/{id}/accept — владелец принимает правку: новая ревизия
+// пасты от имени автора предложения
+func AcceptProposalHandler(w http.ResponseWriter, r *http.Request) {
+	proposal, userID, ok := findProposal(w, r)
+	if !ok {
+		return
+	}
+	if userID != proposal.OwnerID {
+		http.Error(w, "Only the paste owner can accept edits", http.StatusForbidden)
+		return
+	}
+	back := "/proposals/" + proposal.ID.Hex()
+	if proposal.Status != models.ProposalOpen {
+		setFlash(w, "This proposal is already "+proposal.Status)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	pastes := GetCollection("pastes")
+	var paste models.Paste
+	err := pastes.FindOne(ctx, bson.M{"_id": proposal.PasteID, "user_id": userID}).Decode(&paste)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Paste no longer exists", http.StatusNotFound)
+		return
+	} else if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Database error")
+		return
+	}
+	title, content, err := mergeProposal(proposal, paste)
+	if err != nil {
+		setFlash(w, err.Error())
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+
+	// Сначала закрываем предложение, чтобы повторное нажатие не создало
+	// вторую ревизию
+	proposals := GetCollection("proposals")
+	result, err := proposals.UpdateOne(ctx, bson.M{"_id": proposal.ID, "status": models.ProposalOpen},
+		bson.M{"$set": bson.M{"status": models.ProposalAccepted, "resolvedAt": time.Now()}})
+	if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to update proposal")
+		return
+	} else if result.ModifiedCount == 0 {
+		setFlash(w, "This proposal is already resolved")
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+
+	update := bson.M{
+		"$set": bson.M{"title": title, "content": content, "updatedAt": time.Now()},
+		"$inc": bson.M{"revision": 1},
+	}
+	setRevisionAuthor(ctx, update, proposal.AuthorID)
+	var updated models.Paste
+	err = pastes.FindOneAndUpdate(ctx,
+		bson.M{"_id": paste.ID, "user_id": userID, "revision": paste.Revision},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		// Паста изменилась между чтением и записью: предложение снова открыто
+		if _, err := proposals.UpdateOne(ctx, bson.M{"_id": proposal.ID},
+			bson.M{"$set": bson.M{"status": models.ProposalOpen}, "$unset": bson.M{"resolvedAt": ""}}); err != nil {
+			log.Printf("Ошибка возврата предложения %s: %v", proposal.ID.Hex(), err)
+		}
+		if err == mongo.ErrNoDocuments {
+			setFlash(w, "The paste changed while accepting; try again")
+			http.Redirect(w, r, back, http.StatusSeeOther)
+			return
+		}
+		HandleError(w, err, http.StatusInternalServerError, "Failed to update paste")
+		return
+	}
+
+	if _, err := proposals.UpdateOne(ctx, bson.M{"_id": proposal.ID},
+		bson.M{"$set": bson.M{"merged_revision": updated.Revision}}); err != nil {
+		log.Printf("Ошибка сохранения ревизии предложения %s: %v", proposal.ID.Hex(), err)
+	}
+	events.Publish(PasteUpdated{Paste: updated})
+
+	setFlash(w, fmt.Sprintf("Accepted as revision %d", updated.Revision))
+	http.Redirect(w, r, "/paste/"+paste.ID.Hex(), http.StatusSeeOther)
+}
+
+// POST /proposals/{id}/reject — владелец отклоняет правку
+func RejectProposalHandler(w http.ResponseWriter, r *http.Request) {
+	closeProposal(w, r, models.ProposalRejected)
+}
+
+// POST /proposals/{id}/withdraw — автор отзывает свою правку
+func WithdrawProposalHandler(w http.ResponseWriter, r *http.Request) {
+	closeProposal(w, r, models.ProposalWithdrawn)
+}
+
+func closeProposal(w http.ResponseWriter, r *http.Request, status string) {
+	proposal, userID, ok := findProposal(w, r)
+	if !ok {
+		return
+	}
+	allowed := proposal.OwnerID
+	if status == models.ProposalWithdrawn {
+		allowed = proposal.AuthorID
+	}
+	if userID != allowed {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	result, err := GetCollection("proposals").UpdateOne(r.Context(),
+		bson.M{"_id": proposal.ID, "status": models.ProposalOpen},
+		bson.M{"$set": bson.M{"status": status, "resolvedAt": time.Now()}})
+	if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to update proposal")
+		return
+	}
+	if result.ModifiedCount == 0 {
+		setFlash(w, "This proposal is already resolved")
+	} else {
+		setFlash(w, "Proposal "+status)
+	}
+	http.Redirect(w, r, "/proposals/"+proposal.ID.Hex(), http.StatusSeeOther)
+}
+
+// Паста удалена или истекла: её открытые предложения больше нельзя принять
+func closePasteProposals(ctx context.Context, pasteID primitive.ObjectID) error {
+	_, err := GetCollection("proposals").UpdateMany(ctx,
+		bson.M{"paste_id": pasteID, "status": models.ProposalOpen},
+		bson.M{"$set": bson.M{"status": models.ProposalClosed, "resolvedAt": time.Now()}})
+	return err
+}
+
+// Открытые предложения к пастам владельца для профиля, без текстов
+func listOpenProposals(ctx context.Context, ownerID primitive.ObjectID) ([]models.Proposal, error) {
+	cursor, err := GetCollection("proposals").Find(ctx,
+		bson.M{"owner_id": ownerID, "status": models.ProposalOpen},
+		options.Find().
+			SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+			SetProjection(bson.M{"content": 0, "base_content": 0, "comments": 0}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var proposals []models.Proposal
+	err = cursor.All(ctx, &proposals)
+	return proposals, err
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"pastebin/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Разница в виде строк "+текст", "-текст", " текст"
+func diffText(lines []diffLine) string {
+	var out []string
+	for _, l := range lines {
+		mark := map[string]string{"same": " ", "add": "+", "del": "-"}[l.Kind]
+		out = append(out, mark+l.Text)
+	}
+	return strings.Join(out, "|")
+}
+
+func TestLineDiff(t *testing.T) {
+	got := diffText(lineDiff("a\nb\nc\nd", "a\nx\nc\nd\ne"))
+	if want := " a|-b|+x| c| d|+e"; got != want {
+		t.Errorf("lineDiff = %q, want %q", got, want)
+	}
+
+	lines := lineDiff("one\r\ntwo", "one\ntwo\n")
+	if last := lines[len(lines)-1]; last.Kind != "add" || last.NewLine != 3 || lines[1].OldLine != 2 {
+		t.Errorf("номера строк: %+v", lines)
+	}
+}
+
+func TestDiffHunks(t *testing.T) {
+	var old, new []string
+	for i := 1; i <= 30; i++ {
+		old = append(old, fmt.Sprint(i))
+		new = append(new, fmt.Sprint(i))
+	}
+	new[4], new[24] = "five", "twenty-five"
+	hunks := diffHunks(lineDiff(strings.Join(old, "\n"), strings.Join(new, "\n")), 3)
+	if len(hunks) != 2 {
+		t.Fatalf("ожидалось два куска, получено %d", len(hunks))
+	}
+	if first := hunks[0].Lines; first[0].OldLine != 2 || first[len(first)-1].OldLine != 8 {
+		t.Errorf("контекст первого куска: строки %d-%d", first[0].OldLine, first[len(first)-1].OldLine)
+	}
+	if hunks := diffHunks(lineDiff("a", "a"), 3); len(hunks) != 0 {
+		t.Errorf("без изменений: %d кусков", len(hunks))
+	}
+}
+
+func TestMergeProposal(t *testing.T) {
+	proposal := models.Proposal{
+		BaseTitle:   "script",
+		Title:       "script",
+		BaseContent: "line 1\nline 2\nline 3\n",
+		Content:     "line 1\nline 2\nline 3 fixed\n",
+	}
+
+	// Паста не менялась — принимается предложенный текст
+	_, content, err := mergeProposal(proposal, models.Paste{Title: "script", Content: proposal.BaseContent})
+	if err != nil || content != proposal.Content {
+		t.Errorf("без изменений: %q, %v", content, err)
+	}
+
+	// Владелец успел поправить другое место и название
+	title, content, err := mergeProposal(proposal, models.Paste{Title: "deploy", Content: "LINE 1\nline 2\nline 3\n"})
+	if err != nil || content != "LINE 1\nline 2\nline 3 fixed\n" || title != "deploy" {
+		t.Errorf("перенос правки: %q, %q, %v", title, content, err)
+	}
+
+	// Та же строка изменена по-другому
+	if _, _, err := mergeProposal(proposal, models.Paste{Title: "script", Content: "line 1\nline 2\nline 3 broken\n"}); err != errProposalConflict {
+		t.Errorf("пересечение: %v", err)
+	}
+
+	// Оба поменяли название по-разному
+	renamed := proposal
+	renamed.Title = "fix"
+	if _, _, err := mergeProposal(renamed, models.Paste{Title: "deploy", Content: proposal.BaseContent}); err != errProposalConflict {
+		t.Errorf("конфликт названий: %v", err)
+	}
+}
+
+func TestProposalBlocked(t *testing.T) {
+	owner, other := primitive.NewObjectID(), primitive.NewObjectID()
+	paste := models.Paste{UserID: owner}
+	if reason := proposalBlocked(paste, other); reason != "" {
+		t.Errorf("обычная паста: %q", reason)
+	}
+	for name, p := range map[string]models.Paste{
+		"своя":      {UserID: other},
+		"анонимная": {},
+		"сгорающая": {UserID: owner, DeleteAfter: 1},
+		"с паролем": {UserID: owner, Password: "hash"},
+	} {
+		if proposalBlocked(p, other) == "" {
+			t.Errorf("%s паста: правку предложить нельзя", name)
+		}
+	}
+	shared := models.Paste{UserID: owner, Password: "hash", Shares: []models.PasteShare{{UserID: other, Role: models.ShareView}}}
+	if reason := proposalBlocked(shared, other); reason != "" {
+		t.Errorf("паста с паролем, открытая пользователю: %q", reason)
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"pastebin/models"
+	"pastebin/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/skip2/go-qrcode"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Максимальный размер пасты, которую можно закодировать в QR целиком
+const qrMaxContentBytes = 1024
+
+// Размер PNG по умолчанию и допустимые границы
+const (
+	qrDefaultSize = 256
+	qrMinSize     = 64
+	qrMaxSize     = 1024
+)
+
+// Уровень коррекции ошибок из параметра ?ec=L|M|Q|H
+func parseRecoveryLevel(value string) (qrcode.RecoveryLevel, error) {
+	switch strings.ToUpper(value) {
+	case "", "M":
+		return qrcode.Medium, nil
+	case "L":
+		return qrcode.Low, nil
+	case "Q":
+		return qrcode.High, nil
+	case "H":
+		return qrcode.Highest, nil
+	}
+	return 0, fmt.Errorf("unknown error correction level %q", value)
+}
+
+// Строим QR-код для пасты: ссылку или (для маленьких паст) само содержимое
+func buildPasteQR(w http.ResponseWriter, r *http.Request) (*qrcode.QRCode, bool) {
+	objID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		HandleError(w, err, http.StatusBadRequest, "Invalid ID format")
+		return nil, false
+	}
+
+	level, err := parseRecoveryLevel(r.URL.Query().Get("ec"))
+	if err != nil {
+		HandleError(w, err, http.StatusBadRequest, "Invalid error correction level")
+		return nil, false
+	}
+
+	var paste models.Paste
+	err = GetCollection("pastes").FindOne(r.Context(), bson.M{"_id": objID}).Decode(&paste)
+	if err == mongo.ErrNoDocuments {
+		HandleError(w, err, http.StatusNotFound, "Paste not found")
+		return nil, false
+	} else if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "BD connection error")
+		return nil, false
+	}
+
+	// Ссылку на пасту с паролем отдать можно, а вот её содержимое — нет
+	viewerID, _ := utils.GetUserIDFromToken(r)
+	access := checkPasteAccess(r.Context(), paste, viewerID, "")
+	if access == errPasteNotFound {
+		HandleError(w, nil, http.StatusNotFound, "Paste not found")
+		return nil, false
+	}
+
+	payload := pasteURL(r, paste.ID)
+	if r.URL.Query().Get("mode") == "content" {
+		if access == errPasteLocked {
+			HandleError(w, nil, http.StatusForbidden, "Paste is password protected")
+			return nil, false
+		}
+		// Прочтения через QR-код не считаются, иначе лимит легко обойти
+		if paste.DeleteAfter > 0 {
+			HandleError(w, nil, http.StatusForbidden, "Burn-after-reading pastes can't be encoded in a QR code")
+			return nil, false
+		}
+		if len(paste.Content) > qrMaxContentBytes {
+			HandleError(w, nil, http.StatusRequestEntityTooLarge, "Paste is too large to encode its content")
+			return nil, false
+		}
+		payload = paste.Content
+	}
+
+	q, err := qrcode.New(payload, level)
+	if err != nil {
+		HandleError(w, err, http.StatusBadRequest, "Failed to build QR code")
+		return nil, false
+	}
+	return q, true
+}
+
+// QR-код пасты в PNG
+func PasteQRPNGHandler(w http.ResponseWriter, r *http.Request) {
+	q, ok := buildPasteQR(w, r)
+	if !ok {
+		return
+	}
+
+	size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+	if size < qrMinSize || size > qrMaxSize {
+		size = qrDefaultSize
+	}
+
+	png, err := q.PNG(size)
+	if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to render QR code")
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(png)
+}
+
+// QR-код пасты в SVG
+func PasteQRSVGHandler(w http.ResponseWriter, r *http.Request) {
+	q, ok := buildPasteQR(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Write(renderQRSVG(q.Bitmap()))
+}
+
+// Рисуем матрицу QR-кода как SVG: по одному прямоугольнику на тёмный модуль
+func renderQRSVG(bitmap [][]bool) []byte {
+	var buf bytes.Buffer
+	size := len(bitmap)
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/>`, size, size)
+	buf.WriteString(`<path fill="#000" d="`)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes()
+}
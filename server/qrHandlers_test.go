@@ -0,0 +1,124 @@
+package server
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pastebin/models"
+
+	"github.com/gorilla/mux"
+	"github.com/skip2/go-qrcode"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestParseRecoveryLevel(t *testing.T) {
+	tests := []struct {
+		in   string
+		want qrcode.RecoveryLevel
+	}{
+		{"", qrcode.Medium},
+		{"l", qrcode.Low},
+		{"M", qrcode.Medium},
+		{"Q", qrcode.High},
+		{"H", qrcode.Highest},
+	}
+	for _, tt := range tests {
+		got, err := parseRecoveryLevel(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseRecoveryLevel(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+
+	if _, err := parseRecoveryLevel("X"); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного уровня")
+	}
+}
+
+func TestRenderQRSVG(t *testing.T) {
+	q, err := qrcode.New("http://localhost:8080/paste/123", qrcode.Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bitmap := q.Bitmap()
+	svg := string(renderQRSVG(bitmap))
+
+	if !strings.HasPrefix(svg, "<svg") || !strings.HasSuffix(svg, "</svg>") {
+		t.Fatalf("Некорректный SVG: %s", svg[:40])
+	}
+
+	dark := 0
+	for _, row := range bitmap {
+		for _, v := range row {
+			if v {
+				dark++
+			}
+		}
+	}
+	if got := strings.Count(svg, "h1v1h-1z"); got != dark {
+		t.Errorf("Ожидалось %d модулей, получено %d", dark, got)
+	}
+}
+
+// Запрос QR-кода пасты, которую подставная база вернёт на FindOne
+func requestPasteQR(mt *mtest.T, paste models.Paste, query string, responses ...bson.D) *httptest.ResponseRecorder {
+	useTestDB(mt)
+	if pasteLogger == nil {
+		pasteLogger = log.New(io.Discard, "", 0)
+		mt.Cleanup(func() { pasteLogger = nil })
+	}
+	doc, err := bson.Marshal(paste)
+	if err != nil {
+		mt.Fatal(err)
+	}
+	var raw bson.D
+	if err := bson.Unmarshal(doc, &raw); err != nil {
+		mt.Fatal(err)
+	}
+	mt.AddMockResponses(append([]bson.D{mtest.CreateCursorResponse(0, "pastebin.pastes", mtest.FirstBatch, raw)}, responses...)...)
+
+	r := httptest.NewRequest("GET", "/paste/"+paste.ID.Hex()+"/qr.svg?"+query, nil)
+	r = mux.SetURLVars(r, map[string]string{"id": paste.ID.Hex()})
+	w := httptest.NewRecorder()
+	PasteQRSVGHandler(w, r)
+	return w
+}
+
+func TestPasteQRAccess(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("истёкшая паста", func(mt *mtest.T) {
+		paste := models.Paste{ID: primitive.NewObjectID(), Content: "old", ExpiresAt: time.Now().Add(-time.Minute)}
+		// Истёкшая паста удаляется при обращении
+		deleted := mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil})
+		if w := requestPasteQR(mt, paste, "", deleted); w.Code != http.StatusNotFound {
+			mt.Errorf("истёкшая паста: %d", w.Code)
+		}
+	})
+
+	mt.Run("сгорающая паста", func(mt *mtest.T) {
+		paste := models.Paste{ID: primitive.NewObjectID(), Content: "secret", DeleteAfter: 1}
+		if w := requestPasteQR(mt, paste, "mode=content"); w.Code != http.StatusForbidden {
+			mt.Errorf("содержимое сгорающей пасты: %d", w.Code)
+		}
+		if w := requestPasteQR(mt, paste, ""); w.Code != http.StatusOK {
+			mt.Errorf("ссылка на сгорающую пасту: %d", w.Code)
+		}
+	})
+
+	mt.Run("паста с паролем", func(mt *mtest.T) {
+		paste := models.Paste{ID: primitive.NewObjectID(), Content: "secret", Password: "hash"}
+		if w := requestPasteQR(mt, paste, "mode=content"); w.Code != http.StatusForbidden {
+			mt.Errorf("содержимое пасты с паролем: %d", w.Code)
+		}
+		if w := requestPasteQR(mt, paste, ""); w.Code != http.StatusOK {
+			mt.Errorf("ссылка на пасту с паролем: %d", w.Code)
+		}
+	})
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
@@ -29,6 +30,72 @@ func ConnectToDB() error {
 	// Устанавливаем базу данных
 	db = client.Database("pastebin")
 	log.Println("Успешное подключение к MongoDB")
+
+	if err := ensureIndexes(context.TODO()); err != nil {
+		log.Printf("Ошибка создания индексов: %v", err)
+	}
+	return nil
+}
+
+// Индексы, на которые опираются уникальность и выборки
+func ensureIndexes(ctx context.Context) error {
+	indexes := map[string][]mongo.IndexModel{
+		"stars": {
+			{Keys: bson.D{{Key: "paste_id", Value: 1}, {Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "createdAt", Value: 1}}},
+		},
+		"paste_views": {
+			{Keys: bson.D{{Key: "paste_id", Value: 1}, {Key: "visitor", Value: 1}, {Key: "day", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "viewedAt", Value: 1}}},
+		},
+		"paste_stats_daily": {
+			{Keys: bson.D{{Key: "paste_id", Value: 1}, {Key: "day", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "day", Value: 1}}},
+		},
+		"paste_referrers": {
+			{Keys: bson.D{{Key: "paste_id", Value: 1}, {Key: "day", Value: 1}, {Key: "host", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		"pastes": {
+			{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetSparse(true)},
+			{Keys: bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}},
+			{Keys: bson.D{{Key: "shares.user_id", Value: 1}}, Options: options.Index().SetSparse(true)},
+		},
+		"comments": {
+			{Keys: bson.D{{Key: "paste_id", Value: 1}, {Key: "createdAt", Value: 1}}},
+		},
+		"proposals": {
+			{Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "status", Value: 1}, {Key: "createdAt", Value: -1}}},
+			{Keys: bson.D{{Key: "paste_id", Value: 1}, {Key: "status", Value: 1}}},
+		},
+		"api_tokens": {
+			{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "createdAt", Value: -1}}},
+		},
+		"ssh_keys": {
+			{Keys: bson.D{{Key: "fingerprint", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		},
+		"webhooks": {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "events", Value: 1}}},
+			{Keys: bson.D{{Key: "global", Value: 1}, {Key: "events", Value: 1}}},
+		},
+		"idempotency_keys": {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		"webhook_deliveries": {
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}}},
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "lockedUntil", Value: 1}}, Options: options.Index().SetSparse(true)},
+			{Keys: bson.D{{Key: "webhook_id", Value: 1}, {Key: "createdAt", Value: -1}}},
+			// Законченные доставки удаляются сами; у ожидающих finishedAt нет
+			{Keys: bson.D{{Key: "finishedAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(webhookHistoryTTL.Seconds()))},
+		},
+	}
+	for name, indexModels := range indexes {
+		if _, err := db.Collection(name).Indexes().CreateMany(ctx, indexModels); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
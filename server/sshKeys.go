@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"pastebin/models"
+	"pastebin/utils"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/ssh"
+)
+
+// Владелец ключа по отпечатку; NilObjectID, если ключ не зарегистрирован
+func sshKeyOwner(ctx context.Context, fingerprint string) (primitive.ObjectID, error) {
+	var key models.SSHKey
+	err := GetCollection("ssh_keys").FindOne(ctx, bson.M{"fingerprint": fingerprint}).Decode(&key)
+	if err == mongo.ErrNoDocuments {
+		return primitive.NilObjectID, nil
+	} else if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return key.UserID, nil
+}
+
+// Отмечаем вход ключом. Вызывается только после проверки подписи: клиент
+// может спросить про любой публичный ключ, не владея им
+func touchSSHKey(ctx context.Context, fingerprint string) error {
+	_, err := GetCollection("ssh_keys").UpdateOne(ctx,
+		bson.M{"fingerprint": fingerprint},
+		bson.M{"$set": bson.M{"lastUsedAt": time.Now()}},
+	)
+	return err
+}
+
+// Ключи пользователя для страницы профиля
+func listSSHKeys(ctx context.Context, userID primitive.ObjectID) ([]models.SSHKey, error) {
+	cursor, err := GetCollection("ssh_keys").Find(ctx,
+		bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var keys []models.SSHKey
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// POST /profile/ssh-keys
+func AddSSHKeyHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetUserIDFromToken(r)
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Принимаем строку из id_ed25519.pub / id_rsa.pub как есть
+	publicKey, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(strings.TrimSpace(r.FormValue("key"))))
+	if err != nil {
+		setFlash(w, "Invalid public key")
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+		return
+	}
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		name = comment
+	}
+	if name == "" {
+		name = publicKey.Type()
+	}
+
+	key := models.SSHKey{
+		ID:          primitive.NewObjectID(),
+		UserID:      userID,
+		Name:        name,
+		Fingerprint: ssh.FingerprintSHA256(publicKey),
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))),
+		CreatedAt:   time.Now(),
+	}
+	_, err = GetCollection("ssh_keys").InsertOne(r.Context(), key)
+	if mongo.IsDuplicateKeyError(err) {
+		setFlash(w, "This key is already registered")
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+		return
+	} else if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to save SSH key")
+		return
+	}
+
+	log.Printf("SSH-ключ %s добавлен пользователем %s", key.Fingerprint, userID.Hex())
+	setFlash(w, "SSH key added")
+	http.Redirect(w, r, "/profile", http.StatusSeeOther)
+}
+
+// POST /profile/ssh-keys/{id}/delete
+func DeleteSSHKeyHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetUserIDFromToken(r)
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	keyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid key ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := GetCollection("ssh_keys").DeleteOne(r.Context(), bson.M{"_id": keyID, "user_id": userID})
+	if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to delete SSH key")
+		return
+	}
+	if result.DeletedCount == 0 {
+		http.Error(w, "Key not found or access denied", http.StatusForbidden)
+		return
+	}
+
+	setFlash(w, "SSH key removed")
+	http.Redirect(w, r, "/profile", http.StatusSeeOther)
+}
@@ -0,0 +1,345 @@
+package server
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"pastebin/models"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	sshSessionTimeout = 60 * time.Second // Общий лимит времени на одно SSH-соединение
+	sshMaxConns       = 64
+)
+
+const sshUsage = `Usage:
+  ssh host < file                create a paste from stdin
+  ssh host create [flags] < file flags: -title, -lang, -expires, -visibility, -password, -burn N
+  ssh host get <id>              print a paste
+Register your public key on the profile page to own the pastes you create.
+`
+
+// Приём и выдача паст по SSH: ssh host < file, ssh host get <id>
+type SSHServer struct {
+	listener  net.Listener
+	config    *ssh.ServerConfig
+	allow     func(ip string) bool // Общий с HTTP лимит запросов
+	lookupKey func(ctx context.Context, fingerprint string) (primitive.ObjectID, error)
+	touchKey  func(ctx context.Context, fingerprint string) error // Вход ключом состоялся
+	save      func(ctx context.Context, paste models.Paste) error
+	fetch     func(ctx context.Context, id, viewerID primitive.ObjectID) (models.Paste, error)
+	baseURL   string
+	maxBytes  int64
+	slots     chan struct{}
+	conns     connGroup
+}
+
+// Слушаем addr; ключ хоста берём из hostKeyPath или создаём при первом запуске
+func StartSSHServer(addr, hostKeyPath string, allow func(ip string) bool) (*SSHServer, error) {
+	hostKey, err := loadOrCreateHostKey(hostKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("host key: %w", err)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newSSHServer(listener, hostKey, allow, sshKeyOwner, touchSSHKey, insertPaste, fetchPaste, listenerBaseURL("ssh"))
+	log.Printf("SSH-приём паст на %s, ключ хоста %s", listener.Addr(), ssh.FingerprintSHA256(hostKey.PublicKey()))
+	go s.serve()
+	return s, nil
+}
+
+// Паста для "ssh host get" с теми же проверками, что и на сайте
+func fetchPaste(ctx context.Context, id, viewerID primitive.ObjectID) (models.Paste, error) {
+	cached, err := readPaste(ctx, id, viewerID, "")
+	return cached.Paste, err
+}
+
+func loadOrCreateHostKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return ssh.ParsePrivateKey(data)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ssh.MarshalPrivateKey(private, "pastebin host key")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		return nil, err
+	}
+	log.Printf("Создан новый ключ SSH-хоста: %s", path)
+	return ssh.NewSignerFromKey(private)
+}
+
+func newSSHServer(
+	listener net.Listener,
+	hostKey ssh.Signer,
+	allow func(string) bool,
+	lookupKey func(context.Context, string) (primitive.ObjectID, error),
+	touchKey func(context.Context, string) error,
+	save func(context.Context, models.Paste) error,
+	fetch func(context.Context, primitive.ObjectID, primitive.ObjectID) (models.Paste, error),
+	baseURL string,
+) *SSHServer {
+	s := &SSHServer{
+		listener:  listener,
+		allow:     allow,
+		lookupKey: lookupKey,
+		touchKey:  touchKey,
+		save:      save,
+		fetch:     fetch,
+		baseURL:   baseURL,
+		maxBytes:  rawUploadMaxBytes,
+		slots:     make(chan struct{}, sshMaxConns),
+	}
+	s.config = &ssh.ServerConfig{
+		// Зарегистрированный ключ — вход от имени владельца. Незнакомый
+		// отклоняем, чтобы клиент попробовал следующие ключи. Вызывается и
+		// для запроса без подписи, поэтому вход отмечается уже после рукопожатия
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			fingerprint := ssh.FingerprintSHA256(key)
+			userID, err := s.lookupKey(ctx, fingerprint)
+			if err != nil {
+				log.Printf("Ошибка поиска SSH-ключа: %v", err)
+				return nil, err
+			}
+			if userID.IsZero() {
+				return nil, errors.New("unknown key")
+			}
+			return &ssh.Permissions{Extensions: map[string]string{"user_id": userID.Hex(), "fingerprint": fingerprint}}, nil
+		},
+		// Если ни один ключ не подошёл — анонимный вход без вопросов
+		KeyboardInteractiveCallback: func(meta ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			return &ssh.Permissions{}, nil
+		},
+		ServerVersion: "SSH-2.0-pastebin",
+	}
+	s.config.AddHostKey(hostKey)
+	return s
+}
+
+func (s *SSHServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+func (s *SSHServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			log.Printf("Ошибка приёма SSH-соединения: %v", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		// Слишком много одновременных соединений — закрываем до рукопожатия
+		select {
+		case s.slots <- struct{}{}:
+		default:
+			conn.Close()
+			continue
+		}
+
+		if !s.conns.add(conn) {
+			<-s.slots
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer s.conns.done(conn)
+			defer func() { <-s.slots }()
+			defer conn.Close()
+			s.handleConn(conn)
+		}()
+	}
+}
+
+func (s *SSHServer) handleConn(conn net.Conn) {
+	ip, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	allowed := err == nil && (s.allow == nil || s.allow(ip))
+
+	conn.SetDeadline(time.Now().Add(sshSessionTimeout))
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	defer serverConn.Close()
+	go ssh.DiscardRequests(requests)
+
+	userID, _ := primitive.ObjectIDFromHex(serverConn.Permissions.Extensions["user_id"])
+	if fingerprint := serverConn.Permissions.Extensions["fingerprint"]; fingerprint != "" && s.touchKey != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := s.touchKey(ctx, fingerprint); err != nil {
+			log.Printf("Ошибка отметки входа SSH-ключом: %v", err)
+		}
+		cancel()
+	}
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		if !allowed {
+			newChannel.Reject(ssh.ResourceShortage, "Too many requests")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		s.handleSession(channel, channelRequests, userID)
+	}
+}
+
+func (s *SSHServer) handleSession(channel ssh.Channel, requests <-chan *ssh.Request, userID primitive.ObjectID) {
+	defer channel.Close()
+	for req := range requests {
+		switch req.Type {
+		case "exec", "shell":
+			var payload struct{ Command string }
+			if req.Type == "exec" {
+				if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+					req.Reply(false, nil)
+					continue
+				}
+			}
+			req.Reply(true, nil)
+			status := s.run(channel, userID, payload.Command)
+			channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+			return
+		default:
+			// pty не выдаём: ввод читается до EOF (Ctrl-D)
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+// Выполняем команду сессии и возвращаем код выхода
+func (s *SSHServer) run(channel ssh.Channel, userID primitive.ObjectID, command string) uint32 {
+	args := strings.Fields(command)
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		args = append([]string{"create"}, args...)
+	}
+	stderr := channel.Stderr()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("create", flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		var in pasteInput
+		fs.StringVar(&in.Title, "title", "", "paste title")
+		fs.StringVar(&in.Language, "lang", "", "language for highlighting")
+		fs.StringVar(&in.Expires, "expires", "", "1hour, 1day, 1week, 1month, 6months, 1year or never")
+		fs.StringVar(&in.Visibility, "visibility", "", "public, unlisted or private")
+		fs.StringVar(&in.Password, "password", "", "protect the paste with a password")
+		fs.IntVar(&in.DeleteAfter, "burn", 0, "delete the paste after N reads")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+
+		content, err := io.ReadAll(io.LimitReader(channel, s.maxBytes+1))
+		if err != nil {
+			fmt.Fprintln(stderr, "Failed to read input")
+			return 1
+		}
+		if int64(len(content)) > s.maxBytes {
+			fmt.Fprintln(stderr, "Paste is too large")
+			return 1
+		}
+		if len(content) == 0 {
+			fmt.Fprintln(stderr, "Nothing to paste")
+			return 1
+		}
+		in.Content = string(content)
+
+		paste, err := newPaste(userID, in, time.Now())
+		if err != nil {
+			fmt.Fprintln(stderr, err.Error())
+			return 1
+		}
+		if err := s.save(ctx, paste); err != nil {
+			log.Printf("Ошибка сохранения SSH-пасты: %v", err)
+			fmt.Fprintln(stderr, "Failed to save paste")
+			return 1
+		}
+		fmt.Fprintln(channel, pasteLink(s.baseURL, paste.ID))
+		return 0
+
+	case "get":
+		if len(args) != 2 {
+			fmt.Fprint(stderr, sshUsage)
+			return 2
+		}
+		id, err := primitive.ObjectIDFromHex(args[1][strings.LastIndex(args[1], "/")+1:])
+		if err != nil {
+			fmt.Fprintln(stderr, "Invalid paste ID")
+			return 1
+		}
+		paste, err := s.fetch(ctx, id, userID)
+		switch {
+		case err == errPasteNotFound:
+			fmt.Fprintln(stderr, "Paste not found")
+			return 1
+		case err == errPasteLocked:
+			fmt.Fprintln(stderr, "Paste is password protected; open it in the browser")
+			return 1
+		case err != nil:
+			log.Printf("Ошибка загрузки пасты по SSH: %v", err)
+			fmt.Fprintln(stderr, "Failed to load paste")
+			return 1
+		}
+		io.WriteString(channel, paste.Content)
+		if !strings.HasSuffix(paste.Content, "\n") {
+			io.WriteString(channel, "\n")
+		}
+		return 0
+
+	case "help":
+		fmt.Fprint(channel, sshUsage)
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "Unknown command %q\n%s", args[0], sshUsage)
+	return 2
+}
+
+// Перестаём принимать соединения и ждём начатые; по истечении ctx
+// закрываем оставшиеся принудительно
+func (s *SSHServer) Shutdown(ctx context.Context) error {
+	err := s.listener.Close()
+	if waitErr := s.conns.wait(ctx); waitErr != nil {
+		return waitErr
+	}
+	return err
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/ssh"
+	"pastebin/models"
+)
+
+func newTestSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// Отпечатки ключей, которыми выполнен вход
+type touchedKeys struct {
+	mu           sync.Mutex
+	fingerprints []string
+}
+
+func (k *touchedKeys) touch(ctx context.Context, fingerprint string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.fingerprints = append(k.fingerprints, fingerprint)
+	return nil
+}
+
+func (k *touchedKeys) list() []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return append([]string(nil), k.fingerprints...)
+}
+
+// Подписывает чужим ключом: сервер видит публичный ключ владельца,
+// но проверку подписи клиент не проходит
+type forgedSigner struct {
+	public ssh.PublicKey
+	ssh.Signer
+}
+
+func (f forgedSigner) PublicKey() ssh.PublicKey { return f.public }
+
+// SSH-сервер, в котором известен только ключ owner
+func startTestSSH(t *testing.T, owner ssh.PublicKey, ownerID primitive.ObjectID) (*SSHServer, *savedPastes, *touchedKeys) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &savedPastes{}
+	lookup := func(ctx context.Context, fingerprint string) (primitive.ObjectID, error) {
+		if fingerprint == ssh.FingerprintSHA256(owner) {
+			return ownerID, nil
+		}
+		return primitive.NilObjectID, nil
+	}
+	fetch := func(ctx context.Context, id, viewerID primitive.ObjectID) (models.Paste, error) {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		for _, p := range store.pastes {
+			if p.ID == id {
+				return p, nil
+			}
+		}
+		return models.Paste{}, errPasteNotFound
+	}
+	touched := &touchedKeys{}
+	s := newSSHServer(listener, newTestSigner(t), nil, lookup, touched.touch, store.save, fetch, "http://paste.test")
+	go s.serve()
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	return s, store, touched
+}
+
+// Выполняем команду как "ssh host command < stdin"
+func sshRun(t *testing.T, addr net.Addr, key ssh.Signer, command, stdin string) (string, string, error) {
+	t.Helper()
+	client, err := ssh.Dial("tcp", addr.String(), &ssh.ClientConfig{
+		User: "paste",
+		Auth: []ssh.AuthMethod{
+			ssh.PublicKeys(key),
+			ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+				return nil, nil
+			}),
+		},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	var stdout, stderr bytes.Buffer
+	session.Stdin = strings.NewReader(stdin)
+	session.Stdout, session.Stderr = &stdout, &stderr
+	if command == "" {
+		if err := session.Shell(); err != nil {
+			t.Fatal(err)
+		}
+		err = session.Wait()
+	} else {
+		err = session.Run(command)
+	}
+	return stdout.String(), stderr.String(), err
+}
+
+func TestSSHCreateAndGet(t *testing.T) {
+	ownerKey := newTestSigner(t)
+	ownerID := primitive.NewObjectID()
+	s, store, touched := startTestSSH(t, ownerKey.PublicKey(), ownerID)
+
+	// Зарегистрированный ключ — паста принадлежит владельцу
+	out, errOut, err := sshRun(t, s.Addr(), ownerKey, "", "hello over ssh\n")
+	if err != nil || !strings.HasPrefix(out, "http://paste.test/paste/") {
+		t.Fatalf("Ожидалась ссылка, получено %q %q %v", out, errOut, err)
+	}
+	// Незнакомый ключ — анонимно, с флагами
+	out, errOut, err = sshRun(t, s.Addr(), newTestSigner(t), "create -title notes -burn 2", "anon")
+	if err != nil || !strings.HasPrefix(out, "http://paste.test/paste/") {
+		t.Fatalf("Ожидалась ссылка для анонима, получено %q %q %v", out, errOut, err)
+	}
+
+	store.mu.Lock()
+	pastes := append([]models.Paste(nil), store.pastes...)
+	store.mu.Unlock()
+	if len(pastes) != 2 {
+		t.Fatalf("Ожидалось 2 пасты, сохранено %d", len(pastes))
+	}
+	if pastes[0].UserID != ownerID || pastes[0].Content != "hello over ssh\n" {
+		t.Errorf("Паста владельца сохранена неверно: %+v", pastes[0])
+	}
+	if !pastes[1].UserID.IsZero() || pastes[1].Title != "notes" || pastes[1].DeleteAfter != 2 {
+		t.Errorf("Анонимная паста сохранена неверно: %+v", pastes[1])
+	}
+
+	out, _, err = sshRun(t, s.Addr(), ownerKey, "get "+pastes[1].ID.Hex(), "")
+	if err != nil || out != "anon\n" {
+		t.Errorf("get: получено %q, %v", out, err)
+	}
+	_, errOut, err = sshRun(t, s.Addr(), ownerKey, "get "+primitive.NewObjectID().Hex(), "")
+	if err == nil || !strings.Contains(errOut, "Paste not found") {
+		t.Errorf("Ожидалась ошибка для несуществующей пасты, получено %q, %v", errOut, err)
+	}
+
+	// Вход отмечен трижды: создание и два get, анонимный вход не в счёт
+	if got := touched.list(); len(got) != 3 || got[0] != ssh.FingerprintSHA256(ownerKey.PublicKey()) {
+		t.Errorf("Отмеченные входы: %v", got)
+	}
+}
+
+func TestSSHKeyNotTouchedWithoutSignature(t *testing.T) {
+	ownerKey := newTestSigner(t)
+	s, _, touched := startTestSSH(t, ownerKey.PublicKey(), primitive.NewObjectID())
+
+	// Чужой публичный ключ без закрытого: сервер отвечает на запрос о ключе,
+	// но подпись не сходится и вход не состоится
+	forged := forgedSigner{public: ownerKey.PublicKey(), Signer: newTestSigner(t)}
+	client, err := ssh.Dial("tcp", s.Addr().String(), &ssh.ClientConfig{
+		User:            "paste",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(forged)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err == nil {
+		client.Close()
+		t.Fatal("Вход по чужому публичному ключу без подписи")
+	}
+	if got := touched.list(); len(got) != 0 {
+		t.Errorf("Вход ключом отмечен без подписи: %v", got)
+	}
+}
+
+func TestSSHRejectsWhenBusy(t *testing.T) {
+	s, _, _ := startTestSSH(t, newTestSigner(t).PublicKey(), primitive.NewObjectID())
+	for i := 0; i < cap(s.slots); i++ {
+		s.slots <- struct{}{}
+	}
+	_, err := ssh.Dial("tcp", s.Addr().String(), &ssh.ClientConfig{
+		User:            "paste",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	if err == nil {
+		t.Error("Соединение сверх лимита принято")
+	}
+}
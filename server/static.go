@@ -0,0 +1,193 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Каталог статических файлов внутри web/
+const staticDir = "css"
+
+// Статический файл в памяти вместе со сжатыми вариантами
+type staticAsset struct {
+	data        []byte
+	gzip        []byte
+	brotli      []byte
+	contentType string
+	etag        string
+	fingerprint bool // Имя содержит хеш содержимого, можно кэшировать навсегда
+}
+
+var (
+	staticMu     sync.RWMutex
+	staticAssets map[string]*staticAsset // Путь под /static/ -> файл
+	assetURLs    map[string]string       // Логическое имя -> URL с хешем
+)
+
+// Имя файла с хешем содержимого: css/chat.css -> css/chat.1a2b3c4d.css
+func fingerprintName(name string, data []byte) string {
+	sum := sha256.Sum256(data)
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(sum[:4]) + ext
+}
+
+// Читаем статику, считаем хеши и заранее сжимаем gzip и brotli
+func loadStaticAssets(fsys fs.FS) error {
+	assets := make(map[string]*staticAsset)
+	urls := make(map[string]string)
+
+	err := fs.WalkDir(fsys, staticDir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(data)
+		asset := &staticAsset{
+			data:        data,
+			contentType: mime.TypeByExtension(path.Ext(name)),
+			etag:        `"` + hex.EncodeToString(sum[:8]) + `"`,
+		}
+		if asset.contentType == "" {
+			asset.contentType = "application/octet-stream"
+		}
+		if asset.gzip, err = compressGzip(data); err != nil {
+			return err
+		}
+		if asset.brotli, err = compressBrotli(data); err != nil {
+			return err
+		}
+
+		fingerprinted := fingerprintName(name, data)
+		assets[name] = asset
+		assets[fingerprinted] = &staticAsset{
+			data:        asset.data,
+			gzip:        asset.gzip,
+			brotli:      asset.brotli,
+			contentType: asset.contentType,
+			etag:        asset.etag,
+			fingerprint: true,
+		}
+		urls[name] = "/static/" + fingerprinted
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	staticMu.Lock()
+	staticAssets = assets
+	assetURLs = urls
+	staticMu.Unlock()
+	return nil
+}
+
+func compressGzip(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func compressBrotli(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	bw := brotli.NewWriterLevel(&buf, brotli.BestCompression)
+	if _, err := bw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := bw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Функция шаблонов: {{asset "css/chat.css"}} -> /static/css/chat.1a2b3c4d.css
+func assetURL(name string) (string, error) {
+	staticMu.RLock()
+	defer staticMu.RUnlock()
+	url, ok := assetURLs[name]
+	if !ok {
+		return "", fmt.Errorf("unknown asset %q", name)
+	}
+	return url, nil
+}
+
+// Клиент принимает кодировку (без разбора q-значений, q=0 считаем отказом)
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		if strings.EqualFold(fields[0], encoding) {
+			return len(fields) < 2 || strings.TrimSpace(fields[1]) != "q=0"
+		}
+	}
+	return false
+}
+
+// Раздача статики из /static/
+func StaticHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/static/")
+
+	staticMu.RLock()
+	asset, ok := staticAssets[name]
+	staticMu.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Сжатые варианты — разные представления, у каждого свой ETag
+	body, encoding, etag := asset.data, "", asset.etag
+	switch {
+	case acceptsEncoding(r, "br") && len(asset.brotli) < len(body):
+		body, encoding, etag = asset.brotli, "br", strings.TrimSuffix(asset.etag, `"`)+`-br"`
+	case acceptsEncoding(r, "gzip") && len(asset.gzip) < len(body):
+		body, encoding, etag = asset.gzip, "gzip", strings.TrimSuffix(asset.etag, `"`)+`-gz"`
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", asset.contentType)
+	header.Set("ETag", etag)
+	header.Set("Vary", "Accept-Encoding")
+	if asset.fingerprint {
+		header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		header.Set("Cache-Control", "no-cache")
+	}
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if encoding != "" {
+		header.Set("Content-Encoding", encoding)
+	}
+	header.Set("Content-Length", fmt.Sprint(len(body)))
+
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(body)
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"pastebin/web"
+	"strings"
+	"testing"
+)
+
+func TestAssetURLFingerprint(t *testing.T) {
+	if err := loadStaticAssets(web.FS); err != nil {
+		t.Fatal(err)
+	}
+
+	url, err := assetURL("css/chat.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(url, "/static/css/chat.") || !strings.HasSuffix(url, ".css") || url == "/static/css/chat.css" {
+		t.Errorf("Неожиданный URL: %s", url)
+	}
+
+	if _, err := assetURL("css/missing.css"); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного файла")
+	}
+}
+
+func TestStaticHandlerEncodingAndCaching(t *testing.T) {
+	if err := loadStaticAssets(web.FS); err != nil {
+		t.Fatal(err)
+	}
+	url, _ := assetURL("css/admin.css")
+
+	tests := []struct {
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, br", "br"},
+		{"br;q=0, gzip", "gzip"},
+	}
+	etags := map[string]string{}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		rec := httptest.NewRecorder()
+		StaticHandler(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Ожидался статус 200, получен %d", rec.Code)
+		}
+		if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+			t.Errorf("Accept-Encoding %q: ожидалось %q, получено %q", tt.acceptEncoding, tt.wantEncoding, got)
+		}
+		// У каждого представления свой ETag
+		etag := rec.Header().Get("ETag")
+		if other, ok := etags[etag]; ok && other != tt.wantEncoding {
+			t.Errorf("ETag %s у %q и %q", etag, other, tt.wantEncoding)
+		}
+		etags[etag] = tt.wantEncoding
+		if !strings.Contains(rec.Header().Get("Cache-Control"), "immutable") {
+			t.Errorf("Нет долгого кэширования: %s", rec.Header().Get("Cache-Control"))
+		}
+	}
+
+	// Повторный запрос с ETag
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	rec := httptest.NewRecorder()
+	StaticHandler(rec, req)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	StaticHandler(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("Ожидался статус 304, получен %d", rec.Code)
+	}
+	// ETag несжатого файла не подходит к сжатому
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	StaticHandler(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("Ожидался сжатый ответ, получен %d %q", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+
+	// Имя без хеша отдаётся, но без долгого кэширования
+	rec = httptest.NewRecorder()
+	StaticHandler(rec, httptest.NewRequest(http.MethodGet, "/static/css/admin.css", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("Неожиданный ответ для имени без хеша: %d %s", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+}
+
+// Стили страниц подключаются файлами из web/css через asset
+func TestTemplatesHaveNoInlineStyles(t *testing.T) {
+	pages, err := fs.Glob(web.FS, "*.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, page := range pages {
+		data, err := fs.ReadFile(web.FS, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "<style") {
+			t.Errorf("%s: встроенный <style>", page)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"pastebin/models"
+	"pastebin/utils"
+	"pastebin/web"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Общий макет, в который вставляется каждая страница
+const layoutTemplate = "layout.html"
+
+// Шаблоны страниц разбираются один раз при старте.
+// В режиме разработки (DEV_TEMPLATES=1) они читаются с диска
+// и перечитываются, когда файлы меняются
+var (
+	templatesMu      sync.RWMutex
+	templates        map[string]*template.Template
+	templatesFS      fs.FS = web.FS
+	devTemplates     bool
+	templatesModTime time.Time
+)
+
+// Функции, доступные во всех шаблонах
+var templateFuncs = template.FuncMap{
+	"asset": assetURL,
+}
+
+// Данные, которые получает макет; страница видит только Data
+type PageData struct {
+	User    *models.User
+	Flashes []string
+	Data    interface{}
+}
+
+func InitTemplates() error {
+	if os.Getenv("DEV_TEMPLATES") == "1" {
+		devTemplates = true
+		templatesFS = os.DirFS(templatesDir)
+		log.Println("Templates are reloaded from disk on change")
+	}
+	return loadTemplates()
+}
+
+func loadTemplates() error {
+	// Статика нужна шаблонам для функции asset
+	if err := loadStaticAssets(templatesFS); err != nil {
+		return err
+	}
+
+	pages, err := fs.Glob(templatesFS, "*.html")
+	if err != nil {
+		return err
+	}
+
+	set := make(map[string]*template.Template, len(pages))
+	for _, page := range pages {
+		if page == layoutTemplate {
+			continue
+		}
+		tmpl, err := template.New(page).Funcs(templateFuncs).ParseFS(templatesFS, layoutTemplate, page)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", page, err)
+		}
+		set[page] = tmpl
+	}
+
+	modTime, err := latestModTime()
+	if err != nil {
+		return err
+	}
+
+	templatesMu.Lock()
+	templates = set
+	templatesModTime = modTime
+	templatesMu.Unlock()
+	return nil
+}
+
+// Время последнего изменения среди файлов шаблонов и статики
+func latestModTime() (time.Time, error) {
+	var latest time.Time
+	files, err := fs.Glob(templatesFS, "*.html")
+	if err != nil {
+		return latest, err
+	}
+	static, err := fs.Glob(templatesFS, staticDir+"/*")
+	if err != nil {
+		return latest, err
+	}
+	files = append(files, static...)
+	for _, name := range files {
+		info, err := fs.Stat(templatesFS, name)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func reloadTemplatesIfChanged() {
+	modTime, err := latestModTime()
+	if err != nil {
+		log.Printf("Ошибка проверки шаблонов: %v", err)
+		return
+	}
+	templatesMu.RLock()
+	changed := modTime.After(templatesModTime)
+	templatesMu.RUnlock()
+	if !changed {
+		return
+	}
+	if err := loadTemplates(); err != nil {
+		log.Printf("Ошибка перезагрузки шаблонов: %v", err)
+		return
+	}
+	log.Println("Templates reloaded")
+}
+
+// Рендерим страницу в общем макете. Ошибка шаблона даёт 500,
+// а не панику и не обрезанную страницу
+func render(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+	body, ok := renderPage(w, r, name, data)
+	if !ok {
+		return
+	}
+	w.Write(body)
+}
+
+// Страница целиком в памяти, чтобы обработчик мог посчитать ETag.
+// При ошибке ответ 500 уже отправлен
+func renderPage(w http.ResponseWriter, r *http.Request, name string, data interface{}) ([]byte, bool) {
+	if devTemplates {
+		reloadTemplatesIfChanged()
+	}
+
+	templatesMu.RLock()
+	tmpl, ok := templates[name]
+	templatesMu.RUnlock()
+	if !ok {
+		log.Printf("Template not found: %s", name)
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	page := PageData{
+		User:    currentUser(r),
+		Flashes: popFlashes(w, r),
+		Data:    data,
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "layout", page); err != nil {
+		log.Printf("Error rendering template %s: %v", name, err)
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		return nil, false
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return buf.Bytes(), true
+}
+
+// Текущий пользователь по токену из куки; nil для гостей
+func currentUser(r *http.Request) *models.User {
+	userID, err := utils.GetUserIDFromToken(r)
+	if err != nil || db == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var user models.User
+	if err := db.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return nil
+	}
+	return &user
+}
+
+// Сообщение, которое покажется на следующей странице
+func setFlash(w http.ResponseWriter, message string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "flash",
+		Value:    url.QueryEscape(message),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Забираем сообщение и удаляем куку
+func popFlashes(w http.ResponseWriter, r *http.Request) []string {
+	cookie, err := r.Cookie("flash")
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:    "flash",
+		Value:   "",
+		Path:    "/",
+		Expires: time.Unix(0, 0),
+	})
+	message, err := url.QueryUnescape(cookie.Value)
+	if err != nil {
+		return nil
+	}
+	return []string{message}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTemplatesParse(t *testing.T) {
+	if err := loadTemplates(); err != nil {
+		t.Fatalf("Шаблоны не разобрались: %v", err)
+	}
+	for _, page := range []string{"home.html", "readpaste.html", "profile.html", "login.html", "signup.html", "editpaste.html"} {
+		if _, ok := templates[page]; !ok {
+			t.Errorf("Шаблон %s не загружен", page)
+		}
+	}
+	if _, ok := templates[layoutTemplate]; ok {
+		t.Error("Макет не должен быть отдельной страницей")
+	}
+}
+
+func TestRenderUsesLayoutAndFlash(t *testing.T) {
+	if err := loadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/login", nil)
+	req.AddCookie(&http.Cookie{Name: "flash", Value: "Paste+created"})
+	rec := httptest.NewRecorder()
+	render(rec, req, "login.html", nil)
+
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Ожидался статус 200, получен %d", rec.Code)
+	}
+	if !strings.Contains(body, `class="site-nav"`) || !strings.Contains(body, `href="/login">Log in`) {
+		t.Error("Страница отрисована без навигации макета")
+	}
+	if !strings.Contains(body, "Paste created") {
+		t.Error("Flash-сообщение не показано")
+	}
+	if !strings.Contains(rec.Header().Get("Set-Cookie"), "flash=;") {
+		t.Error("Flash-кука не очищена")
+	}
+}
+
+func TestRenderMissingTemplate(t *testing.T) {
+	if err := loadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	render(rec, httptest.NewRequest(http.MethodGet, "/", nil), "missing.html", nil)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Ожидался статус 500, получен %d", rec.Code)
+	}
+}
@@ -0,0 +1,255 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+// Операция над текстом (operational transformation, как в ot.js):
+// последовательность компонентов retain, insert и delete, проходящая
+// документ от начала до конца. Позиции и длины — в кодовых единицах
+// UTF-16, как у строк JavaScript в браузере.
+// В JSON: число > 0 — retain, число < 0 — delete, строка — insert
+type textOp struct {
+	ops       []opComponent
+	baseLen   int // Длина документа, к которому применяется операция
+	targetLen int // Длина документа после неё
+}
+
+type opComponent struct {
+	retain int
+	insert []uint16
+	del    int
+}
+
+var errOpMismatch = errors.New("operation does not match the document")
+
+func (op *textOp) retain(n int) *textOp {
+	if n <= 0 {
+		return op
+	}
+	op.baseLen += n
+	op.targetLen += n
+	if last := len(op.ops) - 1; last >= 0 && op.ops[last].retain > 0 {
+		op.ops[last].retain += n
+	} else {
+		op.ops = append(op.ops, opComponent{retain: n})
+	}
+	return op
+}
+
+// Вставка всегда стоит перед удалением в той же позиции: так у равных
+// операций одно представление
+func (op *textOp) insert(s []uint16) *textOp {
+	if len(s) == 0 {
+		return op
+	}
+	op.targetLen += len(s)
+	last := len(op.ops) - 1
+	switch {
+	case last >= 0 && op.ops[last].insert != nil:
+		op.ops[last].insert = append(append([]uint16{}, op.ops[last].insert...), s...)
+	case last >= 0 && op.ops[last].del > 0:
+		if last > 0 && op.ops[last-1].insert != nil {
+			op.ops[last-1].insert = append(append([]uint16{}, op.ops[last-1].insert...), s...)
+		} else {
+			op.ops = append(op.ops, op.ops[last])
+			op.ops[last] = opComponent{insert: s}
+		}
+	default:
+		op.ops = append(op.ops, opComponent{insert: s})
+	}
+	return op
+}
+
+func (op *textOp) delete(n int) *textOp {
+	if n <= 0 {
+		return op
+	}
+	op.baseLen += n
+	if last := len(op.ops) - 1; last >= 0 && op.ops[last].del > 0 {
+		op.ops[last].del += n
+	} else {
+		op.ops = append(op.ops, opComponent{del: n})
+	}
+	return op
+}
+
+func (op textOp) apply(doc []uint16) ([]uint16, error) {
+	if len(doc) != op.baseLen {
+		return nil, errOpMismatch
+	}
+	out := make([]uint16, 0, op.targetLen)
+	pos := 0
+	for _, c := range op.ops {
+		switch {
+		case c.retain > 0:
+			out = append(out, doc[pos:pos+c.retain]...)
+			pos += c.retain
+		case c.insert != nil:
+			out = append(out, c.insert...)
+		default:
+			pos += c.del
+		}
+	}
+	return out, nil
+}
+
+// Перенос позиции курсора через операцию
+func (op textOp) transformIndex(index int) int {
+	newIndex := index
+	for _, c := range op.ops {
+		switch {
+		case c.retain > 0:
+			index -= c.retain
+		case c.insert != nil:
+			newIndex += len(c.insert)
+		default:
+			newIndex -= min(index, c.del)
+			index -= c.del
+		}
+		if index < 0 {
+			break
+		}
+	}
+	return newIndex
+}
+
+// Для одновременных a и b над одним документом возвращает a' и b', такие
+// что apply(apply(doc, a), b') == apply(apply(doc, b), a'). При вставке в
+// одну позицию текст a оказывается первым
+func transformOps(a, b textOp) (textOp, textOp, error) {
+	var a2, b2 textOp
+	if a.baseLen != b.baseLen {
+		return a2, b2, errOpMismatch
+	}
+	ia, ib := 0, 0
+	var ca, cb opComponent
+	next := func(ops []opComponent, i *int) opComponent {
+		if *i < len(ops) {
+			*i++
+			return ops[*i-1]
+		}
+		return opComponent{}
+	}
+	empty := func(c opComponent) bool { return c.retain == 0 && c.insert == nil && c.del == 0 }
+	ca, cb = next(a.ops, &ia), next(b.ops, &ib)
+
+	for !empty(ca) || !empty(cb) {
+		if ca.insert != nil {
+			a2.insert(ca.insert)
+			b2.retain(len(ca.insert))
+			ca = next(a.ops, &ia)
+			continue
+		}
+		if cb.insert != nil {
+			a2.retain(len(cb.insert))
+			b2.insert(cb.insert)
+			cb = next(b.ops, &ib)
+			continue
+		}
+		if empty(ca) || empty(cb) {
+			return a2, b2, errOpMismatch
+		}
+
+		n := min(ca.retain+ca.del, cb.retain+cb.del)
+		switch {
+		case ca.retain > 0 && cb.retain > 0:
+			a2.retain(n)
+			b2.retain(n)
+		case ca.del > 0 && cb.retain > 0:
+			a2.delete(n)
+		case ca.retain > 0 && cb.del > 0:
+			b2.delete(n)
+		}
+		// Оба удаляют один и тот же текст — в результате ничего
+		ca = consume(ca, n)
+		cb = consume(cb, n)
+		if empty(ca) {
+			ca = next(a.ops, &ia)
+		}
+		if empty(cb) {
+			cb = next(b.ops, &ib)
+		}
+	}
+	return a2, b2, nil
+}
+
+// Остаток retain или delete после n единиц
+func consume(c opComponent, n int) opComponent {
+	if c.retain > 0 {
+		c.retain -= n
+	} else {
+		c.del -= n
+	}
+	return c
+}
+
+// Длина общего начала и общего конца двух текстов, без перекрытия
+func commonEnds(old, new []uint16) (prefix, suffix int) {
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+	for suffix < len(old)-prefix && suffix < len(new)-prefix && old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+	return prefix, suffix
+}
+
+// Операция, переводящая old в new: общее начало и конец сохраняются,
+// середина заменяется
+func diffOp(old, new []uint16) textOp {
+	prefix, suffix := commonEnds(old, new)
+	var op textOp
+	op.retain(prefix)
+	op.insert(new[prefix : len(new)-suffix])
+	op.delete(len(old) - prefix - suffix)
+	op.retain(suffix)
+	return op
+}
+
+func (op textOp) MarshalJSON() ([]byte, error) {
+	out := make([]interface{}, 0, len(op.ops))
+	for _, c := range op.ops {
+		switch {
+		case c.retain > 0:
+			out = append(out, c.retain)
+		case c.insert != nil:
+			out = append(out, string(utf16.Decode(c.insert)))
+		default:
+			out = append(out, -c.del)
+		}
+	}
+	return json.Marshal(out)
+}
+
+func (op *textOp) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*op = textOp{}
+	for _, item := range raw {
+		if bytes.HasPrefix(item, []byte(`"`)) {
+			var s string
+			if err := json.Unmarshal(item, &s); err != nil {
+				return err
+			}
+			op.insert(utf16.Encode([]rune(s)))
+			continue
+		}
+		var n int
+		if err := json.Unmarshal(item, &n); err != nil || n == 0 {
+			return fmt.Errorf("invalid operation component %s", item)
+		}
+		if n > 0 {
+			op.retain(n)
+		} else {
+			op.delete(-n)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+	"unicode/utf16"
+)
+
+func u16(s string) []uint16 { return utf16.Encode([]rune(s)) }
+
+func str16(doc []uint16) string { return string(utf16.Decode(doc)) }
+
+// Случайная операция над документом длины n
+func randomOp(rng *rand.Rand, n int) textOp {
+	var op textOp
+	for pos := 0; pos < n; {
+		k := 1 + rng.Intn(n-pos)
+		switch rng.Intn(3) {
+		case 0:
+			op.retain(k)
+			pos += k
+		case 1:
+			op.delete(k)
+			pos += k
+		default:
+			op.insert(u16([]string{"a", "bc", "😀", "\n"}[rng.Intn(4)]))
+		}
+	}
+	if rng.Intn(2) == 0 {
+		op.insert(u16("z"))
+	}
+	return op
+}
+
+func TestTransformOpsConverges(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		doc := u16("hello 😀 world\nline two")
+		a, b := randomOp(rng, len(doc)), randomOp(rng, len(doc))
+		a2, b2, err := transformOps(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		afterA, _ := a.apply(doc)
+		afterB, _ := b.apply(doc)
+		left, err1 := b2.apply(afterA)
+		right, err2 := a2.apply(afterB)
+		if err1 != nil || err2 != nil || str16(left) != str16(right) {
+			t.Fatalf("расхождение: %q vs %q (%v, %v)", str16(left), str16(right), err1, err2)
+		}
+	}
+}
+
+func TestTransformOpsInsertTieBreak(t *testing.T) {
+	doc := u16("ab")
+	var a, b textOp
+	a.retain(1).insert(u16("X")).retain(1)
+	b.retain(1).insert(u16("Y")).retain(1)
+	a2, b2, _ := transformOps(a, b)
+	afterA, _ := a.apply(doc)
+	got, _ := b2.apply(afterA)
+	afterB, _ := b.apply(doc)
+	got2, _ := a2.apply(afterB)
+	if str16(got) != "aXYb" || str16(got2) != "aXYb" {
+		t.Errorf("got %q and %q", str16(got), str16(got2))
+	}
+}
+
+func TestTextOpJSON(t *testing.T) {
+	var op textOp
+	if err := json.Unmarshal([]byte(`[3, "😀x", -2, 1]`), &op); err != nil {
+		t.Fatal(err)
+	}
+	if op.baseLen != 6 || op.targetLen != 7 {
+		t.Errorf("lengths = %d, %d", op.baseLen, op.targetLen)
+	}
+	doc, err := op.apply(u16("abcdef"))
+	if err != nil || str16(doc) != "abc😀xf" {
+		t.Errorf("apply = %q, %v", str16(doc), err)
+	}
+	data, _ := json.Marshal(op)
+	if string(data) != `[3,"😀x",-2,1]` {
+		t.Errorf("marshal = %s", data)
+	}
+
+	for _, bad := range []string{`[0]`, `[1.5]`, `{}`, `[true]`} {
+		if err := json.Unmarshal([]byte(bad), &op); err == nil {
+			t.Errorf("%s: ожидалась ошибка", bad)
+		}
+	}
+	if _, err := op.apply(u16("short")); err != errOpMismatch {
+		t.Errorf("apply к документу другой длины: %v", err)
+	}
+}
+
+func TestDiffOpAndTransformIndex(t *testing.T) {
+	old, new := u16("hello world"), u16("hello brave new world")
+	op := diffOp(old, new)
+	got, err := op.apply(old)
+	if err != nil || str16(got) != str16(new) {
+		t.Fatalf("diffOp = %q, %v", str16(got), err)
+	}
+	// Курсор перед "world" сдвигается на длину вставки, в начале — остаётся
+	if pos := op.transformIndex(6); pos != 16 {
+		t.Errorf("transformIndex(6) = %d", pos)
+	}
+	if pos := op.transformIndex(2); pos != 2 {
+		t.Errorf("transformIndex(2) = %d", pos)
+	}
+}
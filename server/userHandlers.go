@@ -7,7 +7,6 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
-	"html/template"
 	"net/http"
 	"pastebin/models"
 	"pastebin/utils"
@@ -61,6 +60,8 @@ func SignupHandler(w http.ResponseWriter, r *http.Request) {
 
 		// Получаем `userID` из результата вставки
 		userID := result.InsertedID.(primitive.ObjectID)
+		user.ID = userID
+		events.Publish(UserSignedUp{User: user})
 
 		// Генерация токена с `userID`
 		token := utils.GenerateToken(userID, email)
@@ -74,8 +75,7 @@ func SignupHandler(w http.ResponseWriter, r *http.Request) {
 
 		fmt.Fprintf(w, "Registration successful. Please verify your email.")
 	} else {
-		tmpl := template.Must(template.ParseFiles("web/signup.html"))
-		tmpl.Execute(w, nil)
+		render(w, r, "signup.html", nil)
 	}
 }
 
@@ -120,8 +120,7 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 
 	} else {
 		// Если метод GET, отображаем форму логина
-		tmpl := template.Must(template.ParseFiles("web/login.html"))
-		tmpl.Execute(w, nil)
+		render(w, r, "login.html", nil)
 	}
 }
 
@@ -198,18 +197,61 @@ func ProfileHandler(w http.ResponseWriter, r *http.Request) {
 		pastes = append(pastes, paste)
 	}
 
+	// API-токены пользователя
+	tokens, err := listAPITokens(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch API tokens", http.StatusInternalServerError)
+		return
+	}
+
+	// SSH-ключи пользователя
+	sshKeys, err := listSSHKeys(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch SSH keys", http.StatusInternalServerError)
+		return
+	}
+
+	// Чужие пасты, открытые пользователю
+	shared, err := listSharedPastes(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch shared pastes", http.StatusInternalServerError)
+		return
+	}
+
+	// Предложенные правки, ждущие решения владельца
+	proposals, err := listOpenProposals(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch proposals", http.StatusInternalServerError)
+		return
+	}
+
 	// Загружаем HTML-шаблон
-	tmpl := template.Must(template.ParseFiles("web/profile.html"))
-	tmpl.Execute(w, struct {
-		Name   string         `json:"name"`
-		Email  string         `json:"email"`
-		Pastes []models.Paste `json:"pastes"`
-		ChatID string         `json:"chat_id"`
+	render(w, r, "profile.html", struct {
+		UserID        string            `json:"user_id"`
+		Name          string            `json:"name"`
+		Email         string            `json:"email"`
+		Pastes        []models.Paste    `json:"pastes"`
+		Shared        []sharedPaste     `json:"shared"`
+		Proposals     []models.Proposal `json:"proposals"`
+		ChatID        string            `json:"chat_id"`
+		Tokens        []models.APIToken `json:"tokens"`
+		Scopes        []string          `json:"scopes"`
+		ExpiryOptions []string          `json:"expiry_options"`
+		SSHKeys       []models.SSHKey   `json:"ssh_keys"`
+		Now           time.Time         `json:"-"`
 	}{
-		Name:   user.Name,
-		Email:  user.Email,
-		Pastes: pastes,
-		ChatID: chatID,
+		UserID:        userID.Hex(),
+		Name:          user.Name,
+		Email:         user.Email,
+		Pastes:        pastes,
+		Shared:        shared,
+		Proposals:     proposals,
+		ChatID:        chatID,
+		Tokens:        tokens,
+		Scopes:        models.Scopes,
+		ExpiryOptions: []string{"never", "1week", "1month", "6months", "1year"},
+		SSHKeys:       sshKeys,
+		Now:           time.Now(),
 	})
 }
 func LogoutHandler(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,282 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Как часто сбрасываем накопленные просмотры в базу
+const viewFlushInterval = 5 * time.Second
+
+type dailyKey struct {
+	PasteID primitive.ObjectID
+	Day     string
+}
+
+type dailyDelta struct {
+	Views  int
+	Unique int
+}
+
+// Посетитель пасты за день; visitor — хеш из visitorHash
+type visitorKey struct {
+	PasteID primitive.ObjectID
+	Day     string
+	Visitor string
+}
+
+type referrerKey struct {
+	PasteID primitive.ObjectID
+	Day     string
+	Host    string
+}
+
+// Счётчики просмотров, накопленные в памяти между сбросами.
+// Популярная паста получает одно обновление за интервал вместо
+// обновления на каждый просмотр. Уникальность посетителей тоже
+// проверяется при сбросе: visitors — время первого просмотра
+type viewCounter struct {
+	mu        sync.Mutex
+	reads     map[primitive.ObjectID]int
+	daily     map[dailyKey]*dailyDelta
+	referrers map[referrerKey]int
+	visitors  map[visitorKey]time.Time
+}
+
+func newViewCounter() *viewCounter {
+	return &viewCounter{
+		reads:     make(map[primitive.ObjectID]int),
+		daily:     make(map[dailyKey]*dailyDelta),
+		referrers: make(map[referrerKey]int),
+		visitors:  make(map[visitorKey]time.Time),
+	}
+}
+
+var pendingViews = newViewCounter()
+
+func (c *viewCounter) addRead(pasteID primitive.ObjectID) {
+	c.mu.Lock()
+	c.reads[pasteID]++
+	c.mu.Unlock()
+}
+
+func (c *viewCounter) addView(pasteID primitive.ObjectID, day, visitor string, at time.Time, host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := dailyKey{PasteID: pasteID, Day: day}
+	delta, ok := c.daily[key]
+	if !ok {
+		delta = &dailyDelta{}
+		c.daily[key] = delta
+	}
+	delta.Views++
+	if host != "" {
+		c.referrers[referrerKey{PasteID: pasteID, Day: day, Host: host}]++
+	}
+	visit := visitorKey{PasteID: pasteID, Day: day, Visitor: visitor}
+	if _, ok := c.visitors[visit]; !ok {
+		c.visitors[visit] = at
+	}
+}
+
+// Забираем накопленное и обнуляем счётчики
+func (c *viewCounter) swap() (map[primitive.ObjectID]int, map[dailyKey]*dailyDelta, map[referrerKey]int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	reads, daily, referrers := c.reads, c.daily, c.referrers
+	c.reads = make(map[primitive.ObjectID]int)
+	c.daily = make(map[dailyKey]*dailyDelta)
+	c.referrers = make(map[referrerKey]int)
+	return reads, daily, referrers
+}
+
+// Возвращаем несохранённые счётчики, чтобы записать их при следующем сбросе
+func (c *viewCounter) merge(reads map[primitive.ObjectID]int, daily map[dailyKey]*dailyDelta, referrers map[referrerKey]int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, n := range reads {
+		c.reads[id] += n
+	}
+	for key, d := range daily {
+		delta, ok := c.daily[key]
+		if !ok {
+			delta = &dailyDelta{}
+			c.daily[key] = delta
+		}
+		delta.Views += d.Views
+		delta.Unique += d.Unique
+	}
+	for key, n := range referrers {
+		c.referrers[key] += n
+	}
+}
+
+// Забираем посетителей, ещё не записанных в paste_views
+func (c *viewCounter) takeVisitors() map[visitorKey]time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	visitors := c.visitors
+	c.visitors = make(map[visitorKey]time.Time)
+	return visitors
+}
+
+func (c *viewCounter) mergeVisitors(visitors map[visitorKey]time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, at := range visitors {
+		if prev, ok := c.visitors[key]; !ok || at.Before(prev) {
+			c.visitors[key] = at
+		}
+	}
+}
+
+// Номера операций пачки, которые не выполнились: при ошибках записи —
+// только они, при любой другой ошибке — все
+func failedWrites(err error, n int) []int {
+	if err == nil {
+		return nil
+	}
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil && len(bulkErr.WriteErrors) > 0 {
+		failed := make([]int, 0, len(bulkErr.WriteErrors))
+		for _, e := range bulkErr.WriteErrors {
+			failed = append(failed, e.Index)
+		}
+		return failed
+	}
+	failed := make([]int, n)
+	for i := range failed {
+		failed[i] = i
+	}
+	return failed
+}
+
+// Записываем накопленные счётчики пачками. Каждая коллекция пишется
+// независимо; то, что записать не удалось, возвращается в счётчик
+func (c *viewCounter) flush(ctx context.Context) error {
+	reads, daily, referrers := c.swap()
+	visitors := c.takeVisitors()
+	bulk := options.BulkWrite().SetOrdered(false)
+	var errs []error
+
+	// Посетители пишутся первыми: вставленные впервые за день попадают
+	// в уникальные просмотры этого же сброса
+	if len(visitors) > 0 {
+		keys := make([]visitorKey, 0, len(visitors))
+		writes := make([]mongo.WriteModel, 0, len(visitors))
+		for key, at := range visitors {
+			keys = append(keys, key)
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"paste_id": key.PasteID, "visitor": key.Visitor, "day": key.Day}).
+				SetUpdate(bson.M{"$setOnInsert": bson.M{"viewedAt": at}}).
+				SetUpsert(true))
+		}
+		result, err := GetCollection("paste_views").BulkWrite(ctx, writes, bulk)
+		if result != nil {
+			for i := range result.UpsertedIDs {
+				key := dailyKey{PasteID: keys[i].PasteID, Day: keys[i].Day}
+				delta, ok := daily[key]
+				if !ok {
+					delta = &dailyDelta{}
+					daily[key] = delta
+				}
+				delta.Unique++
+			}
+		}
+		failed := make(map[visitorKey]time.Time)
+		for _, i := range failedWrites(err, len(writes)) {
+			failed[keys[i]] = visitors[keys[i]]
+		}
+		c.mergeVisitors(failed)
+		errs = append(errs, err)
+	}
+
+	if len(reads) > 0 {
+		ids := make([]primitive.ObjectID, 0, len(reads))
+		writes := make([]mongo.WriteModel, 0, len(reads))
+		for id, n := range reads {
+			ids = append(ids, id)
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": id}).
+				SetUpdate(bson.M{"$inc": bson.M{"currentReads": n}}))
+		}
+		_, err := GetCollection("pastes").BulkWrite(ctx, writes, bulk)
+		failed := make(map[primitive.ObjectID]int)
+		for _, i := range failedWrites(err, len(writes)) {
+			failed[ids[i]] = reads[ids[i]]
+		}
+		c.merge(failed, nil, nil)
+		errs = append(errs, err)
+	}
+
+	if len(daily) > 0 {
+		keys := make([]dailyKey, 0, len(daily))
+		writes := make([]mongo.WriteModel, 0, len(daily))
+		for key, delta := range daily {
+			keys = append(keys, key)
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"paste_id": key.PasteID, "day": key.Day}).
+				SetUpdate(bson.M{"$inc": bson.M{"views": delta.Views, "unique": delta.Unique}}).
+				SetUpsert(true))
+		}
+		_, err := GetCollection("paste_stats_daily").BulkWrite(ctx, writes, bulk)
+		failed := make(map[dailyKey]*dailyDelta)
+		for _, i := range failedWrites(err, len(writes)) {
+			failed[keys[i]] = daily[keys[i]]
+		}
+		c.merge(nil, failed, nil)
+		errs = append(errs, err)
+	}
+
+	if len(referrers) > 0 {
+		keys := make([]referrerKey, 0, len(referrers))
+		writes := make([]mongo.WriteModel, 0, len(referrers))
+		for key, n := range referrers {
+			keys = append(keys, key)
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"paste_id": key.PasteID, "day": key.Day, "host": key.Host}).
+				SetUpdate(bson.M{"$inc": bson.M{"count": n}}).
+				SetUpsert(true))
+		}
+		_, err := GetCollection("paste_referrers").BulkWrite(ctx, writes, bulk)
+		failed := make(map[referrerKey]int)
+		for _, i := range failedWrites(err, len(writes)) {
+			failed[keys[i]] = referrers[keys[i]]
+		}
+		c.merge(nil, nil, failed)
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Фоновый сброс счётчиков по таймеру
+func StartViewFlusher(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(viewFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := pendingViews.flush(ctx); err != nil {
+					log.Printf("Ошибка сброса счётчиков просмотров: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Последний сброс счётчиков при остановке сервера
+func FlushViews(ctx context.Context) error {
+	return pendingViews.flush(ctx)
+}
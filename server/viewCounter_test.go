@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestViewCounterAggregates(t *testing.T) {
+	c := newViewCounter()
+	id := primitive.NewObjectID()
+
+	for i := 0; i < 3; i++ {
+		c.addRead(id)
+	}
+	first := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	c.addView(id, "2026-01-01", "alice", first, "example.com")
+	c.addView(id, "2026-01-01", "alice", first.Add(time.Minute), "example.com")
+	c.addView(id, "2026-01-01", "bob", first, "")
+
+	reads, daily, referrers := c.swap()
+	if reads[id] != 3 {
+		t.Errorf("Ожидалось 3 прочтения, получено %d", reads[id])
+	}
+	delta := daily[dailyKey{PasteID: id, Day: "2026-01-01"}]
+	// Уникальных посетителей посчитает сброс, когда запишет их в базу
+	if delta == nil || delta.Views != 3 || delta.Unique != 0 {
+		t.Errorf("Неверные дневные счётчики: %+v", delta)
+	}
+	visitors := c.takeVisitors()
+	if len(visitors) != 2 || !visitors[visitorKey{PasteID: id, Day: "2026-01-01", Visitor: "alice"}].Equal(first) {
+		t.Errorf("Неверные посетители: %v", visitors)
+	}
+	if n := referrers[referrerKey{PasteID: id, Day: "2026-01-01", Host: "example.com"}]; n != 2 {
+		t.Errorf("Ожидалось 2 перехода, получено %d", n)
+	}
+
+	// После swap счётчики пустые
+	reads, daily, referrers = c.swap()
+	if len(reads) != 0 || len(daily) != 0 || len(referrers) != 0 {
+		t.Error("Счётчики не обнулились после swap")
+	}
+}
+
+func TestViewCounterMergeKeepsNewViews(t *testing.T) {
+	c := newViewCounter()
+	id := primitive.NewObjectID()
+	day := dailyKey{PasteID: id, Day: "2026-01-01"}
+	ref := referrerKey{PasteID: id, Day: "2026-01-01", Host: "example.com"}
+
+	// Пока шёл неудачный сброс, пришли новые просмотры
+	c.addRead(id)
+	c.addView(id, day.Day, "alice", time.Now(), ref.Host)
+	c.merge(map[primitive.ObjectID]int{id: 2}, map[dailyKey]*dailyDelta{day: {Views: 2, Unique: 1}}, map[referrerKey]int{ref: 2})
+
+	reads, daily, referrers := c.swap()
+	if reads[id] != 3 || daily[day].Views != 3 || daily[day].Unique != 1 || referrers[ref] != 3 {
+		t.Errorf("после возврата: reads=%d daily=%+v referrers=%d", reads[id], daily[day], referrers[ref])
+	}
+}
+
+func TestFailedWrites(t *testing.T) {
+	if got := failedWrites(nil, 3); len(got) != 0 {
+		t.Errorf("без ошибки: %v", got)
+	}
+	if got := failedWrites(errors.New("connection reset"), 3); len(got) != 3 {
+		t.Errorf("сетевая ошибка: %v", got)
+	}
+	partial := mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{WriteError: mongo.WriteError{Index: 1}}}}
+	if got := failedWrites(partial, 3); len(got) != 1 || got[0] != 1 {
+		t.Errorf("ошибка одной записи: %v", got)
+	}
+}
+
+func TestViewCounterFlushCountsNewVisitors(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("уникальные по результату upsert", func(mt *mtest.T) {
+		useTestDB(mt)
+		c := newViewCounter()
+		id := primitive.NewObjectID()
+		c.addView(id, "2026-01-01", "alice", time.Now(), "")
+		c.addView(id, "2026-01-01", "bob", time.Now(), "")
+
+		// Из двух посетителей впервые за день пришёл только один
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 2}, {Key: "nModified", Value: 0},
+				{Key: "upserted", Value: bson.A{bson.D{{Key: "index", Value: 1}, {Key: "_id", Value: primitive.NewObjectID()}}}}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}},
+		)
+		if err := c.flush(context.Background()); err != nil {
+			mt.Fatalf("flush: %v", err)
+		}
+
+		if got := mt.GetStartedEvent().CommandName; got != "update" {
+			mt.Fatalf("первой записью ожидалась paste_views, получено %s", got)
+		}
+		daily := mt.GetStartedEvent().Command
+		if coll := daily.Lookup("update").StringValue(); coll != "paste_stats_daily" {
+			mt.Fatalf("второй записью ожидалась paste_stats_daily, получено %s", coll)
+		}
+		inc := daily.Lookup("updates").Array().Index(0).Value().Document().Lookup("u", "$inc")
+		if views, unique := inc.Document().Lookup("views").Int32(), inc.Document().Lookup("unique").Int32(); views != 2 || unique != 1 {
+			mt.Errorf("views = %d, unique = %d, want 2 и 1", views, unique)
+		}
+		if len(c.takeVisitors()) != 0 {
+			mt.Error("записанные посетители остались в очереди")
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Соль меняется раз в сутки, поэтому посетителя нельзя отследить между днями
+var (
+	saltMu    sync.Mutex
+	saltDay   string
+	saltValue string
+)
+
+// IP клиента без порта
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// Соль текущих суток. Хранится в базе, чтобы все экземпляры сервера
+// считали одинаково; соли прошлых дней удаляются
+func dailySalt(ctx context.Context, day string) (string, error) {
+	saltMu.Lock()
+	defer saltMu.Unlock()
+	if saltDay == day {
+		return saltValue, nil
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	var doc struct {
+		Salt string `bson:"salt"`
+	}
+	err := GetCollection("view_salts").FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": day},
+		bson.M{"$setOnInsert": bson.M{"salt": hex.EncodeToString(buf)}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&doc)
+	if err != nil {
+		return "", err
+	}
+	if _, err := GetCollection("view_salts").DeleteMany(ctx, bson.M{"_id": bson.M{"$lt": day}}); err != nil {
+		log.Printf("Ошибка удаления старых солей просмотров: %v", err)
+	}
+
+	saltDay, saltValue = day, doc.Salt
+	return saltValue, nil
+}
+
+// Анонимный идентификатор посетителя: хеш соли дня, IP и User-Agent
+func visitorHash(salt string, r *http.Request) string {
+	sum := sha256.Sum256([]byte(salt + "|" + clientIP(r) + "|" + r.UserAgent()))
+	return hex.EncodeToString(sum[:])
+}
+
+// Хост из Referer; переходы внутри сайта не считаем
+func referrerHost(r *http.Request) string {
+	ref, err := url.Parse(r.Referer())
+	if err != nil || ref.Host == "" || strings.EqualFold(ref.Host, r.Host) {
+		return ""
+	}
+	return strings.ToLower(ref.Host)
+}
+
+// Записываем просмотр пасты. Посетитель, дневные счётчики и источник
+// перехода накапливаются в памяти, в базу их пишет viewCounter
+func recordView(ctx context.Context, pasteID primitive.ObjectID, r *http.Request) error {
+	now := time.Now().UTC()
+	day := now.Format("2006-01-02")
+
+	salt, err := dailySalt(ctx, day)
+	if err != nil {
+		return err
+	}
+	pendingViews.addView(pasteID, day, visitorHash(salt, r), now, referrerHost(r))
+	events.Publish(PasteViewed{PasteID: pasteID, At: now})
+	return nil
+}
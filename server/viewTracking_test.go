@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestReferrerHost(t *testing.T) {
+	cases := map[string]string{
+		"":                                "",
+		"https://News.Example.com/item?1": "news.example.com",
+		"https://paste.test/paste/abc":    "", // Переход внутри сайта
+		"https://PASTE.test/":             "",
+		"not a url":                       "",
+		"%zz":                             "",
+	}
+	for referer, want := range cases {
+		r := httptest.NewRequest("GET", "https://paste.test/paste/abc", nil)
+		r.Header.Set("Referer", referer)
+		if got := referrerHost(r); got != want {
+			t.Errorf("referrerHost(%q) = %q, want %q", referer, got, want)
+		}
+	}
+}
+
+func TestVisitorHash(t *testing.T) {
+	visit := func(salt, addr, agent string) string {
+		r := httptest.NewRequest("GET", "/paste/abc", nil)
+		r.RemoteAddr = addr
+		r.Header.Set("User-Agent", agent)
+		return visitorHash(salt, r)
+	}
+
+	first := visit("salt", "203.0.113.5:1000", "curl/8")
+	if len(first) != 64 {
+		t.Errorf("ожидался hex SHA-256, получено %q", first)
+	}
+	if visit("salt", "203.0.113.5:2000", "curl/8") != first {
+		t.Error("другой порт того же посетителя дал другой хеш")
+	}
+	if visit("salt", "203.0.113.6:1000", "curl/8") == first || visit("salt", "203.0.113.5:1000", "firefox") == first {
+		t.Error("разные посетители дали одинаковый хеш")
+	}
+	if visit("other salt", "203.0.113.5:1000", "curl/8") == first {
+		t.Error("хеш не зависит от соли")
+	}
+}
+
+func TestDailySalt(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("соль кэшируется до смены дня", func(mt *mtest.T) {
+		useTestDB(mt)
+		saltDay, saltValue = "", ""
+		mt.Cleanup(func() { saltDay, saltValue = "", "" })
+
+		saltResponse := func(day, salt string) bson.D {
+			return mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{{Key: "_id", Value: day}, {Key: "salt", Value: salt}}})
+		}
+		deleted := bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}}
+		mt.AddMockResponses(saltResponse("2024-05-01", "first"), deleted)
+
+		ctx := context.Background()
+		if salt, err := dailySalt(ctx, "2024-05-01"); err != nil || salt != "first" {
+			mt.Fatalf("dailySalt = %q, %v", salt, err)
+		}
+		// Ответов в очереди больше нет: второй вызов за тот же день не идёт в базу
+		if salt, err := dailySalt(ctx, "2024-05-01"); err != nil || salt != "first" {
+			mt.Errorf("повторный вызов: %q, %v", salt, err)
+		}
+
+		// Удаление старых солей не удалось — соль нового дня всё равно выдаётся
+		mt.AddMockResponses(saltResponse("2024-05-02", "second"),
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 8000, Message: "delete failed"}))
+		if salt, err := dailySalt(ctx, "2024-05-02"); err != nil || salt != "second" {
+			mt.Errorf("новый день: %q, %v", salt, err)
+		}
+	})
+}
@@ -10,7 +10,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"html/template"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
 	"net/http"
 	"pastebin/models"
@@ -53,15 +53,18 @@ func handleWebSocket(conn *websocket.Conn, chatID string) {
 			Timestamp: time.Now(),
 		}
 		chatObjectID, _ := primitive.ObjectIDFromHex(chatID)
-		_, err = GetCollection("chats").UpdateOne(
+		var chat models.Chat
+		err = GetCollection("chats").FindOneAndUpdate(
 			context.TODO(),
 			bson.M{"_id": chatObjectID},
 			bson.M{"$push": bson.M{"messages": message}},
-		)
+			options.FindOneAndUpdate().SetProjection(bson.M{"user_id": 1}),
+		).Decode(&chat)
 		if err != nil {
 			log.Println("Ошибка сохранения сообщения:", err)
 			continue
 		}
+		events.Publish(ChatMessagePosted{Chat: chat, Message: message})
 
 		// Отправляем сообщение обратно всем подключённым клиентам
 		err = conn.WriteJSON(msg)
@@ -169,7 +172,7 @@ func CloseChatHandler(w http.ResponseWriter, r *http.Request) {
 	chatID := r.URL.Query().Get("chat_id")
 	chatObjectID, _ := primitive.ObjectIDFromHex(chatID)
 
-	_, err := GetCollection("chats").UpdateOne(
+	result, err := GetCollection("chats").UpdateOne(
 		context.TODO(),
 		bson.M{"_id": chatObjectID},
 		bson.M{"$set": bson.M{"status": "inactive", "messages": []models.Message{}}},
@@ -178,6 +181,9 @@ func CloseChatHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Ошибка закрытия чата", http.StatusInternalServerError)
 		return
 	}
+	if result.ModifiedCount > 0 {
+		events.Publish(ChatClosed{ChatID: chatObjectID, At: time.Now()})
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -208,12 +214,6 @@ func ChatPageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tmpl, err := template.ParseFiles("web/chat.html")
-	if err != nil {
-		http.Error(w, "Ошибка загрузки шаблона", http.StatusInternalServerError)
-		return
-	}
-
 	// Передаём данные в шаблон
 	data := struct {
 		ChatID  string
@@ -223,10 +223,7 @@ func ChatPageHandler(w http.ResponseWriter, r *http.Request) {
 		IsAdmin: user.Role == "admin", // Проверяем, является ли пользователь админом
 	}
 
-	err = tmpl.Execute(w, data)
-	if err != nil {
-		http.Error(w, "Ошибка рендеринга страницы", http.StatusInternalServerError)
-	}
+	render(w, r, "chat.html", data)
 }
 
 func GetChatHistory(w http.ResponseWriter, r *http.Request) {
@@ -313,10 +310,5 @@ func AllChatsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Рендерим страницу
-	tmpl := template.Must(template.ParseFiles("web/allchats.html"))
-	err = tmpl.Execute(w, chats)
-	if err != nil {
-		log.Printf("Ошибка рендеринга: %v\n", err)
-		http.Error(w, "Ошибка рендеринга страницы", http.StatusInternalServerError)
-	}
+	render(w, r, "allchats.html", chats)
 }
@@ -0,0 +1,292 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/url"
+	"pastebin/models"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Сколько последних доставок показываем на странице вебхука
+const webhookHistoryLimit = 50
+
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// Адрес получателя: только абсолютный http(s) на публичный адрес
+func validWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return false
+	}
+	// Все адреса имени должны быть публичными; при отправке это
+	// проверяется ещё раз, см. webhookDialControl
+	for _, addr := range addrs {
+		if !webhookAddressAllowed(addr.IP) {
+			return false
+		}
+	}
+	return true
+}
+
+// Вебхук, которым может управлять пользователь: свой, а глобальный — любой админ
+func findManagedWebhook(ctx context.Context, user *models.User, id primitive.ObjectID) (models.Webhook, error) {
+	filter := bson.M{"_id": id, "user_id": user.ID}
+	if user.Role == "admin" {
+		filter = bson.M{"_id": id, "$or": bson.A{bson.M{"user_id": user.ID}, bson.M{"global": true}}}
+	}
+	var hook models.Webhook
+	err := GetCollection("webhooks").FindOne(ctx, filter).Decode(&hook)
+	return hook, err
+}
+
+// GET /webhooks
+func WebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	filter := bson.M{"user_id": user.ID}
+	if user.Role == "admin" {
+		filter = bson.M{"$or": bson.A{bson.M{"user_id": user.ID}, bson.M{"global": true}}}
+	}
+	cursor, err := GetCollection("webhooks").Find(r.Context(), filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to fetch webhooks")
+		return
+	}
+	var hooks []models.Webhook
+	if err := cursor.All(r.Context(), &hooks); err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to decode webhooks")
+		return
+	}
+
+	render(w, r, "webhooks.html", struct {
+		Webhooks []models.Webhook
+		Events   []string
+		IsAdmin  bool
+	}{
+		Webhooks: hooks,
+		Events:   models.WebhookEvents,
+		IsAdmin:  user.Role == "admin",
+	})
+}
+
+// POST /webhooks
+func CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	target := strings.TrimSpace(r.FormValue("url"))
+	if !validWebhookURL(target) {
+		setFlash(w, "Webhook URL must be an absolute http(s) URL on a public address")
+		http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+		return
+	}
+	var events []string
+	for _, event := range models.WebhookEvents {
+		for _, selected := range r.Form["events"] {
+			if selected == event {
+				events = append(events, event)
+				break
+			}
+		}
+	}
+	if len(events) == 0 {
+		setFlash(w, "Select at least one event")
+		http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+		return
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to generate secret")
+		return
+	}
+	hook := models.Webhook{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID,
+		URL:       target,
+		Secret:    secret,
+		Events:    events,
+		Global:    user.Role == "admin" && r.FormValue("global") == "on",
+		CreatedAt: time.Now(),
+	}
+	if _, err := GetCollection("webhooks").InsertOne(r.Context(), hook); err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to save webhook")
+		return
+	}
+
+	setFlash(w, "Webhook created")
+	http.Redirect(w, r, "/webhooks/"+hook.ID.Hex(), http.StatusSeeOther)
+}
+
+// GET /webhooks/{id} — секрет и история доставок
+func WebhookDetailHandler(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	hookID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+	hook, err := findManagedWebhook(r.Context(), user, hookID)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to fetch webhook")
+		return
+	}
+
+	cursor, err := GetCollection("webhook_deliveries").Find(r.Context(),
+		bson.M{"webhook_id": hook.ID},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(webhookHistoryLimit),
+	)
+	if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to fetch deliveries")
+		return
+	}
+	var deliveries []models.WebhookDelivery
+	if err := cursor.All(r.Context(), &deliveries); err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to decode deliveries")
+		return
+	}
+
+	render(w, r, "webhook.html", struct {
+		Webhook     models.Webhook
+		Deliveries  []models.WebhookDelivery
+		MaxAttempts int
+	}{
+		Webhook:     hook,
+		Deliveries:  deliveries,
+		MaxAttempts: webhookMaxAttempts,
+	})
+}
+
+// POST /webhooks/{id}/delete
+func DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	hookID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+	hook, err := findManagedWebhook(r.Context(), user, hookID)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Webhook not found or access denied", http.StatusForbidden)
+		return
+	} else if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to fetch webhook")
+		return
+	}
+
+	if _, err := GetCollection("webhooks").DeleteOne(r.Context(), bson.M{"_id": hook.ID}); err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+	// Недоставленное больше не отправляем; история удаляется вместе с вебхуком
+	if _, err := GetCollection("webhook_deliveries").DeleteMany(r.Context(), bson.M{"webhook_id": hook.ID}); err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to delete delivery history")
+		return
+	}
+
+	setFlash(w, "Webhook deleted")
+	http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+}
+
+// POST /webhooks/{id}/deliveries/{delivery}/redeliver — повтор с тем же телом
+func RedeliverWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	vars := mux.Vars(r)
+	hookID, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+	deliveryID, err := primitive.ObjectIDFromHex(vars["delivery"])
+	if err != nil {
+		http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+		return
+	}
+	hook, err := findManagedWebhook(r.Context(), user, hookID)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Webhook not found or access denied", http.StatusForbidden)
+		return
+	} else if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to fetch webhook")
+		return
+	}
+
+	var original models.WebhookDelivery
+	err = GetCollection("webhook_deliveries").FindOne(r.Context(), bson.M{"_id": deliveryID, "webhook_id": hook.ID}).Decode(&original)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to fetch delivery")
+		return
+	}
+
+	now := time.Now()
+	replay := models.WebhookDelivery{
+		ID:            primitive.NewObjectID(),
+		WebhookID:     hook.ID,
+		Event:         original.Event,
+		Payload:       original.Payload,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: now,
+		ReplayOf:      original.ID,
+		CreatedAt:     now,
+	}
+	if _, err := GetCollection("webhook_deliveries").InsertOne(r.Context(), replay); err != nil {
+		HandleError(w, err, http.StatusInternalServerError, "Failed to queue delivery")
+		return
+	}
+	wakeWebhookDispatcher()
+
+	setFlash(w, "Delivery queued")
+	http.Redirect(w, r, "/webhooks/"+hook.ID.Hex(), http.StatusSeeOther)
+}
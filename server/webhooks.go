@@ -0,0 +1,408 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"pastebin/models"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Параметры доставки вебхуков
+const (
+	webhookPollInterval = 5 * time.Second
+	webhookTimeout      = 10 * time.Second
+	webhookMaxAttempts  = 6
+	webhookBaseBackoff  = 30 * time.Second    // 30s, 1m, 2m, 4m, 8m
+	webhookBatchSize    = 50                  // Сколько доставок отправляем за один проход
+	webhookWorkers      = 8                   // Одновременных отправок
+	webhookPerHookLimit = 2                   // Из них на один вебхук
+	webhookClaimTimeout = 2 * webhookTimeout  // Через сколько брошенную доставку можно взять снова
+	webhookHistoryTTL   = 30 * 24 * time.Hour // Сколько хранить законченные доставки
+)
+
+// Заголовки доставки
+const (
+	webhookSignatureHeader = "X-Pastebin-Signature"
+	webhookEventHeader     = "X-Pastebin-Event"
+	webhookDeliveryHeader  = "X-Pastebin-Delivery"
+)
+
+var errWebhookAddress = errors.New("webhook target address is not allowed")
+
+// Вебхуки не должны ходить во внутреннюю сеть сервера. Адрес проверяется
+// при каждом соединении, уже после разрешения имени, поэтому подмена DNS
+// после регистрации вебхука не помогает. Редиректы не выполняются
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: webhookTimeout,
+			Control: webhookDialControl,
+		}).DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Публичный ли адрес: петля, link-local (в том числе метаданные облака),
+// частные сети и 0.0.0.0 запрещены
+func webhookAddressAllowed(ip net.IP) bool {
+	return ip != nil && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsPrivate() && !ip.IsUnspecified() && !ip.IsMulticast()
+}
+
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !webhookAddressAllowed(net.ParseIP(host)) {
+		return errWebhookAddress
+	}
+	return nil
+}
+
+// Будим рассыльщик, когда появились новые доставки
+var webhookWake = make(chan struct{}, 1)
+
+// Тело доставки
+type webhookEnvelope struct {
+	ID        string      `json:"id"` // Общий для всех вебхуков, получивших это событие
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Паста в событиях; содержимое не отправляем, его можно получить через API
+type PasteEventData struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	UserID     string    `json:"user_id"`
+	Title      string    `json:"title"`
+	Language   string    `json:"language"`
+	Visibility string    `json:"visibility"`
+	Revision   int       `json:"revision"`
+	Size       int       `json:"size"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Reason     string    `json:"reason,omitempty"` // Для paste.expired: "expired" или "burned"
+}
+
+type ChatMessageEventData struct {
+	ChatID    string    `json:"chat_id"`
+	UserID    string    `json:"user_id"`
+	Sender    string    `json:"sender"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Адрес сайта для ссылок вне HTTP-запроса
+func siteBaseURL() string {
+	if base := os.Getenv("BASE_URL"); base != "" {
+		return base
+	}
+	return defaultBaseURL
+}
+
+func pasteEventData(p models.Paste, reason string) PasteEventData {
+	visibility := p.Visibility
+	if visibility == "" {
+		visibility = models.VisibilityPublic
+	}
+	return PasteEventData{
+		ID:         p.ID.Hex(),
+		URL:        pasteLink(siteBaseURL(), p.ID),
+		UserID:     p.UserID.Hex(),
+		Title:      p.Title,
+		Language:   p.Language,
+		Visibility: visibility,
+		Revision:   p.Revision,
+		Size:       len(p.Content),
+		CreatedAt:  p.CreatedAt,
+		UpdatedAt:  p.UpdatedAt,
+		Reason:     reason,
+	}
+}
+
+func chatMessageEventData(chat models.Chat, m models.Message) ChatMessageEventData {
+	return ChatMessageEventData{
+		ChatID:    chat.ID.Hex(),
+		UserID:    chat.UserID.Hex(),
+		Sender:    m.Sender,
+		Content:   m.Content,
+		Timestamp: m.Timestamp,
+	}
+}
+
+// Ставим событие в очередь доставки всем подписанным вебхукам: своим
+// вебхукам владельца и глобальным
+func enqueueWebhookEvent(ctx context.Context, event string, ownerID primitive.ObjectID, data interface{}, now time.Time) error {
+	owners := bson.A{bson.M{"global": true}}
+	if !ownerID.IsZero() {
+		owners = append(owners, bson.M{"user_id": ownerID})
+	}
+	cursor, err := GetCollection("webhooks").Find(ctx, bson.M{"events": event, "$or": owners})
+	if err != nil {
+		return err
+	}
+	var hooks []models.Webhook
+	if err := cursor.All(ctx, &hooks); err != nil {
+		return err
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(webhookEnvelope{
+		ID:        primitive.NewObjectIDFromTimestamp(now).Hex(),
+		Event:     event,
+		CreatedAt: now,
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	deliveries := make([]interface{}, 0, len(hooks))
+	for _, hook := range hooks {
+		deliveries = append(deliveries, models.WebhookDelivery{
+			ID:            primitive.NewObjectID(),
+			WebhookID:     hook.ID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+	}
+	if _, err := GetCollection("webhook_deliveries").InsertMany(ctx, deliveries); err != nil {
+		return err
+	}
+	wakeWebhookDispatcher()
+	return nil
+}
+
+func wakeWebhookDispatcher() {
+	select {
+	case webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// Подпись тела: sha256=<hex HMAC-SHA256(secret, body)>
+func signWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Пауза перед следующей попыткой: удваивается после каждой неудачи
+func webhookBackoff(attempts int) time.Duration {
+	return webhookBaseBackoff << (attempts - 1)
+}
+
+// Отправляем доставку; ошибка — сетевая или ответ не 2xx
+func sendWebhook(hook models.Webhook, delivery models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader([]byte(delivery.Payload)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pastebin-webhooks/1")
+	req.Header.Set(webhookEventHeader, delivery.Event)
+	req.Header.Set(webhookDeliveryHeader, delivery.ID.Hex())
+	req.Header.Set(webhookSignatureHeader, signWebhookPayload(hook.Secret, []byte(delivery.Payload)))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Одна попытка доставки с записью результата
+func attemptWebhookDelivery(ctx context.Context, hook models.Webhook, delivery models.WebhookDelivery, now time.Time) error {
+	statusCode, err := sendWebhook(hook, delivery)
+	attempts := delivery.Attempts + 1
+
+	set := bson.M{"attempts": attempts, "statusCode": statusCode}
+	unset := bson.M{"lockedUntil": ""}
+	update := bson.M{"$set": set, "$unset": unset}
+	switch {
+	case err == nil:
+		set["status"] = models.DeliverySucceeded
+		set["deliveredAt"] = time.Now()
+		set["finishedAt"] = time.Now()
+		unset["lastError"] = ""
+	case attempts >= webhookMaxAttempts:
+		set["status"] = models.DeliveryFailed
+		set["lastError"] = err.Error()
+		set["finishedAt"] = time.Now()
+	default:
+		set["status"] = models.DeliveryPending
+		set["lastError"] = err.Error()
+		set["nextAttemptAt"] = now.Add(webhookBackoff(attempts))
+	}
+	_, updateErr := GetCollection("webhook_deliveries").UpdateOne(ctx, bson.M{"_id": delivery.ID}, update)
+	return updateErr
+}
+
+// Забираем одну доставку, время которой пришло, чтобы её не отправил
+// параллельно другой экземпляр сервера. Доставка, которую взяли и не
+// закончили (процесс упал), снова доступна после lockedUntil.
+// Доставки вебхуков из busy пропускаем
+func claimWebhookDelivery(ctx context.Context, busy []primitive.ObjectID, now time.Time) (models.WebhookDelivery, error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": models.DeliveryPending, "nextAttemptAt": bson.M{"$lte": now}},
+		bson.M{"status": models.DeliverySending, "lockedUntil": bson.M{"$lte": now}},
+	}}
+	if len(busy) > 0 {
+		filter["webhook_id"] = bson.M{"$nin": busy}
+	}
+	var delivery models.WebhookDelivery
+	err := GetCollection("webhook_deliveries").FindOneAndUpdate(ctx, filter,
+		bson.M{"$set": bson.M{"status": models.DeliverySending, "lockedUntil": now.Add(webhookClaimTimeout)}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&delivery)
+	return delivery, err
+}
+
+// Отправляем взятую доставку
+func deliverClaimed(ctx context.Context, delivery models.WebhookDelivery) error {
+	var hook models.Webhook
+	err := GetCollection("webhooks").FindOne(ctx, bson.M{"_id": delivery.WebhookID}).Decode(&hook)
+	if err == mongo.ErrNoDocuments {
+		// Вебхук удалён — доставлять некуда
+		_, err = GetCollection("webhook_deliveries").UpdateOne(ctx,
+			bson.M{"_id": delivery.ID},
+			bson.M{
+				"$set":   bson.M{"status": models.DeliveryFailed, "lastError": "webhook deleted", "finishedAt": time.Now()},
+				"$unset": bson.M{"lockedUntil": ""},
+			},
+		)
+		return err
+	} else if err != nil {
+		// Доставку снова возьмут, когда истечёт lockedUntil
+		return err
+	}
+	return attemptWebhookDelivery(ctx, hook, delivery, time.Now())
+}
+
+// Один проход рассылки: сколько доставок уже взято и сколько сейчас
+// отправляется на каждый вебхук
+type webhookDispatch struct {
+	mu       sync.Mutex
+	claimed  int
+	inflight map[primitive.ObjectID]int
+}
+
+// Вебхуки, на которые уже идёт webhookPerHookLimit отправок
+func (d *webhookDispatch) busyHooks() []primitive.ObjectID {
+	var busy []primitive.ObjectID
+	for id, n := range d.inflight {
+		if n >= webhookPerHookLimit {
+			busy = append(busy, id)
+		}
+	}
+	return busy
+}
+
+// Берём и отправляем доставки, пока они есть и не исчерпан лимит прохода
+func (d *webhookDispatch) work(ctx context.Context) error {
+	for {
+		// Взятие доставки и учёт её вебхука — под одной блокировкой, иначе
+		// два обработчика могут одновременно превысить лимит на вебхук
+		d.mu.Lock()
+		if d.claimed >= webhookBatchSize {
+			d.mu.Unlock()
+			return nil
+		}
+		delivery, err := claimWebhookDelivery(ctx, d.busyHooks(), time.Now())
+		if err != nil {
+			d.mu.Unlock()
+			if err == mongo.ErrNoDocuments {
+				return nil
+			}
+			return err
+		}
+		d.claimed++
+		d.inflight[delivery.WebhookID]++
+		d.mu.Unlock()
+
+		err = deliverClaimed(ctx, delivery)
+
+		d.mu.Lock()
+		if d.inflight[delivery.WebhookID]--; d.inflight[delivery.WebhookID] == 0 {
+			delete(d.inflight, delivery.WebhookID)
+		}
+		d.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Отправляем доставки, время которых пришло, в несколько потоков: один
+// медленный получатель занимает не больше webhookPerHookLimit из них
+func dispatchWebhooks(ctx context.Context) error {
+	d := &webhookDispatch{inflight: map[primitive.ObjectID]int{}}
+	errs := make([]error, webhookWorkers)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = d.work(ctx)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Фоновая рассылка вебхуков: по таймеру и сразу после новых событий
+func StartWebhookDispatcher(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(webhookPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-webhookWake:
+			}
+			if err := dispatchWebhooks(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Ошибка рассылки вебхуков: %v", err)
+			}
+		}
+	}()
+}
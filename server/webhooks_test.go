@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pastebin/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// Тестовые получатели слушают на петле, куда настоящий клиент не ходит
+func useWebhookClient(c *http.Client) func() {
+	old := webhookClient
+	webhookClient = c
+	return func() { webhookClient = old }
+}
+
+func TestSendWebhookSignsPayload(t *testing.T) {
+	hook := models.Webhook{Secret: "whsec_test"}
+	delivery := models.WebhookDelivery{
+		ID:      primitive.NewObjectID(),
+		Event:   models.EventPasteCreated,
+		Payload: `{"event":"paste.created"}`,
+	}
+
+	var got *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+	hook.URL = receiver.URL
+	defer useWebhookClient(receiver.Client())()
+
+	status, err := sendWebhook(hook, delivery)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("sendWebhook = %d, %v", status, err)
+	}
+	if string(body) != delivery.Payload {
+		t.Errorf("body = %q", body)
+	}
+	if sig := got.Header.Get(webhookSignatureHeader); sig != signWebhookPayload(hook.Secret, body) {
+		t.Errorf("signature = %q", sig)
+	}
+	if got.Header.Get(webhookEventHeader) != models.EventPasteCreated || got.Header.Get(webhookDeliveryHeader) != delivery.ID.Hex() {
+		t.Errorf("headers = %v", got.Header)
+	}
+}
+
+func TestSendWebhookFailsOnErrorStatus(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusBadGateway)
+	}))
+	defer receiver.Close()
+	defer useWebhookClient(receiver.Client())()
+
+	status, err := sendWebhook(models.Webhook{URL: receiver.URL}, models.WebhookDelivery{Payload: "{}"})
+	if err == nil || status != http.StatusBadGateway {
+		t.Fatalf("sendWebhook = %d, %v", status, err)
+	}
+}
+
+func TestWebhookBackoffDoubles(t *testing.T) {
+	if webhookBackoff(1) != webhookBaseBackoff || webhookBackoff(4) != 8*webhookBaseBackoff {
+		t.Errorf("backoff = %v, %v", webhookBackoff(1), webhookBackoff(4))
+	}
+	if webhookBackoff(webhookMaxAttempts-1) > time.Hour {
+		t.Errorf("last retry too far: %v", webhookBackoff(webhookMaxAttempts-1))
+	}
+}
+
+func TestValidWebhookURL(t *testing.T) {
+	for raw, want := range map[string]bool{
+		"https://93.184.215.14/hook":     true,
+		"http://10.0.0.1:8080/":          false,
+		"http://127.0.0.1/":              false,
+		"http://localhost:8080/":         false,
+		"http://169.254.169.254/latest/": false,
+		"http://[::1]/":                  false,
+		"http://[::ffff:192.168.1.1]/":   false,
+		"http://0.0.0.0:9000/":           false,
+		"ftp://example.com":              false,
+		"/relative":                      false,
+		"https://":                       false,
+	} {
+		if got := validWebhookURL(raw); got != want {
+			t.Errorf("validWebhookURL(%q) = %v", raw, got)
+		}
+	}
+}
+
+func TestWebhookClientRefusesInternalTargets(t *testing.T) {
+	var hit bool
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+	}))
+	defer receiver.Close()
+
+	_, err := sendWebhook(models.Webhook{URL: receiver.URL}, models.WebhookDelivery{Payload: "{}"})
+	if !errors.Is(err, errWebhookAddress) || hit {
+		t.Fatalf("запрос на петлю: %v, hit=%v", err, hit)
+	}
+	if webhookClient.CheckRedirect(nil, nil) != http.ErrUseLastResponse {
+		t.Error("клиент вебхуков следует редиректам")
+	}
+}
+
+func TestClaimWebhookDelivery(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("доставка забирается атомарно", func(mt *mtest.T) {
+		useTestDB(mt)
+		busy := primitive.NewObjectID()
+		now := time.Now()
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "status", Value: models.DeliverySending},
+		}}))
+		delivery, err := claimWebhookDelivery(context.Background(), []primitive.ObjectID{busy}, now)
+		if err != nil || delivery.Status != models.DeliverySending {
+			mt.Fatalf("claimWebhookDelivery = %+v, %v", delivery, err)
+		}
+
+		cmd := mt.GetStartedEvent().Command
+		if name := cmd.Index(0).Key(); name != "findAndModify" {
+			mt.Fatalf("ожидался findAndModify, отправлено %s", name)
+		}
+		set := cmd.Lookup("update", "$set")
+		if status := set.Document().Lookup("status").StringValue(); status != models.DeliverySending {
+			mt.Errorf("статус при взятии: %q", status)
+		}
+		if locked := set.Document().Lookup("lockedUntil").Time(); !locked.After(now) {
+			mt.Errorf("lockedUntil не в будущем: %v", locked)
+		}
+		// Брошенные доставки тоже забираются, занятые вебхуки пропускаются
+		query := cmd.Lookup("query").Document()
+		or, _ := query.Lookup("$or").Array().Values()
+		if len(or) != 2 || query.Lookup("webhook_id", "$nin").Array().Index(0).Value().ObjectID() != busy {
+			mt.Errorf("неверный фильтр: %s", query)
+		}
+	})
+}
+
+func TestWebhookDispatchBusyHooks(t *testing.T) {
+	slow, fast := primitive.NewObjectID(), primitive.NewObjectID()
+	d := &webhookDispatch{inflight: map[primitive.ObjectID]int{slow: webhookPerHookLimit, fast: webhookPerHookLimit - 1}}
+	if busy := d.busyHooks(); len(busy) != 1 || busy[0] != slow {
+		t.Errorf("busyHooks = %v, want [%s]", busy, slow.Hex())
+	}
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type contextKey string
+
+// Ключ, под которым AuthMiddleware кладёт ID пользователя в контекст
+const userIDKey contextKey = "userID"
+
+func ContextWithUserID(ctx context.Context, userID primitive.ObjectID) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// ID пользователя, прошедшего AuthMiddleware; NilObjectID для гостей
+func UserIDFromContext(ctx context.Context) primitive.ObjectID {
+	userID, _ := ctx.Value(userIDKey).(primitive.ObjectID)
+	return userID
+}
+
+// Ключ для прав API-токена
+const scopesKey contextKey = "scopes"
+
+func ContextWithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesKey, scopes)
+}
+
+// Есть ли у запроса право scope. Вход через логин (JWT) ограничений не имеет,
+// API-токен — только перечисленные при создании права
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, ok := ctx.Value(scopesKey).([]string)
+	if !ok {
+		return true
+	}
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
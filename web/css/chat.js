@@ -1,53 +1,78 @@
-const chatID = "123456"; // В реальном проекте будет подставляться динамически
-const socket = new WebSocket(`ws://localhost:8080/ws?chat_id=${chatID}`);
-
-const chatBox = document.getElementById("chatBox");
-const messageInput = document.getElementById("messageInput");
-const sendBtn = document.getElementById("sendBtn");
-const statusIndicator = document.getElementById("status");
-
-// Обновление статуса WebSocket
-socket.onopen = () => {
-    statusIndicator.innerHTML = "🟢 Онлайн";
-};
-
-socket.onclose = () => {
-    statusIndicator.innerHTML = "🔴 Офлайн";
-};
-
-// Получение сообщений
-socket.onmessage = (event) => {
-    const message = JSON.parse(event.data);
-    displayMessage(message.sender, message.content);
-};
-
-// Отправка сообщений
-sendBtn.addEventListener("click", sendMessage);
-messageInput.addEventListener("keypress", (e) => {
-    if (e.key === "Enter") sendMessage();
-});
+// Чат поддержки; ID чата и роль берутся из атрибутов #chat
+document.addEventListener("DOMContentLoaded", async () => {
+    const root = document.getElementById("chat");
+    const chatID = root.dataset.chatId;
+    const isAdmin = root.dataset.admin === "true"; // Определяем, админ ли это
+    const socket = new WebSocket(`${location.protocol === "https:" ? "wss" : "ws"}://${location.host}/ws?chat_id=${encodeURIComponent(chatID)}`);
+    const chatBox = document.getElementById("chatBox");
+    const messageInput = document.getElementById("messageInput");
+    const sendBtn = document.getElementById("sendBtn");
+    const statusIndicator = document.getElementById("status");
+
+    // Запрашиваем историю сообщений
+    async function loadChatHistory() {
+        try {
+            const response = await fetch(`/history?chat_id=${encodeURIComponent(chatID)}`);
+            const messages = await response.json();
+            messages.forEach(msg => displayMessage(msg.sender, msg.content));
+        } catch (error) {
+            console.error("Ошибка загрузки истории:", error);
+        }
+    }
+
+    await loadChatHistory(); // Загружаем историю перед подключением WebSocket
 
-function sendMessage() {
-    const content = messageInput.value.trim();
-    if (!content) return;
+    socket.onopen = () => {
+        statusIndicator.innerHTML = "🟢 Онлайн";
+    };
+
+    socket.onclose = () => {
+        statusIndicator.innerHTML = "🔴 Офлайн";
+    };
 
-    const messageData = {
-        chat_id: chatID,
-        sender: "user", // Или "admin", если это администратор
-        content: content
+    socket.onmessage = (event) => {
+        const message = JSON.parse(event.data);
+        displayMessage(message.sender, message.content);
     };
 
-    socket.send(JSON.stringify(messageData));
-    displayMessage("user", content);
-    messageInput.value = "";
-}
+    sendBtn.addEventListener("click", sendMessage);
+    messageInput.addEventListener("keypress", (e) => {
+        if (e.key === "Enter") sendMessage();
+    });
 
-// Отображение сообщений в чате
-function displayMessage(sender, content) {
-    const messageDiv = document.createElement("div");
-    messageDiv.classList.add("message", sender);
-    messageDiv.textContent = content;
+    function sendMessage() {
+        const content = messageInput.value.trim();
+        if (!content) return;
 
-    chatBox.appendChild(messageDiv);
-    chatBox.scrollTop = chatBox.scrollHeight; // Автопрокрутка вниз
-}
+        const messageData = {
+            chat_id: chatID,
+            sender: isAdmin ? "admin" : "user", // Разделяем пользователей и админов
+            content: content
+        };
+
+        socket.send(JSON.stringify(messageData));
+        messageInput.value = "";
+    }
+
+    // Отображение сообщений в чате
+    function displayMessage(sender, content) {
+        const messageDiv = document.createElement("div");
+        messageDiv.classList.add("message", sender);
+        messageDiv.textContent = content;
+
+        chatBox.appendChild(messageDiv);
+        chatBox.scrollTop = chatBox.scrollHeight; // Автопрокрутка вниз
+    }
+
+    document.getElementById("closeChatBtn").addEventListener("click", () => {
+        fetch(`/close_chat?chat_id=${encodeURIComponent(chatID)}`, { method: "POST" })
+            .then(response => {
+                if (response.ok) {
+                    window.location.href = "/profile"; // Редирект после закрытия
+                } else {
+                    alert("Ошибка закрытия чата");
+                }
+            })
+            .catch(error => console.error("Ошибка:", error));
+    });
+});
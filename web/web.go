@@ -0,0 +1,8 @@
+// Package web содержит HTML-шаблоны и статические файлы,
+// встроенные в бинарник
+package web
+
+import "embed"
+
+//go:embed *.html css
+var FS embed.FS